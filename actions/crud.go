@@ -1,9 +1,11 @@
 package actions
 
 import (
+	"errors"
 	"log"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aprice/observatory/alert"
@@ -14,6 +16,13 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
+// ErrNotApplicable is returned for a check which does not apply to a subject.
+var ErrNotApplicable = errors.New("check does not apply to subject")
+
+// ErrInvalidEscalation is returned for an alert with an escalation step which
+// does not name another existing alert.
+var ErrInvalidEscalation = errors.New("escalation step must name another existing alert")
+
 // UpdatedCheckCleanup handles cleaning up check states and results when a check
 // is modified to no longer apply to some subjects.
 func UpdatedCheckCleanup(conf config.Configuration, checkID uuid.UUID, oldRoles, newRoles []string) {
@@ -165,8 +174,18 @@ func DeletedSubjectCleanup(conf config.Configuration, id uuid.UUID) {
 	wg.Wait()
 }
 
+// checkResultsReceived counts the check results received by this Coordinator.
+var checkResultsReceived uint64
+
+// CheckResultsReceived returns the number of check results received since the
+// Coordinator started, including any not saved.
+func CheckResultsReceived() uint64 {
+	return atomic.LoadUint64(&checkResultsReceived)
+}
+
 // RecordCheckResult including updating check state.
 func RecordCheckResult(result model.CheckResult, ctx model.AppContext, conf config.Configuration) error {
+	atomic.AddUint64(&checkResultsReceived, 1)
 	var (
 		subject model.Subject
 		check   model.Check
@@ -213,7 +232,7 @@ func RecordCheckResult(result model.CheckResult, ctx model.AppContext, conf conf
 	if blackout {
 		return nil
 	}
-	prevStatus := model.StatusOK
+	prev := model.CheckState{ID: result.SubjectCheckID, Status: model.StatusOK}
 	state, err := ctx.CheckStateRepo().Find(result.SubjectCheckID)
 	if err == model.ErrNotFound {
 		state = model.CheckState{
@@ -228,7 +247,7 @@ func RecordCheckResult(result model.CheckResult, ctx model.AppContext, conf conf
 	} else if err != nil {
 		log.Printf("Loading CheckState failed: %s", err.Error())
 	} else {
-		prevStatus = state.Status
+		prev = state
 		state.Updated = result.Time
 		state.Roles = subject.Roles
 		state.Tags = check.Tags
@@ -239,7 +258,9 @@ func RecordCheckResult(result model.CheckResult, ctx model.AppContext, conf conf
 		}
 		if result.Status == model.StatusOK {
 			state.Reminders = map[string]time.Time{}
+			state.Escalations = nil
 		}
+		state.Started = time.Time{}
 	}
 
 	wg.Add(1)
@@ -263,7 +284,7 @@ func RecordCheckResult(result model.CheckResult, ctx model.AppContext, conf conf
 		Check:       check,
 	}
 	if !quiet {
-		err = alert.ExecuteAlerts(crd, prevStatus, ctx, conf)
+		err = alert.ExecuteAlerts(crd, prev, state, ctx, conf)
 		if err != nil {
 			log.Printf("Executing alerts failed: %s.", err.Error())
 		}
@@ -271,6 +292,45 @@ func RecordCheckResult(result model.CheckResult, ctx model.AppContext, conf conf
 	return nil
 }
 
+// FindSubjectCheck loads a subject and check, returning ErrNotApplicable if
+// the check does not apply to the subject.
+func FindSubjectCheck(id model.SubjectCheckID, ctx model.AppContext) (model.Subject, model.Check, error) {
+	subject, err := ctx.SubjectRepo().Find(id.SubjectID)
+	if err != nil {
+		return subject, model.Check{}, err
+	}
+	check, err := ctx.CheckRepo().Find(id.CheckID)
+	if err != nil {
+		return subject, check, err
+	}
+	if !collections.NewStringSet(subject.Roles...).ContainsAny(check.Roles...) {
+		return subject, check, ErrNotApplicable
+	}
+	return subject, check, nil
+}
+
+// RecordJobStart records when the job reporting a check's results for a
+// subject started, so that the duration of its next result can be measured.
+func RecordJobStart(subject model.Subject, check model.Check, t time.Time, ctx model.AppContext) error {
+	id := model.SubjectCheckID{SubjectID: subject.ID, CheckID: check.ID}
+	state, err := ctx.CheckStateRepo().Find(id)
+	if err == model.ErrNotFound {
+		state = model.CheckState{
+			ID:            id,
+			StatusChanged: t,
+			Updated:       t,
+			Status:        model.StatusNone,
+			Type:          check.Type,
+			Roles:         subject.Roles,
+			Tags:          check.Tags,
+		}
+	} else if err != nil {
+		return err
+	}
+	state.Started = t
+	return ctx.CheckStateRepo().Upsert(state)
+}
+
 // FillCheckStateDetails transforms a collection of CheckStates into
 // CheckStateDetails by looking up the check & subject for each.
 func FillCheckStateDetails(ctx model.AppContext, states []model.CheckState) ([]model.CheckStateDetail, error) {
@@ -314,3 +374,47 @@ func FillCheckStateDetails(ctx model.AppContext, states []model.CheckState) ([]m
 	}
 	return csds, nil
 }
+
+// DefaultCheckResultPageSize is the number of CheckResults returned per page
+// when no limit is given.
+const DefaultCheckResultPageSize = 100
+
+// MaxCheckResultPageSize is the maximum number of CheckResults returned per page.
+const MaxCheckResultPageSize = 1000
+
+// QueryCheckResults executes a CheckResult query, returning a single page of
+// results and the cursor for the next page.
+func QueryCheckResults(ctx model.AppContext, query model.CheckResultQuery) (model.CheckResultPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultCheckResultPageSize
+	} else if query.Limit > MaxCheckResultPageSize {
+		query.Limit = MaxCheckResultPageSize
+	}
+	results, err := ctx.CheckResultRepo().Search(query)
+	if err == model.ErrNotFound {
+		results = []model.CheckResult{}
+	} else if err != nil {
+		return model.CheckResultPage{}, err
+	}
+	page := model.CheckResultPage{Results: results}
+	if len(results) == query.Limit {
+		page.Next = results[len(results)-1].ID.String()
+	}
+	return page, nil
+}
+
+// ValidateEscalation checks that each of an alert's escalation steps names an
+// existing alert other than the alert itself.
+func ValidateEscalation(alert model.Alert, ctx model.AppContext) error {
+	for _, step := range alert.Escalation {
+		if step.AlertID == alert.ID {
+			return ErrInvalidEscalation
+		}
+		if _, err := ctx.AlertRepo().Find(step.AlertID); err == model.ErrNotFound {
+			return ErrInvalidEscalation
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
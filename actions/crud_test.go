@@ -0,0 +1,203 @@
+package actions
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/alert"
+	"github.com/aprice/observatory/database/memory"
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/server/config"
+)
+
+func TestRecordCheckResultRecovery(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, r.URL.Path+" "+string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	conf := config.Configuration{ContextFactory: memory.InitStore()}
+	ctx, _ := conf.ContextFactory.Get()
+	subject := model.Subject{Name: "web1", Roles: []string{"web"}}
+	ctx.SubjectRepo().Create(&subject)
+	check := model.Check{Name: "HTTP", Roles: []string{"web"}, Tags: []string{"http"}}
+	ctx.CheckRepo().Create(&check)
+	alerts := []model.Alert{
+		{
+			Name:         "recovering",
+			Type:         model.AlertWebhook,
+			Parameters:   map[string]string{"url": server.URL + "/recovering", "body": "{{.Status}}"},
+			RecoveryBody: "{{.Status}} after {{.PreviousStatus}} for {{.OutageDuration}}",
+			Roles:        []string{"web"},
+			Tags:         []string{"http"},
+		},
+		{
+			Name:         "silent",
+			Type:         model.AlertWebhook,
+			Parameters:   map[string]string{"url": server.URL + "/silent", "body": "{{.Status}}"},
+			SkipRecovery: true,
+			Roles:        []string{"web"},
+			Tags:         []string{"http"},
+		},
+	}
+	for i := range alerts {
+		ctx.AlertRepo().Create(&alerts[i])
+	}
+
+	var tests = []struct {
+		statuses []model.CheckStatus
+		expected []string
+	}{
+		// Both alerts fire for the problem, without reminders, and only the
+		// alert notifying recovery fires when the check returns to OK.
+		{
+			[]model.CheckStatus{model.StatusOK, model.StatusCritical, model.StatusCritical, model.StatusOK, model.StatusOK},
+			[]string{"/recovering Critical", "/silent Critical", "/recovering OK after Critical for 10m0s"},
+		},
+		// Failing to run the check is not a recovery.
+		{
+			[]model.CheckStatus{model.StatusOK, model.StatusCritical, model.StatusFailed},
+			[]string{"/recovering Critical", "/silent Critical"},
+		},
+	}
+	for _, tt := range tests {
+		ctx.CheckResultRepo().DeleteBySubjectCheck(model.SubjectCheckID{SubjectID: subject.ID, CheckID: check.ID})
+		ctx.CheckStateRepo().DeleteBySubjectCheck(model.SubjectCheckID{SubjectID: subject.ID, CheckID: check.ID})
+		mu.Lock()
+		bodies = nil
+		mu.Unlock()
+
+		start := time.Now().Add(-time.Hour)
+		for i, status := range tt.statuses {
+			result := model.NewCheckResult(subject.ID, check.ID, start.Add(time.Duration(i)*5*time.Minute), status)
+			if err := RecordCheckResult(result, ctx, conf); err != nil {
+				t.Fatal(err)
+			}
+			alert.WaitForAlerts()
+		}
+		if len(bodies) != len(tt.expected) {
+			t.Errorf("%v: expected %v, actual %v", tt.statuses, tt.expected, bodies)
+			continue
+		}
+		for i := range tt.expected {
+			if bodies[i] != tt.expected[i] {
+				t.Errorf("%v: alert %d: expected %q, actual %q", tt.statuses, i, tt.expected[i], bodies[i])
+			}
+		}
+	}
+}
+
+func TestRecordCheckResultWhileAlerting(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		bodies   []string
+		requests int
+	)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		requests++
+		first := requests == 1
+		mu.Unlock()
+		if first {
+			// The first delivery is held until the next result is recorded,
+			// and then fails.
+			<-release
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	conf := config.Configuration{ContextFactory: memory.InitStore()}
+	ctx, _ := conf.ContextFactory.Get()
+	subject := model.Subject{Name: "web1", Roles: []string{"web"}}
+	ctx.SubjectRepo().Create(&subject)
+	check := model.Check{Name: "HTTP", Roles: []string{"web"}, Tags: []string{"http"}}
+	ctx.CheckRepo().Create(&check)
+	ctx.AlertRepo().Create(&model.Alert{
+		Name:       "hook",
+		Type:       model.AlertWebhook,
+		Parameters: map[string]string{"url": server.URL, "body": "{{.Status}}"},
+		Roles:      []string{"web"},
+		Tags:       []string{"http"},
+	})
+
+	start := time.Now().Add(-time.Hour)
+	record := func(i int, status model.CheckStatus) {
+		result := model.NewCheckResult(subject.ID, check.ID, start.Add(time.Duration(i)*5*time.Minute), status)
+		if err := RecordCheckResult(result, ctx, conf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record(0, model.StatusOK)
+	record(1, model.StatusCritical)
+	// The alert is still being delivered, so its reminder is already recorded.
+	record(2, model.StatusCritical)
+	close(release)
+	alert.WaitForAlerts()
+	if len(bodies) != 0 {
+		t.Fatalf("expected no alerts while the first was delivered, actual %v", bodies)
+	}
+
+	// The delivery failed, so the next result tries again.
+	record(3, model.StatusCritical)
+	alert.WaitForAlerts()
+	record(4, model.StatusCritical)
+	alert.WaitForAlerts()
+	if len(bodies) != 1 || bodies[0] != "Critical" {
+		t.Errorf("expected [Critical], actual %v", bodies)
+	}
+}
+
+func TestRecordJobStart(t *testing.T) {
+	conf := config.Configuration{ContextFactory: memory.InitStore()}
+	ctx, _ := conf.ContextFactory.Get()
+	subject := model.Subject{Name: "batch1", Roles: []string{"batch"}}
+	ctx.SubjectRepo().Create(&subject)
+	check := model.Check{Name: "Backup", Type: model.CheckPassive, Roles: []string{"batch"}}
+	ctx.CheckRepo().Create(&check)
+	other := model.Check{Name: "Web", Roles: []string{"web"}}
+	ctx.CheckRepo().Create(&other)
+
+	id := model.SubjectCheckID{SubjectID: subject.ID, CheckID: other.ID}
+	if _, _, err := FindSubjectCheck(id, ctx); err != ErrNotApplicable {
+		t.Errorf("expected ErrNotApplicable, actual %v", err)
+	}
+	id.CheckID = check.ID
+	if _, _, err := FindSubjectCheck(id, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-time.Minute)
+	if err := RecordJobStart(subject, check, start, ctx); err != nil {
+		t.Fatal(err)
+	}
+	state, err := ctx.CheckStateRepo().Find(id)
+	if err != nil || !state.Started.Equal(start) || state.Status != model.StatusNone {
+		t.Fatalf("expected new state started at %v, actual %+v, %v", start, state, err)
+	}
+
+	// The next result ends the job.
+	if err = RecordCheckResult(model.NewCheckResult(subject.ID, check.ID, time.Now(), model.StatusOK), ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ = ctx.CheckStateRepo().Find(id); !state.Started.IsZero() || state.Status != model.StatusOK {
+		t.Errorf("expected OK state without start, actual %+v", state)
+	}
+}
@@ -0,0 +1,332 @@
+package actions
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/aprice/observatory/collections"
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+	uuid "github.com/satori/go.uuid"
+)
+
+// span is a window of time from Start (inclusive) to End (exclusive).
+type span struct {
+	Start time.Time
+	End   time.Time
+}
+
+// overlap returns the length of time shared by the span and the given window.
+func (s span) overlap(start, end time.Time) time.Duration {
+	start = utils.LaterDate(s.Start, start)
+	end = earlierOf(s.End, end)
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// ComputeAvailability reports the time spent in each status by the checks
+// matching the query. Each result's status is assumed to hold until the next
+// result; where raw results have expired, hourly and daily rollups are used
+// instead. Time during blackout periods is excluded.
+func ComputeAvailability(ctx model.AppContext, query model.AvailabilityQuery) (model.AvailabilityReport, error) {
+	report := model.AvailabilityReport{Since: query.Since, Until: query.Until, Items: []model.Availability{}}
+	subjects, err := reportSubjects(ctx, query)
+	if err != nil {
+		return report, err
+	}
+	checks, err := reportChecks(ctx, query)
+	if err != nil {
+		return report, err
+	}
+	periods, err := ctx.PeriodRepo().Search("", "", "")
+	if err != nil && err != model.ErrNotFound {
+		return report, err
+	}
+	blackouts := []model.Period{}
+	for _, period := range periods {
+		if period.Type == model.PeriodBlackout && period.Start.Before(query.Until) && period.End.After(query.Since) {
+			blackouts = append(blackouts, period)
+		}
+	}
+
+	groups := map[string]*model.Availability{}
+	for _, subject := range subjects {
+		subjectRoles := collections.NewStringSet(subject.Roles...)
+		for _, check := range checks {
+			if !subjectRoles.ContainsAny(check.Roles...) {
+				continue
+			}
+			checkRoles := collections.NewStringSet(check.Roles...)
+			seconds, err := subjectCheckAvailability(ctx, subject, check, blackouts, query.Since, query.Until)
+			if err != nil {
+				return report, err
+			}
+			switch query.GroupBy {
+			case model.GroupByRole:
+				// Only roles the check applies through count toward them.
+				for _, role := range subject.Roles {
+					if checkRoles.Contains(role) && (query.Role == "" || role == query.Role) {
+						addToGroup(groups, role, model.Availability{Role: role}, seconds)
+					}
+				}
+			case model.GroupByTag:
+				for _, tag := range check.Tags {
+					if query.Tag == "" || tag == query.Tag {
+						addToGroup(groups, tag, model.Availability{Tag: tag}, seconds)
+					}
+				}
+			default:
+				key := subject.ID.String() + "/" + check.ID.String()
+				addToGroup(groups, key, model.Availability{Subject: subject.Name, Check: check.Name}, seconds)
+			}
+		}
+	}
+
+	for _, item := range groups {
+		total := float64(item.Seconds.Total())
+		if total > 0 {
+			item.Percent = model.StatusPercent{
+				None:     100 * float64(item.Seconds.None) / total,
+				OK:       100 * float64(item.Seconds.OK) / total,
+				Warning:  100 * float64(item.Seconds.Warning) / total,
+				Critical: 100 * float64(item.Seconds.Critical) / total,
+				Failed:   100 * float64(item.Seconds.Failed) / total,
+			}
+		}
+		report.Items = append(report.Items, *item)
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Subject+a.Role+a.Tag != b.Subject+b.Role+b.Tag {
+			return a.Subject+a.Role+a.Tag < b.Subject+b.Role+b.Tag
+		}
+		return a.Check < b.Check
+	})
+	return report, nil
+}
+
+func addToGroup(groups map[string]*model.Availability, key string, item model.Availability, seconds model.StatusTally) {
+	if existing, ok := groups[key]; ok {
+		existing.Seconds.Merge(seconds)
+		return
+	}
+	item.Seconds = seconds
+	groups[key] = &item
+}
+
+func reportSubjects(ctx model.AppContext, query model.AvailabilityQuery) ([]model.Subject, error) {
+	if query.SubjectID != uuid.Nil {
+		subject, err := ctx.SubjectRepo().Find(query.SubjectID)
+		return []model.Subject{subject}, err
+	}
+	subjects, err := ctx.SubjectRepo().Search("", query.Role)
+	if err == model.ErrNotFound {
+		return []model.Subject{}, nil
+	}
+	return subjects, err
+}
+
+func reportChecks(ctx model.AppContext, query model.AvailabilityQuery) ([]model.Check, error) {
+	if query.CheckID != uuid.Nil {
+		check, err := ctx.CheckRepo().Find(query.CheckID)
+		return []model.Check{check}, err
+	}
+	checks, err := ctx.CheckRepo().Search("", "", query.Tag)
+	if err == model.ErrNotFound {
+		return []model.Check{}, nil
+	}
+	return checks, err
+}
+
+// subjectCheckAvailability computes the time a single check on a single subject
+// spent in each status over the given window.
+func subjectCheckAvailability(ctx model.AppContext, subject model.Subject, check model.Check, periods []model.Period, since, until time.Time) (model.StatusTally, error) {
+	blackouts := blackoutSpans(periods, subject, check)
+	seconds := map[model.CheckStatus]float64{}
+
+	// The result in effect at the start of the window, followed by all results
+	// in the window, oldest first.
+	repo := ctx.CheckResultRepo()
+	prior, err := priorResult(repo, check, subject.ID, since)
+	if err != nil {
+		return model.StatusTally{}, err
+	}
+	results, err := repo.Search(model.CheckResultQuery{SubjectID: subject.ID, CheckID: check.ID, Since: since, Until: until})
+	if err != nil && err != model.ErrNotFound {
+		return model.StatusTally{}, err
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Time.Before(results[j].Time) })
+	if len(prior) > 0 {
+		prior[0].Time = since
+		results = append(prior, results...)
+	}
+
+	end := until
+	if now := time.Now(); now.Before(end) {
+		end = now
+	}
+	for i, cr := range results {
+		next := end
+		if i+1 < len(results) {
+			next = results[i+1].Time
+		}
+		if !next.After(cr.Time) {
+			continue
+		}
+		d := next.Sub(cr.Time)
+		for _, blackout := range blackouts {
+			d -= blackout.overlap(cr.Time, next)
+		}
+		seconds[cr.Status] += d.Seconds()
+	}
+
+	// Time before the earliest raw result comes from rollups, which are
+	// prorated by how much of each falls within the window. Daily rollups
+	// starting up to a day before the window may overlap it.
+	rawStart := end
+	if len(results) > 0 {
+		rawStart = results[0].Time
+	}
+	rollups, err := ctx.CheckResultRollupRepo().Search(model.CheckResultRollupQuery{
+		SubjectID: subject.ID,
+		CheckID:   check.ID,
+		Since:     since.Add(-model.RollupDaily.Duration()),
+		Until:     rawStart,
+	})
+	if err != nil && err != model.ErrNotFound {
+		return model.StatusTally{}, err
+	}
+	var (
+		last    model.CheckResultRollup
+		lastEnd time.Time
+	)
+	for _, rollup := range rollups {
+		length := rollup.ID.Period.Duration()
+		if length == 0 {
+			continue
+		}
+		if rollupEnd := rollup.ID.Start.Add(length); rollupEnd.After(lastEnd) {
+			last, lastEnd = rollup, rollupEnd
+		}
+		window := span{
+			Start: utils.LaterDate(rollup.ID.Start, since),
+			End:   earlierOf(rollup.ID.Start.Add(length), rawStart),
+		}
+		if !window.End.After(window.Start) {
+			continue
+		}
+		included := window.End.Sub(window.Start)
+		for _, blackout := range blackouts {
+			included -= blackout.overlap(window.Start, window.End)
+		}
+		scale := float64(included) / float64(length)
+		if scale <= 0 {
+			continue
+		}
+		for _, status := range model.CheckStatuses {
+			seconds[status] += float64(rollup.Seconds.Get(status)) * scale
+		}
+	}
+
+	// Raw results start on the hour after the latest rollup, but the first may
+	// come some time into it; the rollup's last status holds until then.
+	if len(results) > 0 && !lastEnd.IsZero() {
+		gap := span{Start: utils.LaterDate(lastEnd, since), End: rawStart}
+		if gap.End.After(gap.Start) {
+			d := gap.End.Sub(gap.Start)
+			for _, blackout := range blackouts {
+				d -= blackout.overlap(gap.Start, gap.End)
+			}
+			seconds[last.LastStatus] += d.Seconds()
+		}
+	}
+
+	var tally model.StatusTally
+	for status, secs := range seconds {
+		tally.Add(status, int64(math.Max(0, math.Floor(secs+0.5))))
+	}
+	return tally, nil
+}
+
+// priorResult returns the result in effect at the given time, as a slice of at
+// most one. Results are stored in the order they were recorded, which may
+// differ slightly from the order of their times, so the latest by time is
+// taken from the results shortly before; only if there are none is the
+// most recently recorded earlier result used.
+func priorResult(repo model.CheckResultRepo, check model.Check, subjectID uuid.UUID, t time.Time) ([]model.CheckResult, error) {
+	lookback := 2 * check.IntervalDuration()
+	if lookback < time.Hour {
+		lookback = time.Hour
+	}
+	query := model.CheckResultQuery{SubjectID: subjectID, CheckID: check.ID, Since: t.Add(-lookback), Until: t}
+	candidates, err := repo.Search(query)
+	if err != nil && err != model.ErrNotFound {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		query.Since, query.Limit = time.Time{}, 1
+		candidates, err = repo.Search(query)
+		if err != nil && err != model.ErrNotFound {
+			return nil, err
+		}
+		return candidates, nil
+	}
+	latest := candidates[0]
+	for _, cr := range candidates[1:] {
+		if cr.Time.After(latest.Time) {
+			latest = cr
+		}
+	}
+	return []model.CheckResult{latest}, nil
+}
+
+// blackoutSpans returns the merged, non-overlapping spans of the blackout
+// periods that apply to the given subject and check.
+func blackoutSpans(periods []model.Period, subject model.Subject, check model.Check) []span {
+	subjectRoles := collections.NewStringSet(subject.Roles...)
+	checkTags := collections.NewStringSet(check.Tags...)
+	spans := []span{}
+	for _, period := range periods {
+		forSubject := subjectRoles.ContainsAny(period.Roles...) || containsID(period.Subjects, subject.ID)
+		if len(period.Tags) > 0 {
+			// Tagged periods apply to matching checks, on all subjects if no
+			// roles or subjects are given.
+			if !checkTags.ContainsAny(period.Tags...) {
+				continue
+			}
+			forSubject = forSubject || (len(period.Roles) == 0 && len(period.Subjects) == 0)
+		}
+		if forSubject {
+			spans = append(spans, span{period.Start, period.End})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	merged := []span{}
+	for _, s := range spans {
+		if last := len(merged) - 1; last >= 0 && !s.Start.After(merged[last].End) {
+			merged[last].End = utils.LaterDate(merged[last].End, s.End)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func earlierOf(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
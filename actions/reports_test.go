@@ -0,0 +1,212 @@
+package actions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/database/memory"
+	"github.com/aprice/observatory/model"
+)
+
+func TestComputeAvailability(t *testing.T) {
+	ctx, _ := memory.InitStore().Get()
+	subject := model.Subject{Name: "web1", Roles: []string{"web"}}
+	ctx.SubjectRepo().Create(&subject)
+	check := model.Check{Name: "HTTP", Roles: []string{"web"}, Tags: []string{"http"}}
+	ctx.CheckRepo().Create(&check)
+	other := model.Check{Name: "DB", Roles: []string{"db"}}
+	ctx.CheckRepo().Create(&other)
+
+	// Four hours: OK, critical, OK, OK; the last hour is blacked out.
+	since := time.Now().Add(-4 * time.Hour).Truncate(time.Second)
+	until := since.Add(4 * time.Hour)
+	for i, status := range []model.CheckStatus{model.StatusOK, model.StatusCritical, model.StatusOK, model.StatusOK} {
+		cr := model.NewCheckResult(subject.ID, check.ID, since.Add(time.Duration(i)*time.Hour), status)
+		ctx.CheckResultRepo().Create(&cr)
+	}
+	ctx.PeriodRepo().Create(&model.Period{
+		Type:  model.PeriodBlackout,
+		Start: since.Add(3 * time.Hour),
+		End:   until.Add(time.Hour),
+		Roles: []string{"web"},
+	})
+
+	report, err := ComputeAvailability(ctx, model.AvailabilityQuery{Since: since, Until: until})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Items) != 1 {
+		t.Fatalf("Expected 1 item, got %+v", report.Items)
+	}
+	item := report.Items[0]
+	if item.Subject != "web1" || item.Check != "HTTP" {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+	if item.Seconds.OK != 7200 || item.Seconds.Critical != 3600 {
+		t.Errorf("Expected two hours OK and an hour critical, got %+v", item.Seconds)
+	}
+	if item.Percent.Critical < 33.3 || item.Percent.Critical > 33.4 {
+		t.Errorf("Expected 33.3%% critical, got %+v", item.Percent)
+	}
+
+	report, _ = ComputeAvailability(ctx, model.AvailabilityQuery{Since: since, Until: until, GroupBy: model.GroupByTag})
+	if len(report.Items) != 1 || report.Items[0].Tag != "http" || report.Items[0].Seconds.Total() != 10800 {
+		t.Errorf("Unexpected tag report: %+v", report.Items)
+	}
+}
+
+func TestComputeAvailabilityFromRollups(t *testing.T) {
+	ctx, _ := memory.InitStore().Get()
+	subject := model.Subject{Name: "web1", Roles: []string{"web"}}
+	ctx.SubjectRepo().Create(&subject)
+	check := model.Check{Name: "HTTP", Roles: []string{"web"}}
+	ctx.CheckRepo().Create(&check)
+
+	day := time.Now().Add(-30 * 24 * time.Hour).Truncate(24 * time.Hour)
+	rollup := model.CheckResultRollup{ID: model.CheckResultRollupID{
+		SubjectID: subject.ID,
+		CheckID:   check.ID,
+		Period:    model.RollupDaily,
+		Start:     day,
+	}}
+	rollup.Seconds.Add(model.StatusOK, 86000)
+	rollup.Seconds.Add(model.StatusWarning, 400)
+	ctx.CheckResultRollupRepo().Upsert(rollup)
+
+	report, err := ComputeAvailability(ctx, model.AvailabilityQuery{Since: day, Until: day.Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Items) != 1 || report.Items[0].Seconds.OK != 86000 || report.Items[0].Seconds.Warning != 400 {
+		t.Errorf("Unexpected report: %+v", report.Items)
+	}
+}
+
+func TestComputeAvailabilityWindowEdges(t *testing.T) {
+	ctx, _ := memory.InitStore().Get()
+	subject := model.Subject{Name: "app1", Roles: []string{"web", "db"}}
+	ctx.SubjectRepo().Create(&subject)
+	web := model.Check{Name: "HTTP", Roles: []string{"web"}, Interval: 60}
+	ctx.CheckRepo().Create(&web)
+	db := model.Check{Name: "DB", Roles: []string{"db"}, Interval: 60}
+	ctx.CheckRepo().Create(&db)
+
+	since := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	until := since.Add(2 * time.Hour)
+	// The HTTP check was OK before the window, but a Critical result with an
+	// earlier time was recorded after it; the OK result is the one in effect.
+	for _, cr := range []model.CheckResult{
+		model.NewCheckResult(subject.ID, web.ID, since.Add(-5*time.Minute), model.StatusOK),
+		model.NewCheckResult(subject.ID, web.ID, since.Add(-20*time.Minute), model.StatusCritical),
+		model.NewCheckResult(subject.ID, web.ID, since.Add(time.Hour), model.StatusNone),
+		model.NewCheckResult(subject.ID, db.ID, since, model.StatusCritical),
+	} {
+		ctx.CheckResultRepo().Create(&cr)
+	}
+
+	report, err := ComputeAvailability(ctx, model.AvailabilityQuery{Since: since, Until: until, CheckID: web.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Items) != 1 || report.Items[0].Seconds.OK != 3600 || report.Items[0].Seconds.None != 3600 {
+		t.Fatalf("Expected an hour OK and an hour with no status, got %+v", report.Items)
+	}
+	percent := report.Items[0].Percent
+	if sum := percent.None + percent.OK + percent.Warning + percent.Critical + percent.Failed; sum < 99.99 || sum > 100.01 {
+		t.Errorf("Expected percentages to total 100, got %+v", percent)
+	}
+
+	// Each role counts only the checks which apply through it.
+	report, _ = ComputeAvailability(ctx, model.AvailabilityQuery{Since: since, Until: until, GroupBy: model.GroupByRole})
+	for _, item := range report.Items {
+		if item.Role == "web" && item.Seconds.Critical != 0 || item.Role == "db" && item.Seconds.Critical != 7200 {
+			t.Errorf("Unexpected role item: %+v", item)
+		}
+	}
+	if len(report.Items) != 2 {
+		t.Errorf("Expected 2 roles, got %+v", report.Items)
+	}
+}
+
+func TestComputeAvailabilityProratesRollups(t *testing.T) {
+	ctx, _ := memory.InitStore().Get()
+	subject := model.Subject{Name: "web1", Roles: []string{"web"}}
+	ctx.SubjectRepo().Create(&subject)
+	check := model.Check{Name: "HTTP", Roles: []string{"web"}}
+	ctx.CheckRepo().Create(&check)
+
+	day := time.Now().Add(-30 * 24 * time.Hour).Truncate(24 * time.Hour)
+	for i, status := range []model.CheckStatus{model.StatusOK, model.StatusCritical} {
+		rollup := model.CheckResultRollup{ID: model.CheckResultRollupID{
+			SubjectID: subject.ID,
+			CheckID:   check.ID,
+			Period:    model.RollupDaily,
+			Start:     day.Add(time.Duration(i) * 24 * time.Hour),
+		}}
+		rollup.Seconds.Add(status, 86400)
+		ctx.CheckResultRollupRepo().Upsert(rollup)
+	}
+
+	// The window covers the second half of the first day and the first
+	// quarter of the second.
+	since, until := day.Add(12*time.Hour), day.Add(30*time.Hour)
+	report, err := ComputeAvailability(ctx, model.AvailabilityQuery{Since: since, Until: until})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Items) != 1 || report.Items[0].Seconds.OK != 43200 || report.Items[0].Seconds.Critical != 21600 {
+		t.Errorf("Unexpected report: %+v", report.Items)
+	}
+}
+
+func TestComputeAvailabilityBetweenRollupsAndResults(t *testing.T) {
+	ctx, _ := memory.InitStore().Get()
+	subject := model.Subject{Name: "web1", Roles: []string{"web"}}
+	ctx.SubjectRepo().Create(&subject)
+	check := model.Check{Name: "HTTP", Roles: []string{"web"}}
+	ctx.CheckRepo().Create(&check)
+
+	// An hour rolled up, ending Critical, then raw results from 20 minutes
+	// into the next hour.
+	hour := time.Now().Add(-30 * 24 * time.Hour).Truncate(24 * time.Hour).Add(9 * time.Hour)
+	rollup := model.CheckResultRollup{
+		ID: model.CheckResultRollupID{
+			SubjectID: subject.ID,
+			CheckID:   check.ID,
+			Period:    model.RollupHourly,
+			Start:     hour,
+		},
+		LastStatus: model.StatusCritical,
+	}
+	rollup.Seconds.Add(model.StatusWarning, 3600)
+	ctx.CheckResultRollupRepo().Upsert(rollup)
+	for _, m := range []time.Duration{80, 110} {
+		cr := model.NewCheckResult(subject.ID, check.ID, hour.Add(m*time.Minute), model.StatusOK)
+		ctx.CheckResultRepo().Create(&cr)
+	}
+
+	var tests = []struct {
+		since                 time.Duration
+		warning, critical, ok int64
+	}{
+		// Half the rolled up hour, and the rollup's last status until the
+		// first result.
+		{30 * time.Minute, 1800, 1200, 2400},
+		// A window starting mid-hour, before the first result.
+		{70 * time.Minute, 0, 600, 2400},
+	}
+	for _, tt := range tests {
+		since, until := hour.Add(tt.since), hour.Add(2*time.Hour)
+		report, err := ComputeAvailability(ctx, model.AvailabilityQuery{Since: since, Until: until})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Items) != 1 {
+			t.Fatalf("Unexpected report: %+v", report.Items)
+		}
+		seconds := report.Items[0].Seconds
+		if seconds.Warning != tt.warning || seconds.Critical != tt.critical || seconds.OK != tt.ok {
+			t.Errorf("Since %v: expected %d warning, %d critical, %d OK seconds, actual %+v", tt.since, tt.warning, tt.critical, tt.ok, seconds)
+		}
+	}
+}
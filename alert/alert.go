@@ -7,8 +7,10 @@ import (
 	"log"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
+	uuid "github.com/satori/go.uuid"
 	"gopkg.in/gomail.v2"
 
 	"github.com/aprice/observatory/collections"
@@ -18,54 +20,236 @@ import (
 )
 
 // MockAlertExecutions records when Mock alerts are executed for testing.
-var MockAlertExecutions = collections.StringSet{}
+var MockAlertExecutions = &MockExecutions{executed: collections.StringSet{}}
 
-// ExecuteAlerts for a given check.
+// MockExecutions is a set of the subject/check IDs Mock alerts executed for,
+// safe to update from the goroutines delivering alerts.
+type MockExecutions struct {
+	sync.Mutex
+	executed collections.StringSet
+}
+
+// Add records a Mock alert execution.
+func (me *MockExecutions) Add(key string) {
+	me.Lock()
+	defer me.Unlock()
+	me.executed.Add(key)
+}
+
+// Contains reports whether a Mock alert executed for the given key.
+func (me *MockExecutions) Contains(key string) bool {
+	me.Lock()
+	defer me.Unlock()
+	return me.executed.Contains(key)
+}
+
+// alertCounts counts the alerts fired and failed by this Coordinator, by type.
+var alertCounts = struct {
+	sync.Mutex
+	fired  map[model.AlertType]uint64
+	failed map[model.AlertType]uint64
+}{fired: map[model.AlertType]uint64{}, failed: map[model.AlertType]uint64{}}
+
+// AlertCounts returns the number of alerts of each type fired successfully, and
+// the number which failed, since the Coordinator started.
+func AlertCounts() (fired, failed map[model.AlertType]uint64) {
+	alertCounts.Lock()
+	defer alertCounts.Unlock()
+	fired = make(map[model.AlertType]uint64, len(alertCounts.fired))
+	for t, n := range alertCounts.fired {
+		fired[t] = n
+	}
+	failed = make(map[model.AlertType]uint64, len(alertCounts.failed))
+	for t, n := range alertCounts.failed {
+		failed[t] = n
+	}
+	return fired, failed
+}
+
+// pendingAlerts tracks alerts being delivered in the background.
+var pendingAlerts sync.WaitGroup
+
+// WaitForAlerts blocks until every alert being delivered in the background has
+// been delivered or has failed.
+func WaitForAlerts() {
+	pendingAlerts.Wait()
+}
+
+// ExecuteAlerts for a given check, given its state before and after the result
+// was recorded. The alerts due are delivered in the background, since webhooks
+// may retry for some time. Their reminders are recorded before delivery starts,
+// so results arriving meanwhile don't fire them again, and are rolled back for
+// any alert which fails.
 //TODO: Skip over any alerts that have a tag in a blackout period that covers this CheckResult,
 //	even if the check does not have the tag.
-func ExecuteAlerts(result model.CheckResultDetail, prevStatus model.CheckStatus, ctx model.AppContext, conf config.Configuration) error {
+func ExecuteAlerts(result model.CheckResultDetail, prev model.CheckState, state model.CheckState, ctx model.AppContext, conf config.Configuration) error {
 	now := time.Now()
-	state, err := ctx.CheckStateRepo().Find(result.SubjectCheckID)
-	if err != nil {
-		return err
+	result.PreviousStatus = prev.Status
+	if result.Status <= model.StatusOK && !result.Recovered() {
+		// Still OK, or no longer reporting a problem without having recovered
+		// (e.g. the check failed to run), nothing to do here.
+		return nil
 	}
-	if result.Status <= model.StatusOK && prevStatus <= model.StatusOK {
-		// Was OK before, still OK now, nothing to do here.
-		return err
+	if prev.Status > model.StatusOK {
+		result.OutageDuration = result.Time.Sub(prev.StatusChanged)
 	}
+	recovered := result.Recovered()
 	alerts, err := ctx.AlertRepo().FindByFilter(result.Subject.Roles, result.Check.Tags)
 	if err != nil {
 		return err
 	}
+	// If we've recovered, or are newly in problem state, reset reminders. If this was a non-issue (e.g. OK -> OK) we would have bailed further up
+	reset := prev.Status <= model.StatusOK || recovered || state.Reminders == nil
+	if reset {
+		state.Reminders = map[string]time.Time{}
+	}
+	if recovered {
+		alerts = append(alerts, escalatedAlerts(prev, alerts, ctx)...)
+	}
+	due := []model.Alert{}
 	for _, alert := range alerts {
-		// If we've recovered, or are newly in problem state, reset reminders. If this was a non-issue (e.g. OK -> OK) we would have bailed further up
-		if prevStatus == model.StatusOK || result.Status == model.StatusOK {
-			state.Reminders = map[string]time.Time{}
+		if recovered {
+			// Only alerts which fired for the problem notify of its recovery.
+			_, fired := prev.Reminders[alert.ID.String()]
+			_, escalated := prev.Escalations[alert.ID.String()]
+			if !(fired || escalated) || !alert.NotifiesRecovery() {
+				continue
+			}
+		} else if lastAlert, ok := state.Reminders[alert.ID.String()]; ok && (alert.ReminderInterval <= 0 || now.Sub(lastAlert) < alert.ReminderDuration()) {
+			continue
+		}
+		due = append(due, alert)
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	// Recoveries leave no reminders to record.
+	previous := map[string]time.Time{}
+	if !recovered {
+		if reset {
+			state.Escalations = nil
 		}
-		if lastAlert, ok := state.Reminders[alert.ID.String()]; !ok || (alert.ReminderInterval > 0 && now.Sub(lastAlert) >= alert.ReminderDuration()) {
-			err = executeAlert(result, alert, conf)
-			if err != nil {
-				log.Printf("Firing alert %s failed: %v", alert.Name, err)
-			} else {
-				state.Reminders[alert.ID.String()] = now
-				err = ctx.CheckStateRepo().Upsert(state)
-				if err != nil {
-					log.Println(err)
-				}
+		for _, alert := range due {
+			id := alert.ID.String()
+			if t, ok := state.Reminders[id]; ok {
+				previous[id] = t
 			}
+			state.Reminders[id] = now
+		}
+		if err = ctx.CheckStateRepo().Upsert(state); err != nil {
+			return err
 		}
 	}
 
+	pendingAlerts.Add(1)
+	go func() {
+		defer pendingAlerts.Done()
+		failed := []string{}
+		for _, alert := range due {
+			if err := fireAlert(result, alert, conf); err != nil {
+				failed = append(failed, alert.ID.String())
+			}
+		}
+		if recovered || len(failed) == 0 {
+			return
+		}
+		if err := rollbackReminders(state.ID, failed, now, previous, conf); err != nil {
+			log.Printf("Rolling back alert reminders for %s/%s failed: %s", state.ID.SubjectID, state.ID.CheckID, err.Error())
+		}
+	}()
 	return nil
 }
 
+// rollbackReminders restores the reminders of alerts which failed to fire, so
+// that the next result tries them again. Reminders recorded since, such as by
+// a later result, are left alone.
+func rollbackReminders(id model.SubjectCheckID, failed []string, recorded time.Time, previous map[string]time.Time, conf config.Configuration) error {
+	ctx, err := conf.ContextFactory.Get()
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+	current, err := ctx.CheckStateRepo().Find(id)
+	if err == model.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	changed := false
+	for _, alertID := range failed {
+		if t, ok := current.Reminders[alertID]; !ok || !t.Equal(recorded) {
+			continue
+		}
+		if t, ok := previous[alertID]; ok {
+			current.Reminders[alertID] = t
+		} else {
+			delete(current.Reminders, alertID)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return ctx.CheckStateRepo().Upsert(current)
+}
+
+// escalatedAlerts returns the alerts escalated to for a check's problem which
+// are not among the given alerts.
+func escalatedAlerts(state model.CheckState, alerts []model.Alert, ctx model.AppContext) []model.Alert {
+	known := collections.NewStringSet()
+	for _, alert := range alerts {
+		known.Add(alert.ID.String())
+	}
+	escalated := []model.Alert{}
+	for id := range state.Escalations {
+		alertID, err := uuid.FromString(id)
+		if err != nil || known.Contains(id) {
+			continue
+		}
+		alert, err := ctx.AlertRepo().Find(alertID)
+		if err != nil {
+			log.Printf("Loading escalated alert %s failed: %v", id, err)
+			continue
+		}
+		escalated = append(escalated, alert)
+	}
+	return escalated
+}
+
+// fireAlert executes an alert, counting and logging the outcome.
+func fireAlert(result model.CheckResultDetail, alert model.Alert, conf config.Configuration) error {
+	err := executeAlert(result, alert, conf)
+	alertCounts.Lock()
+	if err != nil {
+		alertCounts.failed[alert.Type]++
+	} else {
+		alertCounts.fired[alert.Type]++
+	}
+	alertCounts.Unlock()
+	if err != nil {
+		log.Printf("Firing alert %s failed: %v", alert.Name, err)
+	}
+	return err
+}
+
 func executeAlert(result model.CheckResultDetail, alert model.Alert, conf config.Configuration) error {
 	var err error
+	params := alert.Parameters
+	if result.Recovered() {
+		params = alert.RecoveryParameters()
+	}
 	switch alert.Type {
 	case model.AlertExec:
-		err = executeAlertExec(result, alert.Parameters)
+		err = executeAlertExec(result, params)
 	case model.AlertEmail:
-		err = executeAlertEmail(result, alert.Parameters, conf)
+		err = executeAlertEmail(result, params, conf)
+	case model.AlertPagerDuty:
+		err = executeAlertPagerDuty(result, params, conf)
+	case model.AlertWebhook:
+		err = executeAlertWebhook(result, params)
+	case model.AlertChat:
+		err = executeAlertChat(result, params)
 	case model.AlertMock:
 		MockAlertExecutions.Add(fmt.Sprintf("%s/%s", result.SubjectID, result.CheckID))
 	default:
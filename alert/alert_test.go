@@ -10,9 +10,13 @@ import (
 //handleAlertTemplate(templateText string, ai model.CheckResultDetail) (string, error)
 func TestHandleAlertTemplate(t *testing.T) {
 	crd := model.CheckResultDetail{
-		CheckResult: model.CheckResult{Status: model.StatusOK},
-		Subject:     model.Subject{Name: "Test Subject"},
-		Check:       model.Check{Name: "Test Check"},
+		CheckResult: model.CheckResult{
+			Status:  model.StatusOK,
+			Message: "200 OK",
+			Metrics: map[string]float64{"latency_ms": 12.5},
+		},
+		Subject: model.Subject{Name: "Test Subject"},
+		Check:   model.Check{Name: "Test Check"},
 	}
 
 	var tests = []struct {
@@ -25,6 +29,11 @@ func TestHandleAlertTemplate(t *testing.T) {
 			"OK: Test Subject - Test Check",
 			nil,
 		},
+		{
+			"{{.Message}} ({{index .Metrics \"latency_ms\"}}ms)",
+			"200 OK (12.5ms)",
+			nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,149 @@
+package alert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+const (
+	defaultChatText     = "{{.Status}}: {{.Subject.Name}} - {{.Check.Name}}"
+	defaultChatRecovery = "Recovered: {{.Subject.Name}} - {{.Check.Name}}"
+)
+
+// chatMessage is a message in the Slack incoming webhook format, which
+// Mattermost and other chat tools also accept.
+type chatMessage struct {
+	Text        string           `json:"text"`
+	Channel     string           `json:"channel,omitempty"`
+	Username    string           `json:"username,omitempty"`
+	Attachments []chatAttachment `json:"attachments"`
+}
+
+// chatAttachment is the colour-coded block which details a check result.
+type chatAttachment struct {
+	Fallback string      `json:"fallback"`
+	Color    string      `json:"color"`
+	Text     string      `json:"text,omitempty"`
+	Fields   []chatField `json:"fields"`
+	Footer   string      `json:"footer"`
+	Ts       int64       `json:"ts"`
+}
+
+type chatField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func chatColor(status model.CheckStatus) string {
+	switch status {
+	case model.StatusOK:
+		return "#2eb886"
+	case model.StatusWarning:
+		return "#daa038"
+	case model.StatusCritical:
+		return "#a30200"
+	default:
+		return "#808080"
+	}
+}
+
+// chatEscaper escapes the characters which Slack's message formatting treats
+// as control characters.
+var chatEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// formatChatDuration formats a duration to the nearest second, at most two
+// units, such as "3h 25m" or "40s".
+func formatChatDuration(d time.Duration) string {
+	s := int64(d / time.Second)
+	days, hours, minutes, seconds := s/86400, s/3600%24, s/60%60, s%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
+
+// newChatMessage builds the message for a check result. Parameters are:
+//
+//	url      - the incoming webhook URL (required)
+//	subject  - a template for the message text (default
+//	           "{{.Status}}: {{.Subject.Name}} - {{.Check.Name}}", or
+//	           "Recovered: {{.Subject.Name}} - {{.Check.Name}}" on recovery)
+//	channel  - the channel to post to, instead of the webhook's default
+//	username - the name to post as, instead of the webhook's default
+//
+// Alerts may instead give the message text as text, and the recovery message
+// text as recovery, as chat alerts first did. The text is escaped for Slack's
+// formatting once the template has been executed.
+func newChatMessage(result model.CheckResultDetail, params map[string]string) (chatMessage, error) {
+	textTemplate := params["subject"]
+	if textTemplate == "" && result.Recovered() {
+		textTemplate = firstNonEmpty(params["recovery"], defaultChatRecovery)
+	} else if textTemplate == "" {
+		textTemplate = firstNonEmpty(params["text"], defaultChatText)
+	}
+	text, err := handleTextTemplate(textTemplate, result)
+	if err != nil {
+		return chatMessage{}, err
+	}
+
+	fields := []chatField{
+		{Title: "Subject", Value: chatEscaper.Replace(result.Subject.Name), Short: true},
+		{Title: "Check", Value: chatEscaper.Replace(result.Check.Name), Short: true},
+	}
+	if len(result.Subject.Roles) > 0 {
+		fields = append(fields, chatField{Title: "Roles", Value: chatEscaper.Replace(strings.Join(result.Subject.Roles, ", ")), Short: true})
+	}
+	if len(result.Check.Tags) > 0 {
+		fields = append(fields, chatField{Title: "Tags", Value: chatEscaper.Replace(strings.Join(result.Check.Tags, ", ")), Short: true})
+	}
+	if result.OutageDuration > 0 {
+		fields = append(fields, chatField{Title: "Outage", Value: formatChatDuration(result.OutageDuration), Short: true})
+	}
+
+	return chatMessage{
+		Text:     chatEscaper.Replace(text),
+		Channel:  params["channel"],
+		Username: params["username"],
+		Attachments: []chatAttachment{{
+			Fallback: text,
+			Color:    chatColor(result.Status),
+			Text:     chatEscaper.Replace(result.Message),
+			Fields:   fields,
+			Footer:   "Observatory",
+			Ts:       result.Time.Unix(),
+		}},
+	}, nil
+}
+
+// executeAlertChat posts a message about the check result to a Slack-compatible
+// incoming webhook. See newChatMessage for its parameters.
+func executeAlertChat(result model.CheckResultDetail, params map[string]string) error {
+	url := params["url"]
+	if url == "" {
+		return fmt.Errorf("chat alert has no url")
+	}
+	msg, err := newChatMessage(result, params)
+	if err != nil {
+		return err
+	}
+	return postJSON(url, msg)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+func TestExecuteAlertChat(t *testing.T) {
+	messages := []chatMessage{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := chatMessage{}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+			return
+		}
+		messages = append(messages, msg)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	crd := model.CheckResultDetail{
+		CheckResult: model.CheckResult{Time: now, Status: model.StatusCritical, Message: "load 12.5"},
+		Subject:     model.Subject{Name: "web01", Roles: []string{"web", "frontend"}},
+		Check:       model.Check{Name: "Load", Tags: []string{"cpu"}},
+	}
+	if err := executeAlertChat(crd, map[string]string{}); err == nil {
+		t.Error("expected error without url")
+	}
+
+	params := map[string]string{"url": server.URL, "channel": "#ops"}
+	crd.PreviousStatus = model.StatusWarning
+	crd.OutageDuration = 90 * time.Minute
+	if err := executeAlertChat(crd, params); err != nil {
+		t.Fatal(err)
+	}
+	crd.Status, crd.PreviousStatus = model.StatusOK, model.StatusCritical
+	if err := executeAlertChat(crd, params); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, actual %d", len(messages))
+	}
+
+	problem, recovery := messages[0], messages[1]
+	if problem.Text != "Critical: web01 - Load" || problem.Channel != "#ops" || len(problem.Attachments) != 1 {
+		t.Fatalf("unexpected problem message %+v", problem)
+	}
+	attachment := problem.Attachments[0]
+	if attachment.Color != chatColor(model.StatusCritical) || attachment.Text != "load 12.5" || attachment.Ts != now.Unix() {
+		t.Errorf("unexpected attachment %+v", attachment)
+	}
+	expected := map[string]string{
+		"Subject": "web01",
+		"Check":   "Load",
+		"Roles":   "web, frontend",
+		"Tags":    "cpu",
+		"Outage":  "1h 30m",
+	}
+	actual := map[string]string{}
+	for _, field := range attachment.Fields {
+		actual[field.Title] = field.Value
+	}
+	for title, value := range expected {
+		if actual[title] != value {
+			t.Errorf("field %s: expected %q, actual %q", title, value, actual[title])
+		}
+	}
+
+	if recovery.Text != "Recovered: web01 - Load" || recovery.Attachments[0].Color != chatColor(model.StatusOK) {
+		t.Errorf("unexpected recovery message %+v", recovery)
+	}
+}
+
+func TestFormatChatDuration(t *testing.T) {
+	var tests = []struct {
+		in       time.Duration
+		expected string
+	}{
+		{400 * time.Millisecond, "0s"},
+		{45 * time.Second, "45s"},
+		{3*time.Minute + 5*time.Second, "3m 5s"},
+		{2*time.Hour + 10*time.Minute + 30*time.Second, "2h 10m"},
+		{50 * time.Hour, "2d 2h"},
+	}
+	for _, tt := range tests {
+		if actual := formatChatDuration(tt.in); actual != tt.expected {
+			t.Errorf("formatChatDuration(%v): expected %q, actual %q", tt.in, tt.expected, actual)
+		}
+	}
+}
+
+func TestNewChatMessage(t *testing.T) {
+	crd := model.CheckResultDetail{
+		CheckResult: model.CheckResult{Status: model.StatusCritical, Message: `body "<b>" & more`},
+		Subject:     model.Subject{Name: "web01"},
+		Check:       model.Check{Name: `Say "hi"`},
+	}
+	var tests = []struct {
+		status   model.CheckStatus
+		params   map[string]string
+		expected string
+	}{
+		{model.StatusCritical, map[string]string{}, `Critical: web01 - Say "hi"`},
+		{model.StatusCritical, map[string]string{"subject": "<{{.Check.Name}}>"}, `&lt;Say "hi"&gt;`},
+		{model.StatusCritical, map[string]string{"text": "down {{.Subject.Name}}"}, "down web01"},
+		{model.StatusOK, map[string]string{"text": "down", "recovery": "up {{.Subject.Name}}"}, "up web01"},
+		{model.StatusOK, map[string]string{"subject": "{{.Status}}", "recovery": "up"}, "OK"},
+	}
+	for _, tt := range tests {
+		crd.Status, crd.PreviousStatus = tt.status, model.StatusCritical
+		msg, err := newChatMessage(crd, tt.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Text != tt.expected {
+			t.Errorf("newChatMessage(%v): expected %q, actual %q", tt.params, tt.expected, msg.Text)
+		}
+	}
+	msg, _ := newChatMessage(crd, map[string]string{})
+	if expected := `body "&lt;b&gt;" &amp; more`; msg.Attachments[0].Text != expected {
+		t.Errorf("expected attachment text %q, actual %q", expected, msg.Attachments[0].Text)
+	}
+}
@@ -0,0 +1,162 @@
+package alert
+
+import (
+	"log"
+	"time"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/server/config"
+	"github.com/aprice/observatory/utils"
+)
+
+const escalationInterval = time.Minute
+
+// EscalateAlerts starts a loop periodically escalating alerts for checks which
+// remain Critical. Only the cluster leader does any work.
+func EscalateAlerts(conf config.Configuration, quit utils.SentinelChannel) {
+	ticker := time.NewTicker(escalationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			// Follow the leader
+			if !conf.IsLeader() {
+				continue
+			}
+			ctx, err := conf.ContextFactory.Get()
+			if err != nil {
+				log.Printf("Alert escalation failed: %s", err.Error())
+				continue
+			}
+			if err = Escalate(ctx, conf, time.Now()); err != nil {
+				log.Printf("Alert escalation failed: %s", err.Error())
+			}
+			ctx.Close()
+		}
+	}
+}
+
+// Escalate fires every escalation step due as of the given time. A step is due
+// once an alert with the step in its escalation has fired for a check, and the
+// check has been Critical for the step's delay. Each escalated alert fires
+// once per problem.
+func Escalate(ctx model.AppContext, conf config.Configuration, now time.Time) error {
+	states, err := ctx.CheckStateRepo().InStatusRoles([]model.CheckStatus{model.StatusCritical}, nil)
+	if err == model.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if err = escalateCheck(ctx, conf, state, now); err != nil {
+			log.Printf("Escalating alerts for %s/%s failed: %s", state.ID.SubjectID, state.ID.CheckID, err.Error())
+		}
+	}
+	return nil
+}
+
+// dueEscalations returns the escalation steps of the given alerts which are
+// due for a check, without repeating any alert already escalated to or fired
+// for the problem.
+func dueEscalations(state model.CheckState, alerts []model.Alert, now time.Time) []model.EscalationStep {
+	critical := now.Sub(state.StatusChanged)
+	due := []model.EscalationStep{}
+	seen := map[string]bool{}
+	for _, alert := range alerts {
+		if _, fired := state.Reminders[alert.ID.String()]; !fired {
+			continue
+		}
+		for _, step := range alert.Escalation {
+			id := step.AlertID.String()
+			if _, escalated := state.Escalations[id]; escalated || seen[id] || critical < step.DelayDuration() {
+				continue
+			}
+			if _, fired := state.Reminders[id]; fired {
+				continue
+			}
+			seen[id] = true
+			due = append(due, step)
+		}
+	}
+	return due
+}
+
+func escalateCheck(ctx model.AppContext, conf config.Configuration, state model.CheckState, now time.Time) error {
+	alerts, err := ctx.AlertRepo().FindByFilter(state.Roles, state.Tags)
+	if err == model.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	due := dueEscalations(state, alerts, now)
+	if len(due) == 0 {
+		return nil
+	}
+
+	subject, err := ctx.SubjectRepo().Find(state.ID.SubjectID)
+	if err != nil {
+		return err
+	}
+	check, err := ctx.CheckRepo().Find(state.ID.CheckID)
+	if err != nil {
+		return err
+	}
+	periods, err := ctx.PeriodRepo().FindForSubjectChecks(subject, check.Tags, []model.PeriodType{model.PeriodBlackout, model.PeriodQuiet})
+	if err != nil && err != model.ErrNotFound {
+		return err
+	}
+	if len(periods) > 0 {
+		return nil
+	}
+	results, err := ctx.CheckResultRepo().Search(model.CheckResultQuery{SubjectID: subject.ID, CheckID: check.ID, Limit: 1})
+	if err != nil && err != model.ErrNotFound {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	result := model.CheckResultDetail{
+		CheckResult:    results[0],
+		Subject:        subject,
+		Check:          check,
+		PreviousStatus: state.Status,
+		OutageDuration: now.Sub(state.StatusChanged),
+	}
+
+	escalated := map[string]time.Time{}
+	for _, step := range due {
+		alert, err := ctx.AlertRepo().Find(step.AlertID)
+		if err != nil {
+			log.Printf("Loading escalated alert %s failed: %v", step.AlertID, err)
+			continue
+		}
+		if err = fireAlert(result, alert, conf); err == nil {
+			escalated[alert.ID.String()] = now
+		}
+	}
+	if len(escalated) == 0 {
+		return nil
+	}
+
+	// Firing alerts can take a while, so record the escalations against the
+	// current state rather than overwriting any results recorded meanwhile.
+	// If the problem has ended since, there is nothing left to record.
+	current, err := ctx.CheckStateRepo().Find(state.ID)
+	if err == model.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if current.Status != model.StatusCritical || !current.StatusChanged.Equal(state.StatusChanged) {
+		return nil
+	}
+	if current.Escalations == nil {
+		current.Escalations = map[string]time.Time{}
+	}
+	for id, t := range escalated {
+		current.Escalations[id] = t
+	}
+	return ctx.CheckStateRepo().Upsert(current)
+}
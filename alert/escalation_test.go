@@ -0,0 +1,202 @@
+package alert
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/database/memory"
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/server/config"
+	"github.com/aprice/observatory/utils"
+)
+
+func TestEscalate(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		fired []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fired = append(fired, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	takeFired := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		out := fired
+		fired = nil
+		return out
+	}
+
+	conf := config.Configuration{ContextFactory: memory.InitStore()}
+	ctx, _ := conf.ContextFactory.Get()
+	subject := model.Subject{Name: "db1", Roles: []string{"db"}}
+	ctx.SubjectRepo().Create(&subject)
+	check := model.Check{Name: "Replication", Roles: []string{"db"}, Tags: []string{"mysql"}}
+	ctx.CheckRepo().Create(&check)
+	webhook := func(name string) map[string]string {
+		return map[string]string{"url": server.URL + "/" + name}
+	}
+	tier2 := model.Alert{Name: "tier2", Type: model.AlertWebhook, Parameters: webhook("tier2")}
+	ctx.AlertRepo().Create(&tier2)
+	manager := model.Alert{Name: "manager", Type: model.AlertWebhook, Parameters: webhook("manager"), SkipRecovery: true}
+	ctx.AlertRepo().Create(&manager)
+	tier1 := model.Alert{
+		Name:       "tier1",
+		Type:       model.AlertWebhook,
+		Parameters: webhook("tier1"),
+		Escalation: []model.EscalationStep{{AlertID: tier2.ID, Delay: 15}, {AlertID: manager.ID, Delay: 60}},
+		Roles:      []string{"db"},
+		Tags:       []string{"mysql"},
+	}
+	ctx.AlertRepo().Create(&tier1)
+
+	start := time.Now().Add(-3 * time.Hour)
+	result := model.NewCheckResult(subject.ID, check.ID, start, model.StatusCritical)
+	ctx.CheckResultRepo().Create(&result)
+	ctx.CheckStateRepo().Upsert(model.CheckState{
+		ID:            result.SubjectCheckID,
+		StatusChanged: start,
+		Updated:       start,
+		Status:        model.StatusCritical,
+		Roles:         subject.Roles,
+		Tags:          check.Tags,
+		Reminders:     map[string]time.Time{tier1.ID.String(): start},
+	})
+
+	var tests = []struct {
+		after    time.Duration
+		expected []string
+	}{
+		{10 * time.Minute, nil},
+		{20 * time.Minute, []string{"/tier2"}},
+		{30 * time.Minute, nil},
+		{2 * time.Hour, []string{"/manager"}},
+		{3 * time.Hour, nil},
+	}
+	for _, tt := range tests {
+		if err := Escalate(ctx, conf, start.Add(tt.after)); err != nil {
+			t.Fatal(err)
+		}
+		if actual := takeFired(); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Escalate after %v: expected %v, actual %v", tt.after, tt.expected, actual)
+		}
+	}
+
+	// On recovery, escalated alerts notify as the alerts matching the check do.
+	prev, _ := ctx.CheckStateRepo().Find(result.SubjectCheckID)
+	if len(prev.Escalations) != 2 {
+		t.Fatalf("expected 2 escalations, actual %v", prev.Escalations)
+	}
+	state := prev
+	state.Status, state.StatusChanged = model.StatusOK, time.Now()
+	state.Reminders, state.Escalations = map[string]time.Time{}, nil
+	crd := model.CheckResultDetail{
+		CheckResult: model.NewCheckResult(subject.ID, check.ID, time.Now(), model.StatusOK),
+		Subject:     subject,
+		Check:       check,
+	}
+	if err := ExecuteAlerts(crd, prev, state, ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	WaitForAlerts()
+	if actual := takeFired(); !reflect.DeepEqual(actual, []string{"/tier1", "/tier2"}) {
+		t.Errorf("recovery: expected [/tier1 /tier2], actual %v", actual)
+	}
+}
+
+func TestEscalateStateChangedWhileFiring(t *testing.T) {
+	conf := config.Configuration{ContextFactory: memory.InitStore()}
+	ctx, _ := conf.ContextFactory.Get()
+	subject := model.Subject{Name: "db1", Roles: []string{"db"}}
+	ctx.SubjectRepo().Create(&subject)
+	check := model.Check{Name: "Replication", Roles: []string{"db"}, Tags: []string{"mysql"}}
+	ctx.CheckRepo().Create(&check)
+
+	start := time.Now().Add(-time.Hour)
+	id := model.SubjectCheckID{SubjectID: subject.ID, CheckID: check.ID}
+	critical := model.CheckState{
+		ID:            id,
+		StatusChanged: start,
+		Updated:       start,
+		Status:        model.StatusCritical,
+		Roles:         subject.Roles,
+		Tags:          check.Tags,
+	}
+	// Each escalation records the given state, as if a result came in while
+	// the alert was firing.
+	var meanwhile model.CheckState
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx.CheckStateRepo().Upsert(meanwhile)
+	}))
+	defer server.Close()
+
+	tier2 := model.Alert{Name: "tier2", Type: model.AlertWebhook, Parameters: map[string]string{"url": server.URL}}
+	ctx.AlertRepo().Create(&tier2)
+	tier1 := model.Alert{
+		Name:       "tier1",
+		Type:       model.AlertWebhook,
+		Parameters: map[string]string{"url": server.URL},
+		Escalation: []model.EscalationStep{{AlertID: tier2.ID, Delay: 15}},
+		Roles:      []string{"db"},
+		Tags:       []string{"mysql"},
+	}
+	ctx.AlertRepo().Create(&tier1)
+	result := model.NewCheckResult(subject.ID, check.ID, start, model.StatusCritical)
+	ctx.CheckResultRepo().Create(&result)
+	critical.Reminders = map[string]time.Time{tier1.ID.String(): start}
+
+	// A recovery recorded while escalating is kept.
+	ctx.CheckStateRepo().Upsert(critical)
+	meanwhile = critical
+	meanwhile.Status, meanwhile.StatusChanged, meanwhile.Updated = model.StatusOK, time.Now(), time.Now()
+	meanwhile.Reminders = map[string]time.Time{}
+	if err := Escalate(ctx, conf, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := ctx.CheckStateRepo().Find(id); state.Status != model.StatusOK || len(state.Escalations) != 0 {
+		t.Errorf("expected recovered state without escalations, actual %+v", state)
+	}
+
+	// A result recorded while escalating is kept, along with the escalation.
+	ctx.CheckStateRepo().Upsert(critical)
+	meanwhile = critical
+	meanwhile.Updated = time.Now()
+	if err := Escalate(ctx, conf, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := ctx.CheckStateRepo().Find(id)
+	if !state.Updated.Equal(meanwhile.Updated) {
+		t.Errorf("expected state updated at %v, actual %v", meanwhile.Updated, state.Updated)
+	}
+	if _, ok := state.Escalations[tier2.ID.String()]; !ok {
+		t.Errorf("expected escalation to tier2 recorded, actual %v", state.Escalations)
+	}
+}
+
+func TestDueEscalationsSkipsFiredAlerts(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	tier1 := model.Alert{ID: utils.NewTimeUUID(), Name: "tier1"}
+	tier2 := model.Alert{ID: utils.NewTimeUUID(), Name: "tier2"}
+	manager := model.Alert{ID: utils.NewTimeUUID(), Name: "manager"}
+	tier1.Escalation = []model.EscalationStep{
+		{AlertID: tier1.ID, Delay: 5},
+		{AlertID: tier2.ID, Delay: 15},
+		{AlertID: manager.ID, Delay: 30},
+	}
+	// tier2 matches the check too, and has already fired for the problem.
+	state := model.CheckState{
+		StatusChanged: start,
+		Status:        model.StatusCritical,
+		Reminders:     map[string]time.Time{tier1.ID.String(): start, tier2.ID.String(): start},
+	}
+	due := dueEscalations(state, []model.Alert{tier1, tier2}, time.Now())
+	if len(due) != 1 || due[0].AlertID != manager.ID {
+		t.Errorf("expected only the manager step due, actual %v", due)
+	}
+}
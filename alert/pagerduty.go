@@ -0,0 +1,119 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/server/config"
+)
+
+const defaultPagerDutySummary = "{{.Status}}: {{.Subject.Name}} - {{.Check.Name}}"
+
+// pagerDutyEvent is an event sent to the PagerDuty Events API v2.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Client      string            `json:"client,omitempty"`
+}
+
+// pagerDutyPayload describes the problem which triggered an event.
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	Component     string                 `json:"component,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// pagerDutyDedupKey identifies the incident for a subject's check, so that
+// reminders update the open incident rather than raising new ones, and
+// recovery resolves it.
+func pagerDutyDedupKey(id model.SubjectCheckID) string {
+	return fmt.Sprintf("observatory/%s/%s", id.SubjectID, id.CheckID)
+}
+
+func pagerDutySeverity(status model.CheckStatus) string {
+	switch status {
+	case model.StatusCritical:
+		return "critical"
+	case model.StatusWarning:
+		return "warning"
+	case model.StatusFailed:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// newPagerDutyEvent builds the event for a check result: a resolve if the
+// check has recovered, or a trigger otherwise. Parameters
+// are:
+//
+//	service - the integration key of the PagerDuty service (required)
+//	subject - a template for the summary of the incident (default
+//	          "{{.Status}}: {{.Subject.Name}} - {{.Check.Name}}")
+//	body    - a template for the details of the incident (default the check
+//	          result's message)
+func newPagerDutyEvent(result model.CheckResultDetail, params map[string]string) (pagerDutyEvent, error) {
+	event := pagerDutyEvent{
+		RoutingKey: params["service"],
+		DedupKey:   pagerDutyDedupKey(result.SubjectCheckID),
+		Client:     "Observatory",
+	}
+	if event.RoutingKey == "" {
+		return event, fmt.Errorf("PagerDuty alert has no service")
+	}
+	if result.Recovered() {
+		event.EventAction = "resolve"
+		return event, nil
+	}
+	event.EventAction = "trigger"
+
+	summaryTemplate := params["subject"]
+	if summaryTemplate == "" {
+		summaryTemplate = defaultPagerDutySummary
+	}
+	summary, err := handleTextTemplate(summaryTemplate, result)
+	if err != nil {
+		return event, err
+	}
+	details := result.Message
+	if params["body"] != "" {
+		if details, err = handleTextTemplate(params["body"], result); err != nil {
+			return event, err
+		}
+	}
+	// PagerDuty rejects summaries over 1024 characters.
+	if len(summary) > 1024 {
+		summary = summary[:1021] + "..."
+	}
+	customDetails := map[string]interface{}{"details": details}
+	if len(result.Metrics) > 0 {
+		customDetails["metrics"] = result.Metrics
+	}
+	event.Payload = &pagerDutyPayload{
+		Summary:       summary,
+		Source:        result.Subject.Name,
+		Severity:      pagerDutySeverity(result.Status),
+		Timestamp:     result.Time.Format(time.RFC3339),
+		Component:     result.Check.Name,
+		CustomDetails: customDetails,
+	}
+	return event, nil
+}
+
+// executeAlertPagerDuty triggers a PagerDuty incident for a check in a problem
+// state, or resolves it when the check recovers. Reminders trigger the same
+// incident again, which PagerDuty merges into the open incident. See
+// newPagerDutyEvent for its parameters.
+func executeAlertPagerDuty(result model.CheckResultDetail, params map[string]string, conf config.Configuration) error {
+	event, err := newPagerDutyEvent(result, params)
+	if err != nil {
+		return err
+	}
+	return postJSON(conf.PagerDutyURL, event)
+}
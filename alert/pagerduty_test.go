@@ -0,0 +1,71 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/server/config"
+	"github.com/aprice/observatory/utils"
+)
+
+func TestExecuteAlertPagerDuty(t *testing.T) {
+	events := []pagerDutyEvent{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := pagerDutyEvent{}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil || event.RoutingKey == "invalid" {
+			http.Error(w, `{"status":"invalid event"}`, http.StatusBadRequest)
+			return
+		}
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	conf := config.Configuration{PagerDutyURL: server.URL}
+
+	crd := model.CheckResultDetail{
+		CheckResult: model.NewCheckResult(utils.NewTimeUUID(), utils.NewTimeUUID(), time.Now(), model.StatusCritical),
+		Subject:     model.Subject{Name: "db01"},
+		Check:       model.Check{Name: "Replication"},
+	}
+	crd.Message = "lag 300s"
+	crd.Metrics = map[string]float64{"lag_seconds": 300}
+
+	if err := executeAlertPagerDuty(crd, map[string]string{}, conf); err == nil {
+		t.Error("expected error without service")
+	}
+	if err := executeAlertPagerDuty(crd, map[string]string{"service": "invalid"}, conf); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected error for rejected event, actual %v", err)
+	}
+
+	params := map[string]string{"service": "key123"}
+	if err := executeAlertPagerDuty(crd, params, conf); err != nil {
+		t.Fatal(err)
+	}
+	crd.Status, crd.PreviousStatus = model.StatusOK, model.StatusCritical
+	if err := executeAlertPagerDuty(crd, params, conf); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, actual %d", len(events))
+	}
+
+	trigger, resolve := events[0], events[1]
+	if trigger.EventAction != "trigger" || trigger.RoutingKey != "key123" || trigger.Payload == nil {
+		t.Fatalf("expected trigger event, actual %+v", trigger)
+	}
+	if trigger.Payload.Summary != "Critical: db01 - Replication" || trigger.Payload.Severity != "critical" ||
+		trigger.Payload.Source != "db01" || trigger.Payload.CustomDetails["details"] != "lag 300s" {
+		t.Errorf("unexpected trigger payload %+v", trigger.Payload)
+	}
+	if resolve.EventAction != "resolve" || resolve.Payload != nil {
+		t.Errorf("expected resolve event without payload, actual %+v", resolve)
+	}
+	if trigger.DedupKey == "" || resolve.DedupKey != trigger.DedupKey {
+		t.Errorf("expected matching dedup keys, actual %q and %q", trigger.DedupKey, resolve.DedupKey)
+	}
+}
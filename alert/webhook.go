@@ -0,0 +1,276 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = time.Second
+	// maxWebhookErrorBytes limits how much of a failed response is included
+	// in the error.
+	maxWebhookErrorBytes = 512
+)
+
+// webhookTransport is used by all webhook alerts, so that connections are
+// reused between alerts.
+var webhookTransport http.RoundTripper = http.DefaultTransport
+
+// webhookOptions are the parsed parameters of a webhook alert.
+type webhookOptions struct {
+	URL      string
+	Method   string
+	Headers  string
+	Body     string
+	Timeout  time.Duration
+	Retries  int
+	Backoff  time.Duration
+	Statuses []utils.StatusRange
+}
+
+// parseWebhookOptions parses the parameters of a webhook alert:
+//
+//	url     - the URL to request (required)
+//	method  - the request method (default POST)
+//	headers - request headers, one "Name: value" per line
+//	body    - the request body (default a JSON description of the check result)
+//	timeout - the timeout of each attempt, such as "5s" (default 10s)
+//	retries - how many times to retry after a 5xx or 429 status, or a failure
+//	          to connect (default 3)
+//	backoff - how long to wait before the first retry, doubling for each
+//	          retry after (default 1s)
+//	status  - statuses which mean the alert was delivered, such as "200,202"
+//	          or "2xx" (default 2xx)
+//
+// The url, headers and body are templates, executed against the
+// CheckResultDetail with the extra function json, which formats a value as
+// JSON. Empty parameters are treated as unset.
+func parseWebhookOptions(params map[string]string) (webhookOptions, error) {
+	var err error
+	opts := webhookOptions{
+		URL:      params["url"],
+		Method:   http.MethodPost,
+		Headers:  params["headers"],
+		Body:     params["body"],
+		Timeout:  defaultWebhookTimeout,
+		Retries:  defaultWebhookRetries,
+		Backoff:  defaultWebhookBackoff,
+		Statuses: []utils.StatusRange{{Min: 200, Max: 299}},
+	}
+	if opts.URL == "" {
+		return opts, fmt.Errorf("webhook alert has no url")
+	}
+	if raw := params["method"]; raw != "" {
+		opts.Method = strings.ToUpper(raw)
+	}
+	durations := []struct {
+		param string
+		value *time.Duration
+	}{
+		{"timeout", &opts.Timeout},
+		{"backoff", &opts.Backoff},
+	}
+	for _, d := range durations {
+		if raw := params[d.param]; raw != "" {
+			if *d.value, err = time.ParseDuration(raw); err != nil {
+				return opts, err
+			}
+		}
+	}
+	if raw := params["retries"]; raw != "" {
+		if opts.Retries, err = strconv.Atoi(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["status"]; raw != "" {
+		if opts.Statuses, err = utils.ParseStatusRanges(raw); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// webhookPayload is the default body of a webhook alert.
+type webhookPayload struct {
+	Subject   string             `json:"subject"`
+	SubjectID string             `json:"subject_id"`
+	Check     string             `json:"check"`
+	CheckID   string             `json:"check_id"`
+	Status    string             `json:"status"`
+	Message   string             `json:"message"`
+	Time      time.Time          `json:"time"`
+	Metrics   map[string]float64 `json:"metrics,omitempty"`
+}
+
+func newWebhookPayload(result model.CheckResultDetail) webhookPayload {
+	return webhookPayload{
+		Subject:   result.Subject.Name,
+		SubjectID: result.SubjectID.String(),
+		Check:     result.Check.Name,
+		CheckID:   result.CheckID.String(),
+		Status:    result.Status.String(),
+		Message:   result.Message,
+		Time:      result.Time,
+		Metrics:   result.Metrics,
+	}
+}
+
+var textTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// handleTextTemplate executes a template for a webhook or other API. Unlike
+// email templates, values are not HTML escaped, so JSON and other formats can
+// be produced using the json function.
+func handleTextTemplate(templateText string, result model.CheckResultDetail) (string, error) {
+	tmpl, err := template.New("alert").Funcs(textTemplateFuncs).Parse(templateText)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err = tmpl.Execute(buf, result); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// webhookRequest is a rendered webhook alert, which can be sent repeatedly.
+type webhookRequest struct {
+	URL     string
+	Headers http.Header
+	Body    string
+}
+
+func renderWebhook(opts webhookOptions, result model.CheckResultDetail) (webhookRequest, error) {
+	var req webhookRequest
+	var err error
+	if req.URL, err = handleTextTemplate(opts.URL, result); err != nil {
+		return req, err
+	}
+	req.Headers = http.Header{}
+	if opts.Headers != "" {
+		headers, err := handleTextTemplate(opts.Headers, result)
+		if err != nil {
+			return req, err
+		}
+		if req.Headers, err = utils.ParseHeaders(headers); err != nil {
+			return req, err
+		}
+	}
+	if opts.Body == "" {
+		body, err := json.Marshal(newWebhookPayload(result))
+		if err != nil {
+			return req, err
+		}
+		req.Body = string(body)
+		if req.Headers.Get("Content-Type") == "" {
+			req.Headers.Set("Content-Type", "application/json")
+		}
+	} else if req.Body, err = handleTextTemplate(opts.Body, result); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// executeAlertWebhook sends an HTTP request describing the check result. See
+// parseWebhookOptions for its parameters.
+func executeAlertWebhook(result model.CheckResultDetail, params map[string]string) error {
+	opts, err := parseWebhookOptions(params)
+	if err != nil {
+		return err
+	}
+	req, err := renderWebhook(opts, result)
+	if err != nil {
+		return err
+	}
+	return deliverWebhook(opts, req)
+}
+
+// postJSON sends a value as JSON to an API, retrying as a webhook alert with
+// the default options would.
+func postJSON(url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	opts := webhookOptions{
+		Method:   http.MethodPost,
+		Timeout:  defaultWebhookTimeout,
+		Retries:  defaultWebhookRetries,
+		Backoff:  defaultWebhookBackoff,
+		Statuses: []utils.StatusRange{{Min: 200, Max: 299}},
+	}
+	req := webhookRequest{
+		URL:     url,
+		Headers: http.Header{"Content-Type": []string{"application/json"}},
+		Body:    string(body),
+	}
+	return deliverWebhook(opts, req)
+}
+
+// deliverWebhook sends a webhook request, retrying with backoff if the server
+// fails, is rate limiting, or cannot be reached.
+func deliverWebhook(opts webhookOptions, req webhookRequest) error {
+	client := &http.Client{Transport: webhookTransport, Timeout: opts.Timeout}
+	backoff := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := sendWebhook(client, opts, req)
+		if err == nil || !retry || attempt >= opts.Retries {
+			return err
+		}
+		log.Printf("Webhook to %s failed, retrying in %v: %v", req.URL, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// sendWebhook makes one attempt to deliver a webhook. It returns whether a
+// failed attempt may succeed if retried.
+func sendWebhook(client *http.Client, opts webhookOptions, wr webhookRequest) (bool, error) {
+	var body io.Reader
+	if wr.Body != "" {
+		body = strings.NewReader(wr.Body)
+	}
+	req, err := http.NewRequest(opts.Method, wr.URL, body)
+	if err != nil {
+		return false, err
+	}
+	for name, values := range wr.Headers {
+		if name == "Host" {
+			req.Host = values[0]
+			continue
+		}
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if utils.StatusExpected(opts.Statuses, resp.StatusCode) {
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookErrorBytes))
+	io.Copy(ioutil.Discard, resp.Body)
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook to %s returned status %d: %s",
+		wr.URL, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
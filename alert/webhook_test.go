@@ -0,0 +1,116 @@
+package alert
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+func TestExecuteAlertWebhook(t *testing.T) {
+	crd := model.CheckResultDetail{
+		CheckResult: model.CheckResult{
+			Time:    time.Now(),
+			Status:  model.StatusCritical,
+			Message: `disk "/" full`,
+		},
+		Subject: model.Subject{Name: "web01"},
+		Check:   model.Check{Name: "Disk"},
+	}
+
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+		bodies   []string
+		failures int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		switch r.URL.Path {
+		case "/flaky":
+			if failures < 2 {
+				failures++
+				http.Error(w, "try again", http.StatusServiceUnavailable)
+			}
+		case "/down":
+			http.Error(w, "down", http.StatusBadGateway)
+		case "/bad":
+			http.Error(w, "bad payload", http.StatusBadRequest)
+		case "/accepted":
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+	reset := func() {
+		mu.Lock()
+		requests, bodies, failures = nil, nil, 0
+		mu.Unlock()
+	}
+
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		attempts int
+		err      string
+	}{
+		{"no url", map[string]string{}, 0, "no url"},
+		{"bad retries", map[string]string{"url": server.URL, "retries": "many"}, 0, "invalid syntax"},
+		{"ok", map[string]string{"url": server.URL + "/ok"}, 1, ""},
+		{"retried", map[string]string{"url": server.URL + "/flaky", "backoff": "1ms"}, 3, ""},
+		{"retries exhausted", map[string]string{"url": server.URL + "/down", "retries": "2", "backoff": "1ms"}, 3, "status 502: down"},
+		{"client error", map[string]string{"url": server.URL + "/bad", "backoff": "1ms"}, 1, "status 400: bad payload"},
+		{"unexpected status", map[string]string{"url": server.URL + "/accepted", "status": "200"}, 1, "status 202"},
+	}
+	for _, tt := range tests {
+		reset()
+		err := executeAlertWebhook(crd, tt.params)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: expected error %q, actual %v", tt.name, tt.err, err)
+		}
+		if len(requests) != tt.attempts {
+			t.Errorf("%s: expected %d attempts, actual %d", tt.name, tt.attempts, len(requests))
+		}
+	}
+
+	// The default body describes the result as JSON.
+	reset()
+	if err := executeAlertWebhook(crd, map[string]string{"url": server.URL + "/ok"}); err != nil {
+		t.Fatal(err)
+	}
+	payload := webhookPayload{}
+	if err := json.Unmarshal([]byte(bodies[0]), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if requests[0].Method != http.MethodPost || requests[0].Header.Get("Content-Type") != "application/json" ||
+		payload.Subject != "web01" || payload.Check != "Disk" || payload.Status != "Critical" || payload.Message != crd.Message {
+		t.Errorf("default body: unexpected %s %v %s", requests[0].Method, requests[0].Header, bodies[0])
+	}
+
+	// Templates are not HTML escaped, and the json function quotes values.
+	reset()
+	err := executeAlertWebhook(crd, map[string]string{
+		"url":     server.URL + "/{{.Subject.Name}}",
+		"method":  "put",
+		"headers": "Content-Type: application/json\nX-Check: {{.Check.Name}}",
+		"body":    `{"text": {{json (printf "%s on %s: %s" .Status .Subject.Name .Message)}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"text": "Critical on web01: disk \"/\" full"}`
+	if requests[0].Method != http.MethodPut || requests[0].URL.Path != "/web01" ||
+		requests[0].Header.Get("X-Check") != "Disk" || bodies[0] != expected {
+		t.Errorf("templated: expected PUT /web01 %s, actual %s %s %v %s",
+			expected, requests[0].Method, requests[0].URL.Path, requests[0].Header, bodies[0])
+	}
+}
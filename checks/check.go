@@ -25,23 +25,33 @@ type checkConfig struct {
 	Active       bool
 }
 
-// Execute a check and return its exit code.
-func (cc checkConfig) Execute() (model.CheckStatus, error) {
+// Execute a check and return its output.
+func (cc checkConfig) Execute() (model.CheckOutput, error) {
 	switch cc.Check.Type {
 	case model.CheckExec:
 		return executeCheck(cc.SubjectID, cc.Check.Parameters)
 	case model.CheckHTTP:
-		return httpCheck(cc.SubjectID, cc.Check.Parameters)
+		return HTTPCheck(cc.Check.Parameters)
 	case model.CheckPort:
-		return portCheck(cc.SubjectID, cc.Check.Parameters)
+		return PortCheck(cc.Check.Parameters)
 	case model.CheckMemory:
 		return memCheck(cc.SubjectID, cc.Check.Parameters)
 	case model.CheckCPU:
 		return loadCheck(cc.SubjectID, cc.Check.Parameters)
 	case model.CheckDisk:
-		return diskCheck(cc.SubjectID, cc.Check.Parameters)
+		return diskCheck(cc.Check.ID, cc.Check.Parameters)
+	case model.CheckDNS:
+		return DNSCheck(cc.Check.Parameters)
+	case model.CheckProcess:
+		return processCheck(cc.Check.Parameters)
+	case model.CheckLog:
+		return logCheck(cc.Check.ID, cc.Check.Parameters)
+	case model.CheckFile:
+		return fileCheck(cc.Check.Parameters)
+	case model.CheckPrometheus:
+		return PrometheusCheck(cc.Check.ID.String(), cc.Check.Parameters)
 	default:
-		return model.StatusNone, nil
+		return model.CheckOutput{Status: model.StatusNone}, nil
 	}
 }
 
@@ -140,18 +150,20 @@ func repeatCheck(initialConfig checkConfig, control chan checkConfig) {
 
 func doCheck(config checkConfig) {
 	log.Printf("Executing check %s\n", config.Check.Name)
-	status, err := config.Execute()
+	start := time.Now()
+	output, err := config.Execute()
 	if err != nil {
 		log.Println(err)
 	} else {
-		result := model.NewCheckResult(config.SubjectID, config.Check.ID, time.Now(), status)
+		log.Printf("Check %s: %s", config.Check.Name, output.Message)
+		result := model.NewCheckResultFromOutput(config.SubjectID, config.Check.ID, time.Now(), output, time.Since(start))
 		// Record check result non-blocking.
 		go func() {
 			err = client.SendObject("POST", "/checkresults", config.Coordinators, result)
 			if err != nil {
 				log.Println(err)
 			} else {
-				log.Printf("Recorded %s result %d", config.Check.Name, status)
+				log.Printf("Recorded %s result %d", config.Check.Name, output.Status)
 			}
 		}()
 	}
@@ -1,12 +1,8 @@
 package checks
 
 import (
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"log"
-	"net"
-	"net/http"
 	"strconv"
 
 	sigar "github.com/cloudfoundry/gosigar"
@@ -16,136 +12,82 @@ import (
 	"github.com/aprice/observatory/utils"
 )
 
-func executeCheck(subjectID uuid.UUID, params map[string]string) (model.CheckStatus, error) {
+// executeCheck runs a command following the Nagios plugin contract: the exit
+// code gives the status, and the output gives the message and performance data.
+func executeCheck(subjectID uuid.UUID, params map[string]string) (model.CheckOutput, error) {
 	args := utils.StringToArgs(params["command"])
-	status, _, _ := utils.Execute(args...)
-	var checkStatus model.CheckStatus
-	if status == 0 {
-		checkStatus = model.StatusOK
-	} else if status == 1 {
-		checkStatus = model.StatusWarning
-	} else {
-		checkStatus = model.StatusCritical
+	if len(args) == 0 {
+		return model.CheckOutput{Status: model.StatusFailed}, errors.New("exec check has no command")
 	}
-	return checkStatus, nil
-}
-
-var checkClient = &http.Client{}
-
-func httpCheck(subjectID uuid.UUID, params map[string]string) (model.CheckStatus, error) {
-	req, err := http.NewRequest("GET", params["url"], nil)
-	if err != nil {
-		return model.StatusNone, err
-	}
-	resp, err := checkClient.Do(req)
-	if resp != nil {
-		defer func() {
-			io.Copy(ioutil.Discard, resp.Body)
-			resp.Body.Close()
-		}()
-	}
-	if err != nil || resp.StatusCode >= 400 {
-		return model.StatusCritical, err
+	exitCode, output, err := utils.Execute(args...)
+	if exitCode < 0 {
+		// The command could not be run at all.
+		out := model.CheckOutput{Status: model.StatusFailed}
+		if err != nil {
+			out.Message = err.Error()
+		}
+		return out, nil
 	}
-	return model.StatusOK, nil
+	out := model.CheckOutput{Status: nagiosStatus(exitCode)}
+	out.Message, out.Metrics = parseNagiosOutput(string(output))
+	return out, nil
 }
 
-func memCheck(subjectID uuid.UUID, params map[string]string) (model.CheckStatus, error) {
+func memCheck(subjectID uuid.UUID, params map[string]string) (model.CheckOutput, error) {
 	mem := sigar.Mem{}
 	swap := sigar.Swap{}
 	if err := mem.Get(); err != nil {
-		return model.StatusFailed, err
+		return model.CheckOutput{Status: model.StatusFailed}, err
 	}
 	if err := swap.Get(); err != nil {
-		return model.StatusFailed, err
+		return model.CheckOutput{Status: model.StatusFailed}, err
 	}
 	pctUsed := (float64(mem.Used) / float64(mem.Total)) * 100
-	pctSwap := (float64(swap.Used) / float64(swap.Total)) * 100
-	log.Printf("Memory: %s/%s (%.1f%%), Swap: %s/%s (%.1f%%)",
-		utils.HumanReadableBytesSI(int64(mem.Used), 3),
-		utils.HumanReadableBytesSI(int64(mem.Total), 3),
-		pctUsed,
-		utils.HumanReadableBytesSI(int64(swap.Used), 3),
-		utils.HumanReadableBytesSI(int64(swap.Total), 3),
-		pctSwap)
-	if tholdRaw, ok := params["usedcrit"]; ok {
-		thold, err := strconv.ParseFloat(tholdRaw, 64)
-		if err != nil {
-			return model.StatusFailed, err
-		}
-		if pctUsed > thold {
-			return model.StatusCritical, nil
-		}
-	}
-	if tholdRaw, ok := params["usedwarn"]; ok {
-		thold, err := strconv.ParseFloat(tholdRaw, 64)
-		if err != nil {
-			return model.StatusFailed, err
-		}
-		if pctUsed > thold {
-			return model.StatusWarning, nil
-		}
-	}
-	if tholdRaw, ok := params["swapcrit"]; ok {
-		thold, err := strconv.ParseFloat(tholdRaw, 64)
-		if err != nil {
-			return model.StatusFailed, err
-		}
-		if pctSwap > thold {
-			return model.StatusCritical, nil
+	pctSwap := 0.0
+	if swap.Total > 0 {
+		pctSwap = (float64(swap.Used) / float64(swap.Total)) * 100
+	}
+	out := model.CheckOutput{
+		Status: model.StatusOK,
+		Message: fmt.Sprintf("Memory: %s/%s (%.1f%%), Swap: %s/%s (%.1f%%)",
+			utils.HumanReadableBytesSI(int64(mem.Used), 3),
+			utils.HumanReadableBytesSI(int64(mem.Total), 3),
+			pctUsed,
+			utils.HumanReadableBytesSI(int64(swap.Used), 3),
+			utils.HumanReadableBytesSI(int64(swap.Total), 3),
+			pctSwap),
+		Metrics: map[string]float64{
+			"mem_used_pct":    pctUsed,
+			"mem_used_bytes":  float64(mem.Used),
+			"mem_total_bytes": float64(mem.Total),
+			"swap_used_pct":   pctSwap,
+			"swap_used_bytes": float64(swap.Used),
+		},
+	}
+	thresholds := []struct {
+		param  string
+		value  float64
+		status model.CheckStatus
+	}{
+		{"usedcrit", pctUsed, model.StatusCritical},
+		{"usedwarn", pctUsed, model.StatusWarning},
+		{"swapcrit", pctSwap, model.StatusCritical},
+		{"swapwarn", pctSwap, model.StatusWarning},
+	}
+	for _, th := range thresholds {
+		tholdRaw, ok := params[th.param]
+		if !ok {
+			continue
 		}
-	}
-	if tholdRaw, ok := params["swapwarn"]; ok {
 		thold, err := strconv.ParseFloat(tholdRaw, 64)
 		if err != nil {
-			return model.StatusFailed, err
+			out.Status = model.StatusFailed
+			return out, err
 		}
-		if pctSwap > thold {
-			return model.StatusWarning, nil
+		if th.value > thold {
+			out.Status = th.status
+			return out, nil
 		}
 	}
-	return model.StatusOK, nil
-}
-
-func diskCheck(subjectID uuid.UUID, params map[string]string) (model.CheckStatus, error) {
-	var err error
-	fsName := params["filesystem"]
-	acRaw := params["critical"]
-	awRaw := params["warning"]
-	ac, err := strconv.ParseFloat(acRaw, 64)
-	if err != nil {
-		return model.StatusFailed, err
-	}
-	aw, err := strconv.ParseFloat(awRaw, 64)
-	if err != nil {
-		return model.StatusFailed, err
-	}
-
-	usage := sigar.FileSystemUsage{}
-	err = usage.Get(fsName)
-	if err != nil {
-		return model.StatusFailed, err
-	}
-	usedPct := usage.UsePercent()
-	log.Printf("%s: %s/%s (%.1f%%)",
-		fsName,
-		utils.HumanReadableBytesSI(int64(usage.Used), 3),
-		utils.HumanReadableBytesSI(int64(usage.Total), 3),
-		usedPct)
-	if ac < usedPct {
-		return model.StatusCritical, nil
-	}
-	if aw < usedPct {
-		return model.StatusWarning, nil
-	}
-	return model.StatusOK, nil
-}
-
-func portCheck(subjectID uuid.UUID, params map[string]string) (model.CheckStatus, error) {
-	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%s", params["port"]))
-	if err != nil {
-		return model.StatusCritical, err
-	}
-	conn.Close()
-	return model.StatusOK, nil
+	return out, nil
 }
@@ -0,0 +1,283 @@
+package checks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sigar "github.com/cloudfoundry/gosigar"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/aprice/observatory/model"
+)
+
+const defaultCPUWindow = time.Second
+
+// cpuCheckOptions are the parsed parameters of a CPU check.
+type cpuCheckOptions struct {
+	Mode        string
+	Window      time.Duration
+	Warning     float64
+	Critical    float64
+	CoreWarn    float64
+	CoreCrit    float64
+	IOWaitWarn  float64
+	IOWaitCrit  float64
+	StealWarn   float64
+	StealCrit   float64
+	LoadWarn    [3]float64
+	LoadCrit    [3]float64
+	LoadPerCore bool
+}
+
+// parseCPUCheckOptions parses the parameters of a CPU check:
+//
+//	mode       - usage, to measure CPU use over a window, or load, to check the
+//	             1, 5 and 15 minute load averages (default usage)
+//	window     - how long CPU use is measured over, such as "5s" (default 1s)
+//	warning    - the percentage of CPU used above which the check is Warning
+//	critical   - the percentage of CPU used above which the check is Critical
+//	corewarn   - the percentage of any one core used above which the check is
+//	             Warning
+//	corecrit   - the percentage of any one core used above which the check is
+//	             Critical
+//	iowaitwarn - the percentage of CPU time waiting for I/O above which the
+//	             check is Warning
+//	iowaitcrit - the percentage of CPU time waiting for I/O above which the
+//	             check is Critical
+//	stealwarn  - the percentage of CPU time stolen by the hypervisor above
+//	             which the check is Warning
+//	stealcrit  - the percentage of CPU time stolen by the hypervisor above
+//	             which the check is Critical
+//	loadwarn   - the 1, 5 and 15 minute load averages above which the check is
+//	             Warning, such as "4,3,2"; a single value applies to all three
+//	loadcrit   - the load averages above which the check is Critical
+//	percore    - if "true", load averages are divided by the number of cores
+//	             before comparing them to the thresholds
+//
+// Empty parameters are treated as unset.
+func parseCPUCheckOptions(params map[string]string) (cpuCheckOptions, error) {
+	var err error
+	opts := cpuCheckOptions{
+		Mode:        strings.ToLower(params["mode"]),
+		Window:      defaultCPUWindow,
+		LoadPerCore: params["percore"] == "true",
+	}
+	switch opts.Mode {
+	case "":
+		opts.Mode = "usage"
+	case "usage", "load":
+	default:
+		return opts, fmt.Errorf("invalid mode: %q", opts.Mode)
+	}
+	if raw := params["window"]; raw != "" {
+		if opts.Window, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	thresholds := []struct {
+		param string
+		value *float64
+	}{
+		{"warning", &opts.Warning},
+		{"critical", &opts.Critical},
+		{"corewarn", &opts.CoreWarn},
+		{"corecrit", &opts.CoreCrit},
+		{"iowaitwarn", &opts.IOWaitWarn},
+		{"iowaitcrit", &opts.IOWaitCrit},
+		{"stealwarn", &opts.StealWarn},
+		{"stealcrit", &opts.StealCrit},
+	}
+	for _, th := range thresholds {
+		if raw := params[th.param]; raw != "" {
+			if *th.value, err = strconv.ParseFloat(raw, 64); err != nil {
+				return opts, err
+			}
+		}
+	}
+	if opts.LoadWarn, err = parseLoadThresholds(params["loadwarn"]); err != nil {
+		return opts, err
+	}
+	if opts.LoadCrit, err = parseLoadThresholds(params["loadcrit"]); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// parseLoadThresholds parses comma-separated 1, 5 and 15 minute load average
+// thresholds. A single value applies to all three, and an empty value is unset.
+func parseLoadThresholds(raw string) ([3]float64, error) {
+	var thresholds [3]float64
+	if raw == "" {
+		return thresholds, nil
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) != 1 && len(parts) != 3 {
+		return thresholds, fmt.Errorf("expected 1 or 3 load thresholds: %q", raw)
+	}
+	for i := range thresholds {
+		part := strings.TrimSpace(parts[i%len(parts)])
+		if part == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return thresholds, err
+		}
+		thresholds[i] = value
+	}
+	return thresholds, nil
+}
+
+// cpuUsage is the percentage of CPU time spent in each state over a window.
+type cpuUsage struct {
+	Used   float64
+	IOWait float64
+	Steal  float64
+}
+
+// cpuDelta returns the CPU usage between two samples of the cumulative CPU
+// counters. Time spent waiting for I/O is not counted as used.
+func cpuDelta(before, after sigar.Cpu) cpuUsage {
+	total := float64(counterDelta(before.Total(), after.Total()))
+	if total == 0 {
+		return cpuUsage{}
+	}
+	idle := float64(counterDelta(before.Idle, after.Idle))
+	wait := float64(counterDelta(before.Wait, after.Wait))
+	return cpuUsage{
+		Used:   100 * (total - idle - wait) / total,
+		IOWait: 100 * wait / total,
+		Steal:  100 * float64(counterDelta(before.Stolen, after.Stolen)) / total,
+	}
+}
+
+// counterDelta returns the increase in a counter, or zero if it went
+// backwards, which some kernels' iowait counters do.
+func counterDelta(before, after uint64) uint64 {
+	if after < before {
+		return 0
+	}
+	return after - before
+}
+
+// threshold is a value checked against optional warning and critical limits,
+// where a limit of zero is unset.
+type threshold struct {
+	Name  string
+	Unit  string
+	Value float64
+	Warn  float64
+	Crit  float64
+}
+
+// checkThresholds returns the worst status of the thresholds, and a
+// description of each limit exceeded.
+func checkThresholds(thresholds []threshold) (model.CheckStatus, []string) {
+	status := model.StatusOK
+	problems := []string{}
+	for _, th := range thresholds {
+		if th.Crit > 0 && th.Value > th.Crit {
+			status = model.StatusCritical
+			problems = append(problems, fmt.Sprintf("%s over %g%s", th.Name, th.Crit, th.Unit))
+		} else if th.Warn > 0 && th.Value > th.Warn {
+			if status < model.StatusWarning {
+				status = model.StatusWarning
+			}
+			problems = append(problems, fmt.Sprintf("%s over %g%s", th.Name, th.Warn, th.Unit))
+		}
+	}
+	return status, problems
+}
+
+// loadCheck checks CPU use or load averages. See parseCPUCheckOptions for its
+// parameters.
+func loadCheck(subjectID uuid.UUID, params map[string]string) (model.CheckOutput, error) {
+	opts, err := parseCPUCheckOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	if opts.Mode == "load" {
+		load, cores, err := getLoadAverage()
+		if err != nil {
+			return model.CheckOutput{Status: model.StatusFailed}, err
+		}
+		return loadAverageOutput(opts, load, cores), nil
+	}
+	total, cores, err := sampleCPU(opts.Window)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	return cpuUsageOutput(opts, total, cores), nil
+}
+
+// cpuUsageOutput checks CPU usage against the thresholds. Per-core usage may be
+// nil where the platform does not report it.
+func cpuUsageOutput(opts cpuCheckOptions, total cpuUsage, cores []cpuUsage) model.CheckOutput {
+	out := model.CheckOutput{
+		Message: fmt.Sprintf("CPU: %.1f%% used, %.1f%% iowait, %.1f%% steal over %v",
+			total.Used, total.IOWait, total.Steal, opts.Window),
+		Metrics: map[string]float64{
+			"cpu_used_pct":   total.Used,
+			"cpu_iowait_pct": total.IOWait,
+			"cpu_steal_pct":  total.Steal,
+		},
+	}
+	thresholds := []threshold{
+		{"CPU used", "%", total.Used, opts.Warning, opts.Critical},
+		{"iowait", "%", total.IOWait, opts.IOWaitWarn, opts.IOWaitCrit},
+		{"steal", "%", total.Steal, opts.StealWarn, opts.StealCrit},
+	}
+	if len(cores) > 0 {
+		busiest := 0
+		for i, core := range cores {
+			if core.Used > cores[busiest].Used {
+				busiest = i
+			}
+		}
+		out.Message += fmt.Sprintf(", busiest core %d %.1f%% used", busiest, cores[busiest].Used)
+		out.Metrics["cpu_core_max_pct"] = cores[busiest].Used
+		thresholds = append(thresholds, threshold{
+			fmt.Sprintf("core %d used", busiest), "%", cores[busiest].Used, opts.CoreWarn, opts.CoreCrit,
+		})
+	}
+	var problems []string
+	out.Status, problems = checkThresholds(thresholds)
+	if len(problems) > 0 {
+		out.Message += ": " + strings.Join(problems, ", ")
+	}
+	return out
+}
+
+// loadAverageOutput checks load averages against the thresholds.
+func loadAverageOutput(opts cpuCheckOptions, load sigar.LoadAverage, cores int) model.CheckOutput {
+	out := model.CheckOutput{
+		Message: fmt.Sprintf("Load average: %.2f, %.2f, %.2f", load.One, load.Five, load.Fifteen),
+		Metrics: map[string]float64{
+			"load1":  load.One,
+			"load5":  load.Five,
+			"load15": load.Fifteen,
+		},
+	}
+	values := [3]float64{load.One, load.Five, load.Fifteen}
+	unit := ""
+	if opts.LoadPerCore && cores > 0 {
+		for i := range values {
+			values[i] /= float64(cores)
+		}
+		out.Message += fmt.Sprintf(" on %d cores", cores)
+		unit = " per core"
+	}
+	names := [3]string{"1 minute load", "5 minute load", "15 minute load"}
+	thresholds := make([]threshold, len(values))
+	for i := range values {
+		thresholds[i] = threshold{names[i], unit, values[i], opts.LoadWarn[i], opts.LoadCrit[i]}
+	}
+	var problems []string
+	out.Status, problems = checkThresholds(thresholds)
+	if len(problems) > 0 {
+		out.Message += ": " + strings.Join(problems, ", ")
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package checks
+
+import (
+	"strings"
+	"testing"
+
+	sigar "github.com/cloudfoundry/gosigar"
+
+	"github.com/aprice/observatory/model"
+)
+
+func TestParseLoadThresholds(t *testing.T) {
+	var tests = []struct {
+		raw      string
+		expected [3]float64
+		err      bool
+	}{
+		{"", [3]float64{}, false},
+		{"4", [3]float64{4, 4, 4}, false},
+		{"4, 3,2.5", [3]float64{4, 3, 2.5}, false},
+		{",,2", [3]float64{0, 0, 2}, false},
+		{"4,3", [3]float64{}, true},
+		{"high", [3]float64{}, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := parseLoadThresholds(tt.raw)
+		if (err != nil) != tt.err || (!tt.err && actual != tt.expected) {
+			t.Errorf("parseLoadThresholds(%q): expected %v (error %v), actual %v, %v", tt.raw, tt.expected, tt.err, actual, err)
+		}
+	}
+}
+
+func TestCPUDelta(t *testing.T) {
+	before := sigar.Cpu{User: 100, Sys: 50, Idle: 1000, Wait: 20, Stolen: 5}
+	after := sigar.Cpu{User: 150, Sys: 60, Idle: 1020, Wait: 30, Stolen: 15}
+	expected := cpuUsage{Used: 70, IOWait: 10, Steal: 10}
+	if actual := cpuDelta(before, after); actual != expected {
+		t.Errorf("expected %v, actual %v", expected, actual)
+	}
+	// A counter going backwards must not underflow.
+	after.Wait = 10
+	if actual := cpuDelta(before, after); actual.IOWait != 0 || actual.Used < 0 || actual.Used > 100 {
+		t.Errorf("expected no iowait, actual %v", actual)
+	}
+	if actual := cpuDelta(before, before); actual != (cpuUsage{}) {
+		t.Errorf("expected no usage, actual %v", actual)
+	}
+}
+
+func TestCPUUsageOutput(t *testing.T) {
+	total := cpuUsage{Used: 50, IOWait: 25, Steal: 5}
+	cores := []cpuUsage{{Used: 20}, {Used: 95}}
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		expected model.CheckStatus
+		message  string
+	}{
+		{"no thresholds", map[string]string{}, model.StatusOK, "busiest core 1 95.0% used"},
+		{"below", map[string]string{"warning": "60", "critical": "90"}, model.StatusOK, "50.0% used"},
+		// The warning threshold must not be treated as the critical one.
+		{"warning", map[string]string{"warning": "40", "critical": "90"}, model.StatusWarning, "CPU used over 40%"},
+		{"critical", map[string]string{"warning": "20", "critical": "40"}, model.StatusCritical, "CPU used over 40%"},
+		{"core", map[string]string{"corewarn": "80", "corecrit": "99"}, model.StatusWarning, "core 1 used over 80%"},
+		{"iowait", map[string]string{"iowaitwarn": "10", "iowaitcrit": "20"}, model.StatusCritical, "iowait over 20%"},
+		{"steal", map[string]string{"stealwarn": "1", "critical": "99"}, model.StatusWarning, "steal over 1%"},
+	}
+
+	for _, tt := range tests {
+		opts, err := parseCPUCheckOptions(tt.params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		out := cpuUsageOutput(opts, total, cores)
+		if out.Status != tt.expected || !strings.Contains(out.Message, tt.message) {
+			t.Errorf("%s: expected %v containing %q, actual %v %q", tt.name, tt.expected, tt.message, out.Status, out.Message)
+		}
+	}
+}
+
+func TestLoadAverageOutput(t *testing.T) {
+	load := sigar.LoadAverage{One: 6, Five: 3, Fifteen: 1}
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		expected model.CheckStatus
+		message  string
+	}{
+		{"no thresholds", map[string]string{}, model.StatusOK, "Load average: 6.00, 3.00, 1.00"},
+		{"warning", map[string]string{"loadwarn": "5,4,3", "loadcrit": "10,8,6"}, model.StatusWarning, "1 minute load over 5"},
+		{"critical", map[string]string{"loadwarn": "2", "loadcrit": ",2.5,"}, model.StatusCritical, "5 minute load over 2.5"},
+		{"per core", map[string]string{"loadwarn": "1", "percore": "true"}, model.StatusWarning, "1 minute load over 1 per core"},
+	}
+
+	for _, tt := range tests {
+		opts, err := parseCPUCheckOptions(tt.params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		out := loadAverageOutput(opts, load, 4)
+		if out.Status != tt.expected || !strings.Contains(out.Message, tt.message) {
+			t.Errorf("%s: expected %v containing %q, actual %v %q", tt.name, tt.expected, tt.message, out.Status, out.Message)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sigar "github.com/cloudfoundry/gosigar"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+const defaultGrowthWindow = time.Hour
+
+// diskCheckOptions are the parsed parameters of a disk check.
+type diskCheckOptions struct {
+	Filesystem   string
+	Include      *regexp.Regexp
+	Exclude      *regexp.Regexp
+	FSTypes      []string
+	ExcludeTypes []string
+	Warning      float64
+	Critical     float64
+	InodeWarn    float64
+	InodeCrit    float64
+	FreeWarn     int64
+	FreeCrit     int64
+	FullWarn     time.Duration
+	FullCrit     time.Duration
+	GrowthWindow time.Duration
+}
+
+// parseDiskCheckOptions parses the parameters of a disk check:
+//
+//	filesystem    - the mount point or drive to check (default: every mounted
+//	                filesystem)
+//	include       - a regular expression mount points must match to be checked
+//	exclude       - a regular expression for mount points not to check
+//	fstype        - comma-separated filesystem types to check, such as
+//	                "ext4,xfs"
+//	excludefstype - comma-separated filesystem types not to check
+//	warning       - the percentage of space used above which the check is
+//	                Warning
+//	critical      - the percentage of space used above which the check is
+//	                Critical
+//	inodewarn     - the percentage of inodes used above which the check is
+//	                Warning
+//	inodecrit     - the percentage of inodes used above which the check is
+//	                Critical
+//	freewarn      - the free space below which the check is Warning, such as
+//	                "10G"
+//	freecrit      - the free space below which the check is Critical
+//	fullwarn      - the time within which the filesystem is predicted to be
+//	                full, at the current fill rate, for the check to be Warning,
+//	                such as "24h"
+//	fullcrit      - the predicted time to full for the check to be Critical
+//	growthwindow  - how far back the fill rate is measured over (default 1h)
+//
+// Empty parameters are treated as unset.
+func parseDiskCheckOptions(params map[string]string) (diskCheckOptions, error) {
+	var err error
+	opts := diskCheckOptions{
+		Filesystem:   params["filesystem"],
+		FSTypes:      splitList(params["fstype"]),
+		ExcludeTypes: splitList(params["excludefstype"]),
+		GrowthWindow: defaultGrowthWindow,
+	}
+	if raw := params["include"]; raw != "" {
+		if opts.Include, err = regexp.Compile(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["exclude"]; raw != "" {
+		if opts.Exclude, err = regexp.Compile(raw); err != nil {
+			return opts, err
+		}
+	}
+	percentages := []struct {
+		param string
+		value *float64
+	}{
+		{"warning", &opts.Warning},
+		{"critical", &opts.Critical},
+		{"inodewarn", &opts.InodeWarn},
+		{"inodecrit", &opts.InodeCrit},
+	}
+	for _, th := range percentages {
+		if raw := params[th.param]; raw != "" {
+			if *th.value, err = strconv.ParseFloat(raw, 64); err != nil {
+				return opts, err
+			}
+		}
+	}
+	if raw := params["freewarn"]; raw != "" {
+		if opts.FreeWarn, err = utils.ParseBytes(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["freecrit"]; raw != "" {
+		if opts.FreeCrit, err = utils.ParseBytes(raw); err != nil {
+			return opts, err
+		}
+	}
+	durations := []struct {
+		param string
+		value *time.Duration
+	}{
+		{"fullwarn", &opts.FullWarn},
+		{"fullcrit", &opts.FullCrit},
+		{"growthwindow", &opts.GrowthWindow},
+	}
+	for _, d := range durations {
+		if raw := params[d.param]; raw != "" {
+			if *d.value, err = time.ParseDuration(raw); err != nil {
+				return opts, err
+			}
+		}
+	}
+	return opts, nil
+}
+
+// splitList splits a comma-separated list, ignoring blank entries.
+func splitList(raw string) []string {
+	list := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Selects reports whether a mounted filesystem should be checked.
+func (o diskCheckOptions) Selects(fs sigar.FileSystem) bool {
+	if o.Include != nil && !o.Include.MatchString(fs.DirName) {
+		return false
+	}
+	if o.Exclude != nil && o.Exclude.MatchString(fs.DirName) {
+		return false
+	}
+	if len(o.FSTypes) > 0 && !containsString(o.FSTypes, fs.SysTypeName) {
+		return false
+	}
+	return !containsString(o.ExcludeTypes, fs.SysTypeName)
+}
+
+// diskUsage is the usage of a single mounted filesystem.
+type diskUsage struct {
+	Mount string
+	sigar.FileSystemUsage
+}
+
+// diskSample is the space used on a filesystem at a point in time.
+type diskSample struct {
+	Time time.Time
+	Used uint64
+}
+
+// diskHistory holds recent samples of each filesystem checked, to measure how
+// quickly it is filling up.
+type diskHistory struct {
+	sync.Mutex
+	samples map[string][]diskSample
+}
+
+var diskHistories = &diskHistory{samples: make(map[string][]diskSample)}
+
+// Record adds a sample for the key, discards samples older than the window,
+// and returns the fill rate in bytes per second over the remaining samples. It
+// returns false if there are not yet enough samples.
+func (h *diskHistory) Record(key string, sample diskSample, window time.Duration) (float64, bool) {
+	h.Lock()
+	defer h.Unlock()
+	samples := append(h.samples[key], sample)
+	for len(samples) > 1 && sample.Time.Sub(samples[0].Time) > window {
+		samples = samples[1:]
+	}
+	h.samples[key] = samples
+	oldest := samples[0]
+	elapsed := sample.Time.Sub(oldest.Time).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return (float64(sample.Used) - float64(oldest.Used)) / elapsed, true
+}
+
+// diskCheck checks the space and inodes used on one or all mounted
+// filesystems, and predicts when they will be full. See parseDiskCheckOptions
+// for its parameters.
+func diskCheck(checkID uuid.UUID, params map[string]string) (model.CheckOutput, error) {
+	opts, err := parseDiskCheckOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+
+	var mounts []string
+	if opts.Filesystem != "" {
+		mounts = []string{opts.Filesystem}
+	} else {
+		fsList := sigar.FileSystemList{}
+		if err = fsList.Get(); err != nil {
+			return model.CheckOutput{Status: model.StatusFailed}, err
+		}
+		for _, fs := range fsList.List {
+			if opts.Selects(fs) && !containsString(mounts, fs.DirName) {
+				mounts = append(mounts, fs.DirName)
+			}
+		}
+	}
+
+	usages := []diskUsage{}
+	for _, mount := range mounts {
+		usage := sigar.FileSystemUsage{}
+		if err = usage.Get(mount); err != nil {
+			if opts.Filesystem != "" {
+				return model.CheckOutput{Status: model.StatusFailed}, err
+			}
+			// Filesystems may be unmounted, or unreadable by the agent.
+			continue
+		}
+		// Pseudo-filesystems such as proc and sysfs have no space.
+		if usage.Total > 0 || opts.Filesystem != "" {
+			usages = append(usages, diskUsage{mount, usage})
+		}
+	}
+	return diskOutput(opts, checkID.String(), usages, time.Now()), nil
+}
+
+// diskOutput checks the usage of each filesystem against the thresholds. When
+// more than one filesystem is checked, metric names are suffixed with a colon
+// and the mount point, such as "used_pct:/var".
+func diskOutput(opts diskCheckOptions, historyKey string, usages []diskUsage, now time.Time) model.CheckOutput {
+	out := model.CheckOutput{Status: model.StatusOK, Metrics: map[string]float64{}}
+	descriptions := make([]string, len(usages))
+	problems := []string{}
+	for i, usage := range usages {
+		suffix := ""
+		if len(usages) > 1 || opts.Filesystem == "" {
+			suffix = ":" + usage.Mount
+		}
+		// sigar reports filesystem usage in kilobytes.
+		used := usage.Used * 1024
+		total := usage.Total * 1024
+		free := usage.Avail * 1024
+		usedPct := usage.UsePercent()
+		descriptions[i] = fmt.Sprintf("%s: %s/%s (%.1f%%)",
+			usage.Mount,
+			utils.HumanReadableBytesSI(int64(used), 3),
+			utils.HumanReadableBytesSI(int64(total), 3),
+			usedPct)
+		out.Metrics["used_pct"+suffix] = usedPct
+		out.Metrics["used_bytes"+suffix] = float64(used)
+		out.Metrics["total_bytes"+suffix] = float64(total)
+		out.Metrics["free_bytes"+suffix] = float64(free)
+
+		thresholds := []threshold{{usage.Mount + " used", "%", usedPct, opts.Warning, opts.Critical}}
+		if usage.Files > 0 {
+			inodePct := 100 * float64(usage.Files-usage.FreeFiles) / float64(usage.Files)
+			out.Metrics["inode_used_pct"+suffix] = inodePct
+			thresholds = append(thresholds, threshold{usage.Mount + " inodes used", "%", inodePct, opts.InodeWarn, opts.InodeCrit})
+		}
+		status, mountProblems := checkThresholds(thresholds)
+
+		if opts.FreeCrit > 0 && free < uint64(opts.FreeCrit) {
+			status = model.StatusCritical
+			mountProblems = append(mountProblems, fmt.Sprintf("%s free under %s", usage.Mount, utils.HumanReadableBytesSI(opts.FreeCrit, 3)))
+		} else if opts.FreeWarn > 0 && free < uint64(opts.FreeWarn) {
+			status = worseStatus(status, model.StatusWarning)
+			mountProblems = append(mountProblems, fmt.Sprintf("%s free under %s", usage.Mount, utils.HumanReadableBytesSI(opts.FreeWarn, 3)))
+		}
+
+		rate, ok := diskHistories.Record(historyKey+" "+usage.Mount, diskSample{now, used}, opts.GrowthWindow)
+		if ok {
+			out.Metrics["fill_rate_bytes_per_sec"+suffix] = rate
+		}
+		if ok && rate > 0 {
+			toFull := time.Duration(float64(free) / rate * float64(time.Second))
+			out.Metrics["hours_to_full"+suffix] = toFull.Hours()
+			if opts.FullCrit > 0 && toFull < opts.FullCrit {
+				status = model.StatusCritical
+				mountProblems = append(mountProblems, fmt.Sprintf("%s full in %v", usage.Mount, toFull.Truncate(time.Minute)))
+			} else if opts.FullWarn > 0 && toFull < opts.FullWarn {
+				status = worseStatus(status, model.StatusWarning)
+				mountProblems = append(mountProblems, fmt.Sprintf("%s full in %v", usage.Mount, toFull.Truncate(time.Minute)))
+			}
+		}
+
+		out.Status = worseStatus(out.Status, status)
+		problems = append(problems, mountProblems...)
+	}
+
+	out.Message = strings.Join(descriptions, ", ")
+	if len(usages) != 1 {
+		out.Message = fmt.Sprintf("%d filesystems", len(usages))
+		if len(usages) > 0 {
+			out.Message += ": " + strings.Join(descriptions, ", ")
+		}
+	}
+	if len(problems) > 0 {
+		out.Message += "; " + strings.Join(problems, ", ")
+	}
+	return out
+}
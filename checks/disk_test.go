@@ -0,0 +1,149 @@
+package checks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	sigar "github.com/cloudfoundry/gosigar"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+func TestDiskCheckOptionsSelects(t *testing.T) {
+	opts, err := parseDiskCheckOptions(map[string]string{
+		"exclude":       "^/(proc|sys)",
+		"excludefstype": "tmpfs, squashfs",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		fs       sigar.FileSystem
+		expected bool
+	}{
+		{sigar.FileSystem{DirName: "/", SysTypeName: "ext4"}, true},
+		{sigar.FileSystem{DirName: "/sys/fs/cgroup", SysTypeName: "cgroup"}, false},
+		{sigar.FileSystem{DirName: "/run", SysTypeName: "tmpfs"}, false},
+		{sigar.FileSystem{DirName: "/snap/core/1", SysTypeName: "squashfs"}, false},
+	}
+	for _, tt := range tests {
+		if actual := opts.Selects(tt.fs); actual != tt.expected {
+			t.Errorf("Selects(%v): expected %v, actual %v", tt.fs, tt.expected, actual)
+		}
+	}
+
+	opts, _ = parseDiskCheckOptions(map[string]string{"include": "^/data", "fstype": "xfs"})
+	if !opts.Selects(sigar.FileSystem{DirName: "/data/1", SysTypeName: "xfs"}) ||
+		opts.Selects(sigar.FileSystem{DirName: "/data/2", SysTypeName: "ext4"}) ||
+		opts.Selects(sigar.FileSystem{DirName: "/", SysTypeName: "xfs"}) {
+		t.Errorf("expected only xfs filesystems under /data to be selected")
+	}
+}
+
+func TestDiskOutput(t *testing.T) {
+	const gib = 1024 * 1024 // in KiB, as reported by sigar
+	root := diskUsage{"/", sigar.FileSystemUsage{Total: 100 * gib, Used: 50 * gib, Free: 50 * gib, Avail: 50 * gib, Files: 1000, FreeFiles: 100}}
+	data := diskUsage{"/data", sigar.FileSystemUsage{Total: 100 * gib, Used: 95 * gib, Free: 5 * gib, Avail: 5 * gib}}
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		usages   []diskUsage
+		expected model.CheckStatus
+		message  string
+	}{
+		{"single", map[string]string{"filesystem": "/", "warning": "60", "critical": "90"}, []diskUsage{root}, model.StatusOK, "/: 50.0GiB/100GiB (50.0%)"},
+		{"used warning", map[string]string{"filesystem": "/", "warning": "40", "critical": "90"}, []diskUsage{root}, model.StatusWarning, "/ used over 40%"},
+		{"inodes", map[string]string{"filesystem": "/", "inodewarn": "80", "inodecrit": "85"}, []diskUsage{root}, model.StatusCritical, "/ inodes used over 85%"},
+		{"free", map[string]string{"freewarn": "10G", "freecrit": "1G"}, []diskUsage{root, data}, model.StatusWarning, "/data free under 10.0GiB"},
+		{"all", map[string]string{"warning": "80", "critical": "90"}, []diskUsage{root, data}, model.StatusCritical, "2 filesystems: /: 50.0GiB/100GiB (50.0%), /data: 95.0GiB/100GiB (95.0%); /data used over 90%"},
+		{"none", map[string]string{}, []diskUsage{}, model.StatusOK, "0 filesystems"},
+	}
+
+	for _, tt := range tests {
+		opts, err := parseDiskCheckOptions(tt.params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		out := diskOutput(opts, tt.name, tt.usages, time.Now())
+		if out.Status != tt.expected || !strings.Contains(out.Message, tt.message) {
+			t.Errorf("%s: expected %v containing %q, actual %v %q", tt.name, tt.expected, tt.message, out.Status, out.Message)
+		}
+	}
+
+	out := diskOutput(parseDiskCheckOptionsOrFail(t, map[string]string{}), "metrics", []diskUsage{root, data}, time.Now())
+	if out.Metrics["used_pct:/data"] != 95 || out.Metrics["free_bytes:/"] != 50*1024*1024*1024 {
+		t.Errorf("expected metrics for each filesystem, actual %v", out.Metrics)
+	}
+}
+
+func parseDiskCheckOptionsOrFail(t *testing.T, params map[string]string) diskCheckOptions {
+	opts, err := parseDiskCheckOptions(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestDiskOutputGrowth(t *testing.T) {
+	const gib = 1024 * 1024
+	opts := parseDiskCheckOptionsOrFail(t, map[string]string{"filesystem": "/", "fullwarn": "24h", "fullcrit": "2h"})
+	start := time.Now()
+	usage := diskUsage{"/", sigar.FileSystemUsage{Total: 100 * gib, Used: 50 * gib, Free: 50 * gib, Avail: 50 * gib}}
+
+	// The first sample gives no fill rate.
+	out := diskOutput(opts, "growth", []diskUsage{usage}, start)
+	if out.Status != model.StatusOK {
+		t.Errorf("expected OK, actual %v %q", out.Status, out.Message)
+	}
+	if _, ok := out.Metrics["hours_to_full"]; ok {
+		t.Errorf("expected no prediction from one sample, actual %v", out.Metrics)
+	}
+
+	// 5GiB an hour leaves 45GiB for 9 hours.
+	usage.Used, usage.Avail = 55*gib, 45*gib
+	out = diskOutput(opts, "growth", []diskUsage{usage}, start.Add(time.Hour))
+	if out.Status != model.StatusWarning || !strings.Contains(out.Message, "/ full in 9h0m0s") {
+		t.Errorf("expected Warning, actual %v %q", out.Status, out.Message)
+	}
+
+	// 40GiB in the last hour, measured over the default one hour window,
+	// leaves 5GiB for 7.5 minutes.
+	usage.Used, usage.Avail = 95*gib, 5*gib
+	out = diskOutput(opts, "growth", []diskUsage{usage}, start.Add(2*time.Hour))
+	if out.Status != model.StatusCritical || !strings.Contains(out.Message, "/ full in 7m0s") {
+		t.Errorf("expected Critical, actual %v %q", out.Status, out.Message)
+	}
+
+	// Shrinking filesystems are never predicted to fill.
+	usage.Used, usage.Avail = 10*gib, 90*gib
+	out = diskOutput(opts, "growth", []diskUsage{usage}, start.Add(3*time.Hour))
+	if out.Status != model.StatusOK || out.Metrics["fill_rate_bytes_per_sec"] >= 0 {
+		t.Errorf("expected OK with negative fill rate, actual %v %q %v", out.Status, out.Message, out.Metrics)
+	}
+}
+
+func TestDiskCheck(t *testing.T) {
+	out, err := diskCheck(utils.NewTimeUUID(), map[string]string{"filesystem": "/", "warning": "", "critical": "100"})
+	if err != nil || out.Status != model.StatusOK || out.Metrics["total_bytes"] <= 0 {
+		t.Errorf("expected OK, actual %v %q %v", out.Status, out.Message, err)
+	}
+	out, err = diskCheck(utils.NewTimeUUID(), map[string]string{})
+	if err != nil || out.Status != model.StatusOK || !strings.Contains(out.Message, "filesystems") {
+		t.Errorf("expected OK, actual %v %q %v", out.Status, out.Message, err)
+	}
+
+	for _, params := range []map[string]string{
+		{"filesystem": "/no/such/filesystem"},
+		{"warning": "most"},
+		{"freewarn": "lots"},
+		{"fullwarn": "soon"},
+		{"exclude": "("},
+	} {
+		if out, err := diskCheck(utils.NewTimeUUID(), params); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("diskCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
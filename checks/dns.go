@@ -0,0 +1,229 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+const defaultDNSTimeout = 5 * time.Second
+
+// dnsCheckOptions are the parsed parameters of a DNS check.
+type dnsCheckOptions struct {
+	Name        string
+	Type        string
+	Resolver    string
+	Expect      []string
+	Regex       *regexp.Regexp
+	Timeout     time.Duration
+	LatencyWarn time.Duration
+}
+
+// parseDNSCheckOptions parses the parameters of a DNS check:
+//
+//	name        - the record name to look up (required)
+//	type        - the record type: A, AAAA, CNAME, MX, TXT, or SRV (default A)
+//	resolver    - the address of the DNS server to query, such as 8.8.8.8 or
+//	              10.0.0.2:5353 (default the system resolver)
+//	expect      - comma-separated values which must all be in the answer
+//	regex       - a regular expression every value in the answer must match
+//	timeout     - the lookup timeout, such as "2s" (default 5s)
+//	latencywarn - the lookup time above which the check is Warning
+//
+// Empty parameters are treated as unset.
+func parseDNSCheckOptions(params map[string]string) (dnsCheckOptions, error) {
+	var err error
+	opts := dnsCheckOptions{
+		Name:    params["name"],
+		Type:    strings.ToUpper(params["type"]),
+		Timeout: defaultDNSTimeout,
+	}
+	if opts.Name == "" {
+		return opts, fmt.Errorf("DNS check has no name")
+	}
+	switch opts.Type {
+	case "":
+		opts.Type = "A"
+	case "A", "AAAA", "CNAME", "MX", "TXT", "SRV":
+	default:
+		return opts, fmt.Errorf("unsupported record type: %q", opts.Type)
+	}
+	if raw := params["resolver"]; raw != "" {
+		opts.Resolver = raw
+		if _, _, err = net.SplitHostPort(raw); err != nil {
+			opts.Resolver = net.JoinHostPort(raw, "53")
+		}
+	}
+	if raw := params["expect"]; raw != "" {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				opts.Expect = append(opts.Expect, normalizeDNSValue(opts.Type, value))
+			}
+		}
+	}
+	if raw := params["regex"]; raw != "" {
+		if opts.Regex, err = regexp.Compile(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["timeout"]; raw != "" {
+		if opts.Timeout, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["latencywarn"]; raw != "" {
+		if opts.LatencyWarn, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// DNSCheck looks up a DNS record and checks the answer. It is Critical if the
+// name does not exist or the answer does not match, and Warning if the lookup
+// is slow. See parseDNSCheckOptions for its parameters.
+func DNSCheck(params map[string]string) (model.CheckOutput, error) {
+	opts, err := parseDNSCheckOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	resolver := net.DefaultResolver
+	if opts.Resolver != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, opts.Resolver)
+			},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	start := time.Now()
+	values, err := lookupDNS(ctx, resolver, opts.Type, opts.Name)
+	latency := time.Since(start)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+	}
+	out := model.CheckOutput{
+		Status: model.StatusOK,
+		Message: fmt.Sprintf("%s %s: %s in %v",
+			opts.Name, opts.Type, strings.Join(values, ", "), latency),
+		Metrics: map[string]float64{
+			"lookup_ms": latency.Seconds() * 1000,
+			"answers":   float64(len(values)),
+		},
+	}
+	problems := []string{}
+	if len(values) == 0 {
+		out.Status = model.StatusCritical
+		problems = append(problems, "no records")
+	}
+	for _, expected := range opts.Expect {
+		if !containsString(values, expected) {
+			out.Status = model.StatusCritical
+			problems = append(problems, fmt.Sprintf("missing %s", expected))
+		}
+	}
+	if opts.Regex != nil {
+		for _, value := range values {
+			if !opts.Regex.MatchString(value) {
+				out.Status = model.StatusCritical
+				problems = append(problems, fmt.Sprintf("%s does not match %q", value, opts.Regex.String()))
+			}
+		}
+	}
+	if out.Status == model.StatusOK && opts.LatencyWarn > 0 && latency > opts.LatencyWarn {
+		out.Status = model.StatusWarning
+		problems = append(problems, fmt.Sprintf("lookup time over %v", opts.LatencyWarn))
+	}
+	if len(problems) > 0 {
+		out.Message += ": " + strings.Join(problems, ", ")
+	}
+	return out, nil
+}
+
+// lookupDNS returns the normalized, sorted values of the records of the given
+// type. MX and SRV records are given as their target host, and SRV records
+// include the port.
+func lookupDNS(ctx context.Context, resolver *net.Resolver, recordType, name string) ([]string, error) {
+	values := []string{}
+	switch recordType {
+	case "A", "AAAA":
+		addrs, err := resolver.LookupIPAddr(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if (addr.IP.To4() != nil) == (recordType == "A") {
+				values = append(values, addr.IP.String())
+			}
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, cname)
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			values = append(values, mx.Host)
+		}
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, txts...)
+	case "SRV":
+		_, srvs, err := resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		for _, srv := range srvs {
+			values = append(values, net.JoinHostPort(srv.Target, strconv.Itoa(int(srv.Port))))
+		}
+	}
+	for i, value := range values {
+		values[i] = normalizeDNSValue(recordType, value)
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// normalizeDNSValue makes values comparable regardless of case or trailing
+// dots on names. TXT values are left untouched.
+func normalizeDNSValue(recordType, value string) string {
+	if recordType == "TXT" {
+		return value
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		return ip.String()
+	}
+	value = strings.ToLower(value)
+	if host, port, err := net.SplitHostPort(value); err == nil && recordType == "SRV" {
+		return net.JoinHostPort(strings.TrimSuffix(host, "."), port)
+	}
+	return strings.TrimSuffix(value, ".")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
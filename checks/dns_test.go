@@ -0,0 +1,121 @@
+package checks
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/aprice/observatory/model"
+)
+
+// serveDNS answers A queries for the given names over UDP, and NXDOMAIN for any
+// other name. It returns the address of the server.
+func serveDNS(t *testing.T, records map[string]net.IP) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := buf[:n]
+			// Find the end of the question: the name's labels, type and class.
+			end := 12
+			labels := []string{}
+			for end < n && query[end] != 0 {
+				labels = append(labels, string(query[end+1:end+1+int(query[end])]))
+				end += int(query[end]) + 1
+			}
+			end += 5
+			if end > n {
+				continue
+			}
+			qtype := binary.BigEndian.Uint16(query[end-4:])
+			resp := append([]byte{}, query[:end]...)
+			binary.BigEndian.PutUint16(resp[6:], 0)  // answers
+			binary.BigEndian.PutUint16(resp[8:], 0)  // authorities
+			binary.BigEndian.PutUint16(resp[10:], 0) // additional
+			ip, ok := records[strings.ToLower(strings.Join(labels, "."))]
+			switch {
+			case !ok:
+				binary.BigEndian.PutUint16(resp[2:], 0x8183) // NXDOMAIN
+			case qtype == 1:
+				binary.BigEndian.PutUint16(resp[2:], 0x8180)
+				binary.BigEndian.PutUint16(resp[6:], 1)
+				resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+				resp = append(resp, ip.To4()...)
+			default:
+				binary.BigEndian.PutUint16(resp[2:], 0x8180)
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func TestDNSCheck(t *testing.T) {
+	resolver, stop := serveDNS(t, map[string]net.IP{"web.example.com": net.ParseIP("192.0.2.10")})
+	defer stop()
+
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		expected model.CheckStatus
+		message  string
+	}{
+		{"resolves", map[string]string{"name": "web.example.com", "resolver": resolver}, model.StatusOK, "web.example.com A: 192.0.2.10"},
+		{"expected", map[string]string{"name": "web.example.com", "resolver": resolver, "expect": "192.0.2.10"}, model.StatusOK, "192.0.2.10"},
+		{"unexpected", map[string]string{"name": "web.example.com", "resolver": resolver, "expect": "192.0.2.10, 192.0.2.11"}, model.StatusCritical, "missing 192.0.2.11"},
+		{"regex", map[string]string{"name": "web.example.com", "resolver": resolver, "regex": `^192\.0\.2\.`}, model.StatusOK, "192.0.2.10"},
+		{"regex mismatch", map[string]string{"name": "web.example.com", "resolver": resolver, "regex": `^10\.`}, model.StatusCritical, "does not match"},
+		{"no records", map[string]string{"name": "web.example.com", "resolver": resolver, "type": "aaaa"}, model.StatusCritical, "no records"},
+		{"nxdomain", map[string]string{"name": "missing.example.com", "resolver": resolver}, model.StatusCritical, "no such host"},
+		{"slow", map[string]string{"name": "web.example.com", "resolver": resolver, "latencywarn": "1ns"}, model.StatusWarning, "lookup time over 1ns"},
+	}
+
+	for _, tt := range tests {
+		out, err := DNSCheck(tt.params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if out.Status != tt.expected || !strings.Contains(out.Message, tt.message) {
+			t.Errorf("%s: expected %v containing %q, actual %v %q", tt.name, tt.expected, tt.message, out.Status, out.Message)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{},
+		{"name": "web.example.com", "type": "PTR"},
+		{"name": "web.example.com", "regex": "("},
+	} {
+		if out, err := DNSCheck(params); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("DNSCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
+
+func TestNormalizeDNSValue(t *testing.T) {
+	var tests = []struct {
+		recordType string
+		value      string
+		expected   string
+	}{
+		{"A", "192.0.2.1", "192.0.2.1"},
+		{"AAAA", "2001:0DB8::1", "2001:db8::1"},
+		{"CNAME", "Web.Example.com.", "web.example.com"},
+		{"SRV", "SIP.example.com.:5060", "sip.example.com:5060"},
+		{"TXT", "v=spf1 -all", "v=spf1 -all"},
+	}
+
+	for _, tt := range tests {
+		if actual := normalizeDNSValue(tt.recordType, tt.value); actual != tt.expected {
+			t.Errorf("normalizeDNSValue(%q, %q): expected %q, actual %q", tt.recordType, tt.value, tt.expected, actual)
+		}
+	}
+}
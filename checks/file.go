@@ -0,0 +1,136 @@
+package checks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+// fileCheckOptions are the parsed parameters of a file check.
+type fileCheckOptions struct {
+	Path    string
+	AgeWarn time.Duration
+	AgeCrit time.Duration
+	MinSize int64
+	MaxSize int64
+}
+
+// parseFileCheckOptions parses the parameters of a file check:
+//
+//	path    - the file to check, which may be a glob pattern such as
+//	          /backups/*.tar.gz (required)
+//	agewarn - the age of the newest matching file above which the check is
+//	          Warning, such as "26h"
+//	agecrit - the age of the newest matching file above which the check is
+//	          Critical
+//	minsize - the size of the newest matching file below which the check is
+//	          Critical, such as "1M"
+//	maxsize - the size of the newest matching file above which the check is
+//	          Critical
+//
+// Empty parameters are treated as unset.
+func parseFileCheckOptions(params map[string]string) (fileCheckOptions, error) {
+	var err error
+	opts := fileCheckOptions{Path: params["path"]}
+	if opts.Path == "" {
+		return opts, fmt.Errorf("file check has no path")
+	}
+	if _, err = filepath.Match(opts.Path, ""); err != nil {
+		return opts, err
+	}
+	if raw := params["agewarn"]; raw != "" {
+		if opts.AgeWarn, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["agecrit"]; raw != "" {
+		if opts.AgeCrit, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["minsize"]; raw != "" {
+		if opts.MinSize, err = utils.ParseBytes(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["maxsize"]; raw != "" {
+		if opts.MaxSize, err = utils.ParseBytes(raw); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// fileCheck checks that a file matching a pattern exists, and that the newest
+// one is recent and of a reasonable size, such as the output of a backup job.
+// See parseFileCheckOptions for its parameters.
+func fileCheck(params map[string]string) (model.CheckOutput, error) {
+	opts, err := parseFileCheckOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	paths, err := filepath.Glob(opts.Path)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	var newest os.FileInfo
+	var newestPath string
+	var count, totalSize int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		count++
+		totalSize += info.Size()
+		if newest == nil || info.ModTime().After(newest.ModTime()) {
+			newest, newestPath = info, path
+		}
+	}
+	if newest == nil {
+		return model.CheckOutput{
+			Status:  model.StatusCritical,
+			Message: fmt.Sprintf("No files matching %s", opts.Path),
+			Metrics: map[string]float64{"files": 0},
+		}, nil
+	}
+
+	age := time.Since(newest.ModTime())
+	out := model.CheckOutput{
+		Status: model.StatusOK,
+		Message: fmt.Sprintf("%d files matching %s, newest %s (%s) modified %v ago",
+			count, opts.Path, newestPath,
+			utils.HumanReadableBytesSI(newest.Size(), 3), age.Truncate(time.Second)),
+		Metrics: map[string]float64{
+			"files":              float64(count),
+			"total_size_bytes":   float64(totalSize),
+			"newest_size_bytes":  float64(newest.Size()),
+			"newest_age_seconds": age.Seconds(),
+		},
+	}
+	problems := []string{}
+	if opts.AgeCrit > 0 && age > opts.AgeCrit {
+		out.Status = model.StatusCritical
+		problems = append(problems, fmt.Sprintf("older than %v", opts.AgeCrit))
+	} else if opts.AgeWarn > 0 && age > opts.AgeWarn {
+		out.Status = model.StatusWarning
+		problems = append(problems, fmt.Sprintf("older than %v", opts.AgeWarn))
+	}
+	if opts.MinSize > 0 && newest.Size() < opts.MinSize {
+		out.Status = model.StatusCritical
+		problems = append(problems, fmt.Sprintf("smaller than %s", utils.HumanReadableBytesSI(opts.MinSize, 3)))
+	}
+	if opts.MaxSize > 0 && newest.Size() > opts.MaxSize {
+		out.Status = model.StatusCritical
+		problems = append(problems, fmt.Sprintf("larger than %s", utils.HumanReadableBytesSI(opts.MaxSize, 3)))
+	}
+	if len(problems) > 0 {
+		out.Message += ": " + strings.Join(problems, ", ")
+	}
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package checks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+func TestFileCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "observatory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	old := filepath.Join(dir, "backup-1.tar.gz")
+	newest := filepath.Join(dir, "backup-2.tar.gz")
+	ioutil.WriteFile(old, make([]byte, 4096), 0644)
+	ioutil.WriteFile(newest, make([]byte, 2048), 0644)
+	os.Mkdir(filepath.Join(dir, "backup-3.tar.gz"), 0755)
+	twoHoursAgo := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(old, twoHoursAgo.Add(-time.Hour), twoHoursAgo.Add(-time.Hour))
+	os.Chtimes(newest, twoHoursAgo, twoHoursAgo)
+	glob := filepath.Join(dir, "backup-*.tar.gz")
+
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		expected model.CheckStatus
+		message  string
+	}{
+		{"exists", map[string]string{"path": old}, model.StatusOK, "1 files matching " + old},
+		{"glob", map[string]string{"path": glob}, model.StatusOK, "2 files matching " + glob + ", newest " + newest + " (2.00KiB) modified 2h0m"},
+		{"missing", map[string]string{"path": filepath.Join(dir, "*.sql")}, model.StatusCritical, "No files matching"},
+		{"recent", map[string]string{"path": glob, "agewarn": "3h", "agecrit": "4h"}, model.StatusOK, "modified"},
+		{"old", map[string]string{"path": glob, "agewarn": "1h", "agecrit": "4h"}, model.StatusWarning, "older than 1h0m0s"},
+		{"too old", map[string]string{"path": glob, "agewarn": "1h", "agecrit": "90m"}, model.StatusCritical, "older than 1h30m0s"},
+		{"size", map[string]string{"path": glob, "minsize": "1K", "maxsize": "4K"}, model.StatusOK, "modified"},
+		{"too small", map[string]string{"path": glob, "minsize": "3K"}, model.StatusCritical, "smaller than 3.00KiB"},
+		{"too large", map[string]string{"path": glob, "maxsize": "1K", "agewarn": "1h"}, model.StatusCritical, "older than 1h0m0s, larger than 1.00KiB"},
+	}
+
+	for _, tt := range tests {
+		out, err := fileCheck(tt.params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if out.Status != tt.expected || !strings.Contains(out.Message, tt.message) {
+			t.Errorf("%s: expected %v containing %q, actual %v %q", tt.name, tt.expected, tt.message, out.Status, out.Message)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{},
+		{"path": "[bad"},
+		{"path": glob, "agewarn": "yesterday"},
+		{"path": glob, "minsize": "big"},
+	} {
+		if out, err := fileCheck(params); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("fileCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
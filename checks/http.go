@@ -0,0 +1,233 @@
+package checks
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+const (
+	defaultHTTPTimeout   = 30 * time.Second
+	defaultHTTPRedirects = 10
+	// maxHTTPBodyBytes limits how much of the response body is read for
+	// matching.
+	maxHTTPBodyBytes = 1 << 20
+)
+
+// httpTransport is used by all HTTP checks, so that connections are reused
+// between executions.
+var httpTransport http.RoundTripper = http.DefaultTransport
+
+// httpCheckOptions are the parsed parameters of an HTTP check.
+type httpCheckOptions struct {
+	URL         string
+	Method      string
+	Headers     http.Header
+	Body        string
+	Statuses    []utils.StatusRange
+	Match       string
+	Regex       *regexp.Regexp
+	Redirects   int
+	Timeout     time.Duration
+	LatencyWarn time.Duration
+	LatencyCrit time.Duration
+	CertWarn    int
+}
+
+// parseHTTPCheckOptions parses the parameters of an HTTP check:
+//
+//	url         - the URL to request (required)
+//	method      - the request method (default GET)
+//	headers     - request headers, one "Name: value" per line
+//	body        - the request body
+//	status      - expected status codes, such as "200,301-302,4xx" (default any
+//	              status below 400)
+//	match       - a substring the response body must contain
+//	regex       - a regular expression the response body must match
+//	redirects   - the maximum number of redirects to follow (default 10)
+//	timeout     - the request timeout, such as "10s" (default 30s)
+//	latencywarn - the response time above which the check is Warning
+//	latencycrit - the response time above which the check is Critical
+//	certwarn    - the number of days before TLS certificate expiry at which the
+//	              check is Warning
+//
+// Empty parameters are treated as unset.
+func parseHTTPCheckOptions(params map[string]string) (httpCheckOptions, error) {
+	var err error
+	opts := httpCheckOptions{
+		URL:       params["url"],
+		Method:    http.MethodGet,
+		Headers:   http.Header{},
+		Body:      params["body"],
+		Match:     params["match"],
+		Redirects: defaultHTTPRedirects,
+		Timeout:   defaultHTTPTimeout,
+	}
+	if opts.URL == "" {
+		return opts, fmt.Errorf("HTTP check has no url")
+	}
+	if raw := params["method"]; raw != "" {
+		opts.Method = strings.ToUpper(raw)
+	}
+	if raw := params["headers"]; raw != "" {
+		if opts.Headers, err = utils.ParseHeaders(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["status"]; raw != "" {
+		if opts.Statuses, err = utils.ParseStatusRanges(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["regex"]; raw != "" {
+		if opts.Regex, err = regexp.Compile(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["redirects"]; raw != "" {
+		if opts.Redirects, err = strconv.Atoi(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["timeout"]; raw != "" {
+		if opts.Timeout, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["latencywarn"]; raw != "" {
+		if opts.LatencyWarn, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["latencycrit"]; raw != "" {
+		if opts.LatencyCrit, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["certwarn"]; raw != "" {
+		if opts.CertWarn, err = strconv.Atoi(raw); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// HTTPCheck requests a URL and checks the response. See
+// parseHTTPCheckOptions for its parameters.
+func HTTPCheck(params map[string]string) (model.CheckOutput, error) {
+	opts, err := parseHTTPCheckOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	var reqBody io.Reader
+	if opts.Body != "" {
+		reqBody = strings.NewReader(opts.Body)
+	}
+	req, err := http.NewRequest(opts.Method, opts.URL, reqBody)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	for name, values := range opts.Headers {
+		if name == "Host" {
+			req.Host = values[0]
+			continue
+		}
+		req.Header[name] = values
+	}
+	client := &http.Client{
+		Transport: httpTransport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.Redirects {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if resp != nil {
+		defer func() {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+	}
+	latency := time.Since(start)
+	out := model.CheckOutput{
+		Status: model.StatusOK,
+		Metrics: map[string]float64{
+			"status_code": float64(resp.StatusCode),
+			"latency_ms":  latency.Seconds() * 1000,
+		},
+	}
+	problems := []string{}
+	raise := func(status model.CheckStatus, format string, args ...interface{}) {
+		if status > out.Status {
+			out.Status = status
+		}
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !utils.StatusExpected(opts.Statuses, resp.StatusCode) {
+		raise(model.StatusCritical, "unexpected status %d", resp.StatusCode)
+	}
+	if opts.Match != "" || opts.Regex != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPBodyBytes))
+		if err != nil {
+			raise(model.StatusCritical, "reading body failed: %v", err)
+		} else {
+			if opts.Match != "" && !strings.Contains(string(body), opts.Match) {
+				raise(model.StatusCritical, "body does not contain %q", opts.Match)
+			}
+			if opts.Regex != nil && !opts.Regex.Match(body) {
+				raise(model.StatusCritical, "body does not match %q", opts.Regex.String())
+			}
+		}
+	}
+	if opts.LatencyCrit > 0 && latency > opts.LatencyCrit {
+		raise(model.StatusCritical, "response time over %v", opts.LatencyCrit)
+	} else if opts.LatencyWarn > 0 && latency > opts.LatencyWarn {
+		raise(model.StatusWarning, "response time over %v", opts.LatencyWarn)
+	}
+	if expires, ok := certExpiry(resp.TLS); ok {
+		days := expires.Sub(time.Now()).Hours() / 24
+		out.Metrics["cert_expiry_days"] = days
+		if opts.CertWarn > 0 && days < float64(opts.CertWarn) {
+			raise(model.StatusWarning, "certificate expires %s", expires.Format(time.RFC3339))
+		}
+	}
+
+	out.Message = fmt.Sprintf("%s in %v", resp.Status, latency)
+	if len(problems) > 0 {
+		out.Message += ": " + strings.Join(problems, ", ")
+	}
+	return out, nil
+}
+
+// certExpiry returns the earliest expiry of the certificates presented by the
+// server, if the connection used TLS.
+func certExpiry(state *tls.ConnectionState) (time.Time, bool) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return time.Time{}, false
+	}
+	expires := state.PeerCertificates[0].NotAfter
+	for _, cert := range state.PeerCertificates[1:] {
+		if cert.NotAfter.Before(expires) {
+			expires = cert.NotAfter
+		}
+	}
+	return expires, true
+}
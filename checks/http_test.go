@@ -0,0 +1,108 @@
+package checks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+func TestHTTPCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "all systems go")
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("X-Test"), body)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		expected model.CheckStatus
+		message  string
+	}{
+		{"ok", map[string]string{"url": server.URL + "/ok"}, model.StatusOK, "200 OK"},
+		{"not found", map[string]string{"url": server.URL + "/missing"}, model.StatusCritical, "unexpected status 404"},
+		{"expected not found", map[string]string{"url": server.URL + "/missing", "status": "404"}, model.StatusOK, "404 Not Found"},
+		{"follows redirects", map[string]string{"url": server.URL + "/redirect"}, model.StatusOK, "200 OK"},
+		{"no redirects", map[string]string{"url": server.URL + "/redirect", "redirects": "0", "status": "2xx"}, model.StatusCritical, "unexpected status 302"},
+		{"match", map[string]string{"url": server.URL + "/ok", "match": "systems go"}, model.StatusOK, "200 OK"},
+		{"no match", map[string]string{"url": server.URL + "/ok", "match": "systems down"}, model.StatusCritical, "does not contain"},
+		{"regex", map[string]string{"url": server.URL + "/ok", "regex": "^all .* go$"}, model.StatusOK, "200 OK"},
+		{"no regex match", map[string]string{"url": server.URL + "/ok", "regex": "^go"}, model.StatusCritical, "does not match"},
+		{
+			"method headers body",
+			map[string]string{
+				"url":     server.URL + "/echo",
+				"method":  "post",
+				"headers": "X-Test: hello\nAccept: text/plain",
+				"body":    "payload",
+				"match":   "POST hello payload",
+			},
+			model.StatusOK,
+			"200 OK",
+		},
+		{"latency warning", map[string]string{"url": server.URL + "/slow", "latencywarn": "1ms", "latencycrit": "10s"}, model.StatusWarning, "response time over 1ms"},
+		{"latency critical", map[string]string{"url": server.URL + "/slow", "latencywarn": "1ms", "latencycrit": "2ms"}, model.StatusCritical, "response time over 2ms"},
+		{"timeout", map[string]string{"url": server.URL + "/slow", "timeout": "5ms"}, model.StatusCritical, ""},
+		{"unset parameters", map[string]string{"url": server.URL + "/ok", "status": "", "timeout": "", "latencywarn": ""}, model.StatusOK, "200 OK"},
+	}
+
+	for _, tt := range tests {
+		out, err := HTTPCheck(tt.params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if out.Status != tt.expected || !strings.Contains(out.Message, tt.message) {
+			t.Errorf("%s: expected %v containing %q, actual %v %q", tt.name, tt.expected, tt.message, out.Status, out.Message)
+		}
+		if tt.message != "" && out.Metrics["latency_ms"] <= 0 {
+			t.Errorf("%s: expected latency metric, actual %v", tt.name, out.Metrics)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{},
+		{"url": server.URL, "timeout": "soon"},
+		{"url": server.URL, "headers": "not a header"},
+		{"url": server.URL, "regex": "("},
+	} {
+		if out, err := HTTPCheck(params); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("HTTPCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
+
+func TestHTTPCheckCertExpiry(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	defer func(transport http.RoundTripper) { httpTransport = transport }(httpTransport)
+	httpTransport = server.Client().Transport
+
+	out, err := HTTPCheck(map[string]string{"url": server.URL, "certwarn": "30"})
+	if err != nil || out.Status != model.StatusOK || out.Metrics["cert_expiry_days"] < 30 {
+		t.Errorf("expected OK with certificate expiry metric, actual %v %q %v %v", out.Status, out.Message, out.Metrics, err)
+	}
+	// The test certificate is valid for decades, but not for a million days.
+	out, err = HTTPCheck(map[string]string{"url": server.URL, "certwarn": "1000000"})
+	if err != nil || out.Status != model.StatusWarning || !strings.Contains(out.Message, "certificate expires") {
+		t.Errorf("expected certificate expiry warning, actual %v %q %v", out.Status, out.Message, err)
+	}
+}
@@ -0,0 +1,238 @@
+package checks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/aprice/observatory/model"
+)
+
+const defaultLogMaxLines = 10
+
+// logCheckOptions are the parsed parameters of a log check.
+type logCheckOptions struct {
+	Path     string
+	Regex    *regexp.Regexp
+	Exclude  *regexp.Regexp
+	Warning  int
+	Critical int
+	MaxLines int
+}
+
+// parseLogCheckOptions parses the parameters of a log check:
+//
+//	path     - the log file to watch (required)
+//	regex    - a regular expression matching the lines to count (required)
+//	exclude  - a regular expression for lines not to count even if they match
+//	warning  - the number of matching lines at which the check is Warning
+//	           (default 1)
+//	critical - the number of matching lines at which the check is Critical
+//	maxlines - the number of matching lines to include in the message
+//	           (default 10)
+//
+// Empty parameters are treated as unset.
+func parseLogCheckOptions(params map[string]string) (logCheckOptions, error) {
+	var err error
+	opts := logCheckOptions{
+		Path:     params["path"],
+		Warning:  1,
+		MaxLines: defaultLogMaxLines,
+	}
+	if opts.Path == "" {
+		return opts, fmt.Errorf("log check has no path")
+	}
+	if params["regex"] == "" {
+		return opts, fmt.Errorf("log check has no regex")
+	}
+	if opts.Regex, err = regexp.Compile(params["regex"]); err != nil {
+		return opts, err
+	}
+	if raw := params["exclude"]; raw != "" {
+		if opts.Exclude, err = regexp.Compile(raw); err != nil {
+			return opts, err
+		}
+	}
+	counts := []struct {
+		param string
+		value *int
+	}{
+		{"warning", &opts.Warning},
+		{"critical", &opts.Critical},
+		{"maxlines", &opts.MaxLines},
+	}
+	for _, c := range counts {
+		if raw := params[c.param]; raw != "" {
+			if *c.value, err = strconv.Atoi(raw); err != nil {
+				return opts, err
+			}
+		}
+	}
+	return opts, nil
+}
+
+// Matches reports whether a line should be counted.
+func (o logCheckOptions) Matches(line string) bool {
+	return o.Regex.MatchString(line) && (o.Exclude == nil || !o.Exclude.MatchString(line))
+}
+
+// logPosition is how far a log file has been read.
+type logPosition struct {
+	File   os.FileInfo
+	Offset int64
+}
+
+// logPositions holds the read position of each log check, so each run only
+// reads the lines written since the last.
+var logPositions = struct {
+	sync.Mutex
+	positions map[string]logPosition
+}{positions: make(map[string]logPosition)}
+
+// logCheck counts the lines matching a pattern which were written to a log file
+// since the check last ran. The first run after the agent starts reads from the
+// end of the file, since positions are only held in memory. If the file is
+// truncated, it is read from the start, and if it is rotated, the rest of the
+// old file is read if it can be found in the same directory, then the new file
+// from the start. See parseLogCheckOptions for its parameters.
+func logCheck(checkID uuid.UUID, params map[string]string) (model.CheckOutput, error) {
+	opts, err := parseLogCheckOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	key := checkID.String() + " " + opts.Path
+	logPositions.Lock()
+	pos, seen := logPositions.positions[key]
+	logPositions.Unlock()
+
+	f, err := os.Open(opts.Path)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+	}
+	matches := logMatches{Lines: []string{}}
+	var read int64
+	switch {
+	case !seen:
+		pos.Offset = info.Size()
+	case !os.SameFile(pos.File, info):
+		// The log was rotated.
+		if read, err = drainRotatedLog(opts.Path, pos, opts, &matches); err != nil {
+			return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+		}
+		pos.Offset = 0
+	case info.Size() < pos.Offset:
+		// The log was truncated.
+		pos.Offset = 0
+	}
+	pos.File = info
+
+	n, err := scanLog(f, pos.Offset, opts, &matches)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+	}
+	pos.Offset += n
+	read += n
+	count, lines := matches.Count, matches.Lines
+	logPositions.Lock()
+	logPositions.positions[key] = pos
+	logPositions.Unlock()
+
+	out := model.CheckOutput{
+		Status:  model.StatusOK,
+		Message: fmt.Sprintf("%d lines matching %q in %s", count, opts.Regex.String(), opts.Path),
+		Metrics: map[string]float64{
+			"matches":    float64(count),
+			"bytes_read": float64(read),
+		},
+	}
+	if opts.Critical > 0 && count >= opts.Critical {
+		out.Status = model.StatusCritical
+	} else if opts.Warning > 0 && count >= opts.Warning {
+		out.Status = model.StatusWarning
+	}
+	if len(lines) > 0 {
+		out.Message += "\n" + strings.Join(lines, "\n")
+		if count > len(lines) {
+			out.Message += fmt.Sprintf("\n(%d more)", count-len(lines))
+		}
+	}
+	return out, nil
+}
+
+// logMatches collects the lines matching a log check.
+type logMatches struct {
+	Count int
+	Lines []string
+}
+
+func (m *logMatches) add(line string, opts logCheckOptions) {
+	m.Count++
+	if len(m.Lines) < opts.MaxLines {
+		m.Lines = append(m.Lines, line)
+	}
+}
+
+// drainRotatedLog reads the rest of a rotated log, found by looking for the
+// file last read among those in the same directory whose names start with the
+// log's name (such as app.log.1). It returns the number of bytes read, which
+// is zero if the old file cannot be found, such as when it was compressed.
+func drainRotatedLog(path string, pos logPosition, opts logCheckOptions, matches *logMatches) (int64, error) {
+	dir, base := filepath.Split(path)
+	infos, err := ioutil.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return 0, err
+	}
+	for _, info := range infos {
+		if info.Name() == base || !strings.HasPrefix(info.Name(), base) || !os.SameFile(pos.File, info) {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		if info.Size() < pos.Offset {
+			return 0, nil
+		}
+		return scanLog(f, pos.Offset, opts, matches)
+	}
+	return 0, nil
+}
+
+// scanLog reads complete lines from the offset, adding those which match, and
+// returns the number of bytes read. A final line without a newline is left to
+// be read once it is complete.
+func scanLog(f io.ReadSeeker, offset int64, opts logCheckOptions, matches *logMatches) (int64, error) {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(f)
+	var read int64
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return read, nil
+		} else if err != nil {
+			return read, err
+		}
+		read += int64(len(line))
+		line = strings.TrimRight(line, "\r\n")
+		if opts.Matches(line) {
+			matches.add(line, opts)
+		}
+	}
+}
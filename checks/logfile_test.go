@@ -0,0 +1,83 @@
+package checks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+func appendLog(t *testing.T, path, text string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "observatory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	appendLog(t, path, "ERROR before the agent started\n")
+
+	checkID := utils.NewTimeUUID()
+	params := map[string]string{"path": path, "regex": "ERROR|FATAL", "exclude": "ignored", "critical": "3", "maxlines": "2"}
+	var steps = []struct {
+		name     string
+		before   func()
+		expected model.CheckStatus
+		count    float64
+		message  string
+	}{
+		{"starts at end", func() {}, model.StatusOK, 0, "0 lines matching"},
+		{"no matches", func() { appendLog(t, path, "INFO started\n") }, model.StatusOK, 0, "0 lines matching"},
+		{"warning", func() { appendLog(t, path, "ERROR one\nERROR ignored\nINFO ok\n") }, model.StatusWarning, 1, "1 lines matching \"ERROR|FATAL\" in " + path + "\nERROR one"},
+		{"partial line", func() { appendLog(t, path, "FATAL two\nERROR thr") }, model.StatusWarning, 1, "\nFATAL two"},
+		{"critical", func() { appendLog(t, path, "ee\nERROR four\nERROR five\n") }, model.StatusCritical, 3, "\nERROR three\nERROR four\n(1 more)"},
+		{"truncated", func() { ioutil.WriteFile(path, []byte("ERROR six\n"), 0644) }, model.StatusWarning, 1, "\nERROR six"},
+		{"rotated", func() {
+			os.Rename(path, path+".1")
+			appendLog(t, path+".1", "ERROR drained\n")
+			appendLog(t, path, "ERROR seven\nERROR eight\nERROR nine\nERROR ten\nERROR eleven\n")
+		}, model.StatusCritical, 6, "\nERROR drained\nERROR seven\n(4 more)"},
+	}
+
+	for _, step := range steps {
+		step.before()
+		out, err := logCheck(checkID, params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", step.name, err)
+			continue
+		}
+		if out.Status != step.expected || out.Metrics["matches"] != step.count || !strings.Contains(out.Message, step.message) {
+			t.Errorf("%s: expected %v with %v matches containing %q, actual %v %v %q", step.name, step.expected, step.count, step.message, out.Status, out.Metrics["matches"], out.Message)
+		}
+	}
+
+	os.Remove(path)
+	if out, err := logCheck(checkID, params); err != nil || out.Status != model.StatusCritical {
+		t.Errorf("expected missing file to be Critical, actual %v %q %v", out.Status, out.Message, err)
+	}
+
+	for _, params := range []map[string]string{
+		{"regex": "ERROR"},
+		{"path": path},
+		{"path": path, "regex": "("},
+		{"path": path, "regex": "ERROR", "critical": "many"},
+	} {
+		if out, err := logCheck(checkID, params); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("logCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
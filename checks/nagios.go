@@ -0,0 +1,132 @@
+package checks
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aprice/observatory/model"
+)
+
+// Nagios plugin exit codes.
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+// nagiosStatus maps a Nagios plugin exit code to a CheckStatus. UNKNOWN and
+// any other unexpected code are treated as a failure to execute the check.
+func nagiosStatus(exitCode int) model.CheckStatus {
+	switch exitCode {
+	case nagiosOK:
+		return model.StatusOK
+	case nagiosWarning:
+		return model.StatusWarning
+	case nagiosCritical:
+		return model.StatusCritical
+	default:
+		return model.StatusFailed
+	}
+}
+
+// parseNagiosOutput parses the output of a Nagios plugin, which takes the form:
+//
+//	TEXT OUTPUT | OPTIONAL PERFDATA
+//	LONG TEXT LINE 1
+//	LONG TEXT LINE 2
+//	LONG TEXT LINE N | PERFDATA LINE 2
+//	PERFDATA LINE 3
+//
+// It returns the text output and long text joined by newlines, and the
+// performance data values keyed by label.
+func parseNagiosOutput(output string) (string, map[string]float64) {
+	lines := strings.Split(strings.TrimRight(strings.Replace(output, "\r\n", "\n", -1), "\n"), "\n")
+	text := []string{}
+	perfData := []string{}
+	// The first line may carry its own perfdata; after that, perfdata starts at
+	// the first pipe in the long text and continues to the end of the output.
+	first := strings.SplitN(lines[0], "|", 2)
+	text = append(text, strings.TrimSpace(first[0]))
+	if len(first) > 1 {
+		perfData = append(perfData, first[1])
+	}
+	inPerfData := false
+	for _, line := range lines[1:] {
+		if inPerfData {
+			perfData = append(perfData, line)
+			continue
+		}
+		parts := strings.SplitN(line, "|", 2)
+		text = append(text, parts[0])
+		if len(parts) > 1 {
+			perfData = append(perfData, parts[1])
+			inPerfData = true
+		}
+	}
+
+	metrics := map[string]float64{}
+	for _, line := range perfData {
+		for label, value := range parsePerfData(line) {
+			metrics[label] = value
+		}
+	}
+	if len(metrics) == 0 {
+		metrics = nil
+	}
+	return strings.TrimSpace(strings.Join(text, "\n")), metrics
+}
+
+// parsePerfData parses a line of Nagios performance data, a space-separated
+// list of 'label'=value[UOM];[warn];[crit];[min];[max]. Only the value is
+// kept; entries with a missing or non-numeric value are skipped.
+func parsePerfData(line string) map[string]float64 {
+	metrics := map[string]float64{}
+	for _, entry := range splitPerfData(line) {
+		// Values never contain an equals sign, but quoted labels may.
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			continue
+		}
+		label := entry[:i]
+		if len(label) >= 2 && label[0] == '\'' && label[len(label)-1] == '\'' {
+			label = strings.Replace(label[1:len(label)-1], "''", "'", -1)
+		}
+		field := strings.SplitN(entry[i+1:], ";", 2)[0]
+		value, err := strconv.ParseFloat(strings.TrimRightFunc(field, isUnitRune), 64)
+		if err != nil || label == "" {
+			continue
+		}
+		metrics[label] = value
+	}
+	return metrics
+}
+
+// splitPerfData splits a line of performance data on spaces outside of quoted
+// labels.
+func splitPerfData(line string) []string {
+	entries := []string{}
+	inQuote := false
+	start := 0
+	for i, char := range line {
+		switch {
+		case char == '\'':
+			inQuote = !inQuote
+		case char == ' ' && !inQuote:
+			if i > start {
+				entries = append(entries, line[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(line) {
+		entries = append(entries, line[start:])
+	}
+	return entries
+}
+
+// isUnitRune reports whether a rune may be part of a unit of measure suffix,
+// such as s, ms, %, B, KB or c.
+func isUnitRune(r rune) bool {
+	return r == '%' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
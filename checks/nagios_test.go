@@ -0,0 +1,79 @@
+package checks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aprice/observatory/model"
+)
+
+func TestNagiosStatus(t *testing.T) {
+	var tests = []struct {
+		code     int
+		expected model.CheckStatus
+	}{
+		{0, model.StatusOK},
+		{1, model.StatusWarning},
+		{2, model.StatusCritical},
+		{3, model.StatusFailed},
+		{127, model.StatusFailed},
+	}
+
+	for _, tt := range tests {
+		if actual := nagiosStatus(tt.code); actual != tt.expected {
+			t.Errorf("nagiosStatus(%d): expected %v, actual %v", tt.code, tt.expected, actual)
+		}
+	}
+}
+
+func TestParseNagiosOutput(t *testing.T) {
+	var tests = []struct {
+		output          string
+		expectedMessage string
+		expectedMetrics map[string]float64
+	}{
+		{
+			"DISK OK - free space: / 3326 MB (56%);\n",
+			"DISK OK - free space: / 3326 MB (56%);",
+			nil,
+		},
+		{
+			"PING OK - Packet loss = 0%, RTA = 0.80 ms | rta=0.800000ms;100.000000;500.000000;0.000000 pl=0%;20;60;0\n",
+			"PING OK - Packet loss = 0%, RTA = 0.80 ms",
+			map[string]float64{"rta": 0.8, "pl": 0},
+		},
+		{
+			"DISK OK - free space: / 3326 MB (56%); | /=2643MB;5948;5958;0;5968\n" +
+				"/ 15272 MB (77%);\n" +
+				"/boot 68 MB (69%);\n" +
+				"/home 69357 MB (27%);\n" +
+				"/var/log 819 MB (84%); | /boot=68MB;88;93;0;98\n" +
+				"/home=69357MB;253404;253409;0;253414 \n" +
+				"'/var/log'=818MB;970;975;0;980\n",
+			"DISK OK - free space: / 3326 MB (56%);\n" +
+				"/ 15272 MB (77%);\n" +
+				"/boot 68 MB (69%);\n" +
+				"/home 69357 MB (27%);\n" +
+				"/var/log 819 MB (84%);",
+			map[string]float64{"/": 2643, "/boot": 68, "/home": 69357, "/var/log": 818},
+		},
+		{
+			"OK | 'time taken'=1.5s 'it''s'=3 bad novalue= unknown=U count=12c",
+			"OK",
+			map[string]float64{"time taken": 1.5, "it's": 3, "count": 12},
+		},
+		{
+			"",
+			"",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		message, metrics := parseNagiosOutput(tt.output)
+		if message != tt.expectedMessage || !reflect.DeepEqual(metrics, tt.expectedMetrics) {
+			t.Errorf("parseNagiosOutput(%q): expected %q, %v; actual %q, %v",
+				tt.output, tt.expectedMessage, tt.expectedMetrics, message, metrics)
+		}
+	}
+}
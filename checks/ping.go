@@ -0,0 +1,170 @@
+package checks
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+const (
+	defaultPingPort    = "80"
+	defaultPingCount   = 4
+	defaultPingTimeout = 2 * time.Second
+)
+
+// tcpPingOptions are the parsed parameters of a TCP ping check.
+type tcpPingOptions struct {
+	Host     string
+	Port     string
+	Count    int
+	Timeout  time.Duration
+	LossWarn float64
+	LossCrit float64
+	RTTWarn  time.Duration
+	RTTCrit  time.Duration
+}
+
+// parseTCPPingOptions parses the parameters of a TCP ping check:
+//
+//	host     - the host to ping (required)
+//	port     - the TCP port to connect to (default 80)
+//	count    - the number of connection attempts (default 4)
+//	timeout  - the timeout for each attempt, such as "1s" (default 2s)
+//	losswarn - the percentage of lost attempts above which the check is
+//	           Warning (default: never)
+//	losscrit - the percentage of lost attempts above which the check is
+//	           Critical (default: Critical only if every attempt is lost)
+//	rttwarn  - the average round trip time above which the check is Warning
+//	rttcrit  - the average round trip time above which the check is Critical
+//
+// Empty parameters are treated as unset.
+func parseTCPPingOptions(params map[string]string) (tcpPingOptions, error) {
+	var err error
+	opts := tcpPingOptions{
+		Host:     params["host"],
+		Port:     params["port"],
+		Count:    defaultPingCount,
+		Timeout:  defaultPingTimeout,
+		LossWarn: 100,
+		LossCrit: 100,
+	}
+	if opts.Host == "" {
+		return opts, fmt.Errorf("ping check has no host")
+	}
+	if opts.Port == "" {
+		opts.Port = defaultPingPort
+	}
+	if raw := params["count"]; raw != "" {
+		if opts.Count, err = strconv.Atoi(raw); err != nil {
+			return opts, err
+		}
+		if opts.Count < 1 {
+			return opts, fmt.Errorf("invalid count: %d", opts.Count)
+		}
+	}
+	if raw := params["timeout"]; raw != "" {
+		if opts.Timeout, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["losswarn"]; raw != "" {
+		if opts.LossWarn, err = strconv.ParseFloat(raw, 64); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["losscrit"]; raw != "" {
+		if opts.LossCrit, err = strconv.ParseFloat(raw, 64); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["rttwarn"]; raw != "" {
+		if opts.RTTWarn, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["rttcrit"]; raw != "" {
+		if opts.RTTCrit, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// TCPPingCheck measures reachability and round trip time to a host without
+// ICMP, by timing repeated TCP connection attempts. A refused connection still
+// proves the host is reachable, so only timeouts and unreachable errors count
+// as lost. See parseTCPPingOptions for its parameters.
+func TCPPingCheck(params map[string]string) (model.CheckOutput, error) {
+	opts, err := parseTCPPingOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	addr := net.JoinHostPort(opts.Host, opts.Port)
+	var (
+		received       int
+		total          time.Duration
+		minRTT, maxRTT time.Duration
+		lastErr        error
+	)
+	for i := 0; i < opts.Count; i++ {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", addr, opts.Timeout)
+		rtt := time.Since(start)
+		if err == nil {
+			conn.Close()
+		} else if !isRefused(err) {
+			lastErr = err
+			continue
+		}
+		received++
+		total += rtt
+		if minRTT == 0 || rtt < minRTT {
+			minRTT = rtt
+		}
+		if rtt > maxRTT {
+			maxRTT = rtt
+		}
+	}
+
+	loss := 100 * float64(opts.Count-received) / float64(opts.Count)
+	out := model.CheckOutput{
+		Status:  model.StatusOK,
+		Metrics: map[string]float64{"loss_pct": loss},
+	}
+	if received == 0 {
+		out.Status = model.StatusCritical
+		out.Message = fmt.Sprintf("%s unreachable: %v", addr, lastErr)
+		return out, nil
+	}
+	avgRTT := total / time.Duration(received)
+	out.Metrics["rtt_min_ms"] = minRTT.Seconds() * 1000
+	out.Metrics["rtt_avg_ms"] = avgRTT.Seconds() * 1000
+	out.Metrics["rtt_max_ms"] = maxRTT.Seconds() * 1000
+	out.Message = fmt.Sprintf("%s: %d/%d received, %.0f%% loss, rtt min/avg/max %v/%v/%v",
+		addr, received, opts.Count, loss, minRTT, avgRTT, maxRTT)
+
+	if loss > opts.LossCrit || (opts.RTTCrit > 0 && avgRTT > opts.RTTCrit) {
+		out.Status = model.StatusCritical
+	} else if loss > opts.LossWarn || (opts.RTTWarn > 0 && avgRTT > opts.RTTWarn) {
+		out.Status = model.StatusWarning
+	}
+	return out, nil
+}
+
+// isRefused reports whether a dial error was caused by the host actively
+// refusing the connection.
+func isRefused(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
+		return sysErr.Err == syscall.ECONNREFUSED
+	}
+	return opErr.Err == syscall.ECONNREFUSED
+}
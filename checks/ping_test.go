@@ -0,0 +1,67 @@
+package checks
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/aprice/observatory/model"
+)
+
+func TestTCPPingCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	_, closedPort, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		expected model.CheckStatus
+		message  string
+	}{
+		{"open", map[string]string{"host": "127.0.0.1", "port": port, "count": "3"}, model.StatusOK, "3/3 received, 0% loss"},
+		{"refused is reachable", map[string]string{"host": "127.0.0.1", "port": closedPort}, model.StatusOK, "4/4 received"},
+		{"slow", map[string]string{"host": "127.0.0.1", "port": port, "rttwarn": "1ns"}, model.StatusWarning, "received"},
+		{"very slow", map[string]string{"host": "127.0.0.1", "port": port, "rttwarn": "1ns", "rttcrit": "1ns"}, model.StatusCritical, "received"},
+	}
+
+	for _, tt := range tests {
+		out, err := TCPPingCheck(tt.params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if out.Status != tt.expected || !strings.Contains(out.Message, tt.message) {
+			t.Errorf("%s: expected %v containing %q, actual %v %q", tt.name, tt.expected, tt.message, out.Status, out.Message)
+		}
+		if out.Metrics["loss_pct"] != 0 || out.Metrics["rtt_avg_ms"] <= 0 {
+			t.Errorf("%s: unexpected metrics %v", tt.name, out.Metrics)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{},
+		{"host": "127.0.0.1", "count": "0"},
+		{"host": "127.0.0.1", "timeout": "soon"},
+	} {
+		if out, err := TCPPingCheck(params); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("TCPPingCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
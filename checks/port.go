@@ -0,0 +1,203 @@
+package checks
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+const (
+	defaultPortTimeout = 10 * time.Second
+	// maxBannerBytes limits how much of a response is read looking for the
+	// expected banner.
+	maxBannerBytes = 4096
+	// udpClosedWait limits how long a UDP check without an expected response
+	// waits to hear that the port is closed.
+	udpClosedWait = time.Second
+)
+
+// tlsRootCAs are the certificate authorities trusted by TLS port checks; nil
+// uses the system roots.
+var tlsRootCAs *x509.CertPool
+
+// portCheckOptions are the parsed parameters of a port check.
+type portCheckOptions struct {
+	Host     string
+	Port     string
+	Protocol string
+	Timeout  time.Duration
+	Send     string
+	Expect   string
+	CertWarn int
+}
+
+// Address returns the host and port to dial.
+func (o portCheckOptions) Address() string {
+	return net.JoinHostPort(o.Host, o.Port)
+}
+
+// parsePortCheckOptions parses the parameters of a port check:
+//
+//	port     - the port to connect to (required)
+//	host     - the host to connect to (default localhost)
+//	protocol - tcp, udp, or tls (default tcp)
+//	timeout  - the connection timeout, such as "5s" (default 10s)
+//	send     - a string to send once connected, which may include escapes
+//	           such as \r\n
+//	expect   - a string the response must contain, such as a greeting banner
+//	certwarn - the number of days before TLS certificate expiry at which the
+//	           check is Warning
+//
+// Empty parameters are treated as unset.
+func parsePortCheckOptions(params map[string]string) (portCheckOptions, error) {
+	var err error
+	opts := portCheckOptions{
+		Host:     params["host"],
+		Port:     params["port"],
+		Protocol: strings.ToLower(params["protocol"]),
+		Timeout:  defaultPortTimeout,
+	}
+	if opts.Port == "" {
+		return opts, fmt.Errorf("port check has no port")
+	}
+	if opts.Host == "" {
+		opts.Host = "localhost"
+	}
+	switch opts.Protocol {
+	case "":
+		opts.Protocol = "tcp"
+	case "tcp", "udp", "tls":
+	default:
+		return opts, fmt.Errorf("invalid protocol: %q", opts.Protocol)
+	}
+	if raw := params["timeout"]; raw != "" {
+		if opts.Timeout, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	if opts.Send, err = unescape(params["send"]); err != nil {
+		return opts, err
+	}
+	if opts.Expect, err = unescape(params["expect"]); err != nil {
+		return opts, err
+	}
+	if raw := params["certwarn"]; raw != "" {
+		if opts.CertWarn, err = strconv.Atoi(raw); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// unescape interprets Go string escapes such as \r\n and \x00.
+func unescape(raw string) (string, error) {
+	if !strings.Contains(raw, `\`) {
+		return raw, nil
+	}
+	return strconv.Unquote(`"` + strings.Replace(raw, `"`, `\"`, -1) + `"`)
+}
+
+// PortCheck connects to a port and optionally checks its response. See
+// parsePortCheckOptions for its parameters.
+func PortCheck(params map[string]string) (model.CheckOutput, error) {
+	opts, err := parsePortCheckOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	start := time.Now()
+	var conn net.Conn
+	if opts.Protocol == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", opts.Address(), &tls.Config{
+			ServerName: opts.Host,
+			RootCAs:    tlsRootCAs,
+		})
+	} else {
+		conn, err = dialer.Dial(opts.Protocol, opts.Address())
+	}
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+	}
+	defer conn.Close()
+	latency := time.Since(start)
+	out := model.CheckOutput{
+		Status:  model.StatusOK,
+		Message: fmt.Sprintf("Connected to %s/%s in %v", opts.Address(), opts.Protocol, latency),
+		Metrics: map[string]float64{"connect_ms": latency.Seconds() * 1000},
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		if expires, ok := certExpiry(&state); ok {
+			days := expires.Sub(time.Now()).Hours() / 24
+			out.Metrics["cert_expiry_days"] = days
+			if opts.CertWarn > 0 && days < float64(opts.CertWarn) {
+				out.Status = model.StatusWarning
+				out.Message += fmt.Sprintf(": certificate expires %s", expires.Format(time.RFC3339))
+			}
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(opts.Timeout))
+	if opts.Send != "" || opts.Protocol == "udp" {
+		// UDP is connectionless, so something must be sent to find out whether
+		// the port is closed.
+		if _, err = io.WriteString(conn, opts.Send); err != nil {
+			return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+		}
+	}
+	if opts.Expect == "" {
+		if opts.Protocol == "udp" {
+			// A closed UDP port answers with an ICMP error, which is returned
+			// from the next read; no answer at all is the best we can hope for.
+			// The error comes back within a round trip, so there is no need to
+			// wait out the whole timeout.
+			if opts.Timeout > udpClosedWait {
+				conn.SetDeadline(time.Now().Add(udpClosedWait))
+			}
+			_, err = conn.Read(make([]byte, maxBannerBytes))
+			if err != nil && !isTimeout(err) {
+				return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+			}
+		}
+		return out, nil
+	}
+
+	response, err := readUntil(conn, opts.Expect)
+	if !bytes.Contains(response, []byte(opts.Expect)) {
+		out.Status = model.StatusCritical
+		out.Message = fmt.Sprintf("Response from %s/%s does not contain %q: %q", opts.Address(), opts.Protocol, opts.Expect, response)
+		if err != nil && err != io.EOF {
+			out.Message += fmt.Sprintf(" (%v)", err)
+		}
+	}
+	return out, nil
+}
+
+// readUntil reads from the connection until the response contains expect, the
+// connection is closed, or the read deadline passes.
+func readUntil(conn net.Conn, expect string) ([]byte, error) {
+	response := make([]byte, 0, maxBannerBytes)
+	buf := make([]byte, maxBannerBytes)
+	for len(response) < maxBannerBytes {
+		n, err := conn.Read(buf[:maxBannerBytes-len(response)])
+		response = append(response, buf[:n]...)
+		if bytes.Contains(response, []byte(expect)) || err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,155 @@
+package checks
+
+import (
+	"bufio"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+func TestPortCheck(t *testing.T) {
+	// A line-based server in the style of SMTP or Redis: a greeting, then an
+	// echo of each line received.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				fmt.Fprint(conn, "220 test ESMTP\r\n")
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					fmt.Fprintf(conn, "+%s\r\n", scanner.Text())
+				}
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	_, closedPort, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		expected model.CheckStatus
+		message  string
+	}{
+		{"open", map[string]string{"host": "127.0.0.1", "port": port}, model.StatusOK, "Connected to 127.0.0.1:" + port + "/tcp"},
+		{"closed", map[string]string{"host": "127.0.0.1", "port": closedPort}, model.StatusCritical, "refused"},
+		{"banner", map[string]string{"host": "127.0.0.1", "port": port, "expect": "220 "}, model.StatusOK, "Connected"},
+		{"wrong banner", map[string]string{"host": "127.0.0.1", "port": port, "expect": "+OK", "timeout": "100ms"}, model.StatusCritical, `does not contain "+OK"`},
+		{"send expect", map[string]string{"host": "127.0.0.1", "port": port, "send": `PING\r\n`, "expect": "+PING"}, model.StatusOK, "Connected"},
+	}
+
+	for _, tt := range tests {
+		out, err := PortCheck(tt.params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if out.Status != tt.expected || !strings.Contains(out.Message, tt.message) {
+			t.Errorf("%s: expected %v containing %q, actual %v %q", tt.name, tt.expected, tt.message, out.Status, out.Message)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{},
+		{"port": port, "protocol": "sctp"},
+		{"port": port, "timeout": "soon"},
+	} {
+		if out, err := PortCheck(params); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("PortCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
+
+func TestPortCheckUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+
+	out, err := PortCheck(map[string]string{"host": "127.0.0.1", "port": port, "protocol": "udp", "send": "hello", "expect": "hello"})
+	if err != nil || out.Status != model.StatusOK {
+		t.Errorf("expected OK, actual %v %q %v", out.Status, out.Message, err)
+	}
+
+	closed, _ := net.ListenPacket("udp", "127.0.0.1:0")
+	_, closedPort, _ := net.SplitHostPort(closed.LocalAddr().String())
+	closed.Close()
+	out, err = PortCheck(map[string]string{"host": "127.0.0.1", "port": closedPort, "protocol": "udp", "timeout": "1s"})
+	if err != nil || out.Status != model.StatusCritical {
+		t.Errorf("expected closed port to be Critical, actual %v %q %v", out.Status, out.Message, err)
+	}
+
+	// Without an expected response, a port which never answers doesn't hold
+	// the check until the timeout.
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	_, silentPort, _ := net.SplitHostPort(silent.LocalAddr().String())
+	start := time.Now()
+	out, err = PortCheck(map[string]string{"host": "127.0.0.1", "port": silentPort, "protocol": "udp", "timeout": "30s"})
+	if err != nil || out.Status != model.StatusOK {
+		t.Errorf("expected OK, actual %v %q %v", out.Status, out.Message, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected check without expect to finish quickly, took %v", elapsed)
+	}
+}
+
+func TestPortCheckTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	params := map[string]string{"host": host, "port": port, "protocol": "tls"}
+
+	// The test certificate is not trusted by default.
+	out, err := PortCheck(params)
+	if err != nil || out.Status != model.StatusCritical {
+		t.Errorf("expected untrusted certificate to be Critical, actual %v %q %v", out.Status, out.Message, err)
+	}
+
+	defer func(roots *x509.CertPool) { tlsRootCAs = roots }(tlsRootCAs)
+	tlsRootCAs = x509.NewCertPool()
+	tlsRootCAs.AddCert(server.Certificate())
+	out, err = PortCheck(params)
+	if err != nil || out.Status != model.StatusOK || out.Metrics["cert_expiry_days"] <= 0 {
+		t.Errorf("expected OK with certificate expiry metric, actual %v %q %v %v", out.Status, out.Message, out.Metrics, err)
+	}
+	params["certwarn"] = "1000000"
+	out, err = PortCheck(params)
+	if err != nil || out.Status != model.StatusWarning || !strings.Contains(out.Message, "certificate expires") {
+		t.Errorf("expected certificate expiry warning, actual %v %q %v", out.Status, out.Message, err)
+	}
+}
@@ -0,0 +1,266 @@
+package checks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	sigar "github.com/cloudfoundry/gosigar"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+// processCPUSample is how long CPU time is measured over to find each
+// process's CPU use.
+var processCPUSample = 500 * time.Millisecond
+
+// processCheckOptions are the parsed parameters of a process check.
+type processCheckOptions struct {
+	Name    *regexp.Regexp
+	Cmdline *regexp.Regexp
+	PIDFile string
+	Min     int
+	Max     int
+	RSSWarn int64
+	RSSCrit int64
+	CPUWarn float64
+	CPUCrit float64
+}
+
+// parseProcessCheckOptions parses the parameters of a process check:
+//
+//	name    - a regular expression the process name must match
+//	cmdline - a regular expression the full command line must match
+//	pidfile - the path of a file containing the process ID
+//	min     - the minimum number of matching processes (default 1, or 0 if
+//	          max is 0)
+//	max     - the maximum number of matching processes (default: no limit)
+//	rsswarn - the resident memory of any one process above which the check is
+//	          Warning, such as "512M"
+//	rsscrit - the resident memory of any one process above which the check is
+//	          Critical
+//	cpuwarn - the CPU use of any one process above which the check is Warning,
+//	          as a percentage of one core
+//	cpucrit - the CPU use of any one process above which the check is Critical
+//
+// At least one of name, cmdline or pidfile is required; if more than one is
+// given, processes must match all of them. Empty parameters are treated as
+// unset.
+func parseProcessCheckOptions(params map[string]string) (processCheckOptions, error) {
+	var err error
+	opts := processCheckOptions{
+		PIDFile: params["pidfile"],
+		Min:     1,
+		Max:     -1,
+	}
+	if raw := params["name"]; raw != "" {
+		if opts.Name, err = regexp.Compile(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["cmdline"]; raw != "" {
+		if opts.Cmdline, err = regexp.Compile(raw); err != nil {
+			return opts, err
+		}
+	}
+	if opts.Name == nil && opts.Cmdline == nil && opts.PIDFile == "" {
+		return opts, fmt.Errorf("process check has no name, cmdline or pidfile")
+	}
+	if raw := params["min"]; raw != "" {
+		if opts.Min, err = strconv.Atoi(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["max"]; raw != "" {
+		if opts.Max, err = strconv.Atoi(raw); err != nil {
+			return opts, err
+		}
+		if opts.Max == 0 && params["min"] == "" {
+			opts.Min = 0
+		}
+		if opts.Max < opts.Min {
+			return opts, fmt.Errorf("max %d is less than min %d", opts.Max, opts.Min)
+		}
+	}
+	if raw := params["rsswarn"]; raw != "" {
+		if opts.RSSWarn, err = utils.ParseBytes(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["rsscrit"]; raw != "" {
+		if opts.RSSCrit, err = utils.ParseBytes(raw); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["cpuwarn"]; raw != "" {
+		if opts.CPUWarn, err = strconv.ParseFloat(raw, 64); err != nil {
+			return opts, err
+		}
+	}
+	if raw := params["cpucrit"]; raw != "" {
+		if opts.CPUCrit, err = strconv.ParseFloat(raw, 64); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// processInfo is the resource use of a single matching process.
+type processInfo struct {
+	PID     int
+	RSS     uint64
+	CPU     float64
+	cpuTime uint64
+}
+
+// processCheck checks that the expected number of matching processes are
+// running, and that none of them is using too much memory or CPU. See
+// parseProcessCheckOptions for its parameters.
+func processCheck(params map[string]string) (model.CheckOutput, error) {
+	opts, err := parseProcessCheckOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	procs, err := findProcesses(opts)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	sampleProcessCPU(procs)
+
+	out := model.CheckOutput{
+		Status:  model.StatusOK,
+		Metrics: map[string]float64{"count": float64(len(procs))},
+	}
+	pids := make([]string, len(procs))
+	var totalRSS, maxRSS uint64
+	var totalCPU, maxCPU float64
+	problems := []string{}
+	for i, proc := range procs {
+		pids[i] = strconv.Itoa(proc.PID)
+		totalRSS += proc.RSS
+		totalCPU += proc.CPU
+		if proc.RSS > maxRSS {
+			maxRSS = proc.RSS
+		}
+		if proc.CPU > maxCPU {
+			maxCPU = proc.CPU
+		}
+	}
+	out.Message = fmt.Sprintf("%d processes", len(procs))
+	if len(procs) > 0 {
+		out.Message += fmt.Sprintf(" (PIDs %s) using %s, %.1f%% CPU",
+			strings.Join(pids, ", "), utils.HumanReadableBytesSI(int64(totalRSS), 3), totalCPU)
+		out.Metrics["rss_bytes"] = float64(totalRSS)
+		out.Metrics["rss_max_bytes"] = float64(maxRSS)
+		out.Metrics["cpu_pct"] = totalCPU
+		out.Metrics["cpu_max_pct"] = maxCPU
+	}
+
+	if len(procs) < opts.Min {
+		out.Status = model.StatusCritical
+		problems = append(problems, fmt.Sprintf("expected at least %d", opts.Min))
+	}
+	if opts.Max >= 0 && len(procs) > opts.Max {
+		out.Status = model.StatusCritical
+		problems = append(problems, fmt.Sprintf("expected at most %d", opts.Max))
+	}
+	if opts.RSSCrit > 0 && maxRSS > uint64(opts.RSSCrit) {
+		out.Status = model.StatusCritical
+		problems = append(problems, fmt.Sprintf("memory over %s", utils.HumanReadableBytesSI(opts.RSSCrit, 3)))
+	} else if opts.RSSWarn > 0 && maxRSS > uint64(opts.RSSWarn) {
+		out.Status = worseStatus(out.Status, model.StatusWarning)
+		problems = append(problems, fmt.Sprintf("memory over %s", utils.HumanReadableBytesSI(opts.RSSWarn, 3)))
+	}
+	if opts.CPUCrit > 0 && maxCPU > opts.CPUCrit {
+		out.Status = model.StatusCritical
+		problems = append(problems, fmt.Sprintf("CPU over %.1f%%", opts.CPUCrit))
+	} else if opts.CPUWarn > 0 && maxCPU > opts.CPUWarn {
+		out.Status = worseStatus(out.Status, model.StatusWarning)
+		problems = append(problems, fmt.Sprintf("CPU over %.1f%%", opts.CPUWarn))
+	}
+	if len(problems) > 0 {
+		out.Message += ": " + strings.Join(problems, ", ")
+	}
+	return out, nil
+}
+
+// findProcesses returns the running processes matching the options, with
+// their resident memory. Processes which exit while being inspected are
+// skipped.
+func findProcesses(opts processCheckOptions) ([]processInfo, error) {
+	var pids []int
+	if opts.PIDFile != "" {
+		// A missing or empty pidfile means the process is not running.
+		raw, err := ioutil.ReadFile(opts.PIDFile)
+		if err != nil {
+			return nil, nil
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+		if err != nil {
+			return nil, nil
+		}
+		pids = []int{pid}
+	} else {
+		list := sigar.ProcList{}
+		if err := list.Get(); err != nil {
+			return nil, err
+		}
+		pids = list.List
+	}
+
+	procs := []processInfo{}
+	for _, pid := range pids {
+		state := sigar.ProcState{}
+		if err := state.Get(pid); err != nil {
+			continue
+		}
+		if opts.Name != nil && !opts.Name.MatchString(state.Name) {
+			continue
+		}
+		if opts.Cmdline != nil {
+			args := sigar.ProcArgs{}
+			if err := args.Get(pid); err != nil || !opts.Cmdline.MatchString(strings.Join(args.List, " ")) {
+				continue
+			}
+		}
+		mem := sigar.ProcMem{}
+		if err := mem.Get(pid); err != nil {
+			continue
+		}
+		procs = append(procs, processInfo{PID: pid, RSS: mem.Resident})
+	}
+	return procs, nil
+}
+
+// sampleProcessCPU measures the CPU use of each process over processCPUSample.
+func sampleProcessCPU(procs []processInfo) {
+	if len(procs) == 0 {
+		return
+	}
+	for i := range procs {
+		pt := sigar.ProcTime{}
+		if pt.Get(procs[i].PID) == nil {
+			procs[i].cpuTime = pt.Total
+		}
+	}
+	start := time.Now()
+	time.Sleep(processCPUSample)
+	elapsed := time.Since(start).Seconds() * 1000
+	for i := range procs {
+		pt := sigar.ProcTime{}
+		if pt.Get(procs[i].PID) == nil && pt.Total >= procs[i].cpuTime {
+			procs[i].CPU = 100 * float64(pt.Total-procs[i].cpuTime) / elapsed
+		}
+	}
+}
+
+func worseStatus(a, b model.CheckStatus) model.CheckStatus {
+	if b > a {
+		return b
+	}
+	return a
+}
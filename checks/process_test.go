@@ -0,0 +1,70 @@
+package checks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+func TestProcessCheck(t *testing.T) {
+	defer func(sample time.Duration) { processCPUSample = sample }(processCPUSample)
+	processCPUSample = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "observatory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidfile := filepath.Join(dir, "test.pid")
+	if err = ioutil.WriteFile(pidfile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmdline := regexp.QuoteMeta(strings.Join(os.Args, " "))
+
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		expected model.CheckStatus
+		message  string
+	}{
+		{"pidfile", map[string]string{"pidfile": pidfile}, model.StatusOK, "1 processes (PIDs " + strconv.Itoa(os.Getpid()) + ")"},
+		{"missing pidfile", map[string]string{"pidfile": filepath.Join(dir, "missing.pid")}, model.StatusCritical, "0 processes: expected at least 1"},
+		{"cmdline", map[string]string{"cmdline": cmdline}, model.StatusOK, "1 processes"},
+		{"too few", map[string]string{"cmdline": cmdline, "min": "2"}, model.StatusCritical, "expected at least 2"},
+		{"too many", map[string]string{"pidfile": pidfile, "max": "0"}, model.StatusCritical, "expected at most 0"},
+		{"none expected", map[string]string{"cmdline": "^no such process$", "max": "0"}, model.StatusOK, "0 processes"},
+		{"name mismatch", map[string]string{"pidfile": pidfile, "name": "^no such process$"}, model.StatusCritical, "0 processes"},
+		{"memory warning", map[string]string{"pidfile": pidfile, "rsswarn": "1K", "rsscrit": "1T"}, model.StatusWarning, "memory over 1.00KiB"},
+		{"memory critical", map[string]string{"pidfile": pidfile, "rsswarn": "1K", "rsscrit": "2K"}, model.StatusCritical, "memory over 2.00KiB"},
+		{"cpu", map[string]string{"pidfile": pidfile, "cpuwarn": "100000", "rsscrit": ""}, model.StatusOK, "CPU"},
+	}
+
+	for _, tt := range tests {
+		out, err := processCheck(tt.params)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if out.Status != tt.expected || !strings.Contains(out.Message, tt.message) {
+			t.Errorf("%s: expected %v containing %q, actual %v %q", tt.name, tt.expected, tt.message, out.Status, out.Message)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{},
+		{"name": "("},
+		{"pidfile": pidfile, "min": "3", "max": "2"},
+		{"pidfile": pidfile, "rsswarn": "lots"},
+	} {
+		if out, err := processCheck(params); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("processCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
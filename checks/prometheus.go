@@ -0,0 +1,472 @@
+package checks
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+// maxPrometheusBytes limits how much of a metrics page is read.
+const maxPrometheusBytes = 16 << 20
+
+// prometheusCheckOptions are the parsed parameters of a Prometheus check.
+type prometheusCheckOptions struct {
+	URL       string
+	Headers   http.Header
+	Metric    string
+	Labels    []labelMatcher
+	Aggregate string
+	Rate      bool
+	Warning   *float64
+	Critical  *float64
+	Below     bool
+	Timeout   time.Duration
+}
+
+// parsePrometheusCheckOptions parses the parameters of a Prometheus check:
+//
+//	url       - the URL of the metrics page to scrape (required)
+//	headers   - request headers, one "Name: value" per line
+//	metric    - the name of the metric to check (required)
+//	labels    - label matchers the series must match, such as
+//	            `code=~"5..",method!="OPTIONS"`
+//	aggregate - how the values of several matching series are combined: sum,
+//	            avg, min, max or count (default sum)
+//	rate      - if "true", the per-second rate of increase of the value since
+//	            the last run is checked instead of the value itself
+//	warning   - the value above which the check is Warning
+//	critical  - the value above which the check is Critical
+//	below     - if "true", the check is Warning or Critical when the value is
+//	            below the thresholds instead of above them
+//	timeout   - the request timeout, such as "10s" (default 30s)
+//
+// Empty parameters are treated as unset.
+func parsePrometheusCheckOptions(params map[string]string) (prometheusCheckOptions, error) {
+	var err error
+	opts := prometheusCheckOptions{
+		URL:       params["url"],
+		Headers:   http.Header{},
+		Metric:    params["metric"],
+		Aggregate: strings.ToLower(params["aggregate"]),
+		Rate:      params["rate"] == "true",
+		Below:     params["below"] == "true",
+		Timeout:   defaultHTTPTimeout,
+	}
+	if opts.URL == "" {
+		return opts, fmt.Errorf("Prometheus check has no url")
+	}
+	if opts.Metric == "" {
+		return opts, fmt.Errorf("Prometheus check has no metric")
+	}
+	if raw := params["headers"]; raw != "" {
+		if opts.Headers, err = utils.ParseHeaders(raw); err != nil {
+			return opts, err
+		}
+	}
+	if opts.Labels, err = parseLabelMatchers(params["labels"]); err != nil {
+		return opts, err
+	}
+	switch opts.Aggregate {
+	case "":
+		opts.Aggregate = "sum"
+	case "sum", "avg", "min", "max", "count":
+	default:
+		return opts, fmt.Errorf("invalid aggregate: %q", opts.Aggregate)
+	}
+	thresholds := []struct {
+		param string
+		value **float64
+	}{
+		{"warning", &opts.Warning},
+		{"critical", &opts.Critical},
+	}
+	for _, th := range thresholds {
+		if raw := params[th.param]; raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return opts, err
+			}
+			*th.value = &value
+		}
+	}
+	if raw := params["timeout"]; raw != "" {
+		if opts.Timeout, err = time.ParseDuration(raw); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// labelMatcher selects series by the value of one label, using the same
+// operators as PromQL: =, !=, =~ and !~. Regular expressions must match the
+// whole value.
+type labelMatcher struct {
+	Name  string
+	Op    string
+	Value string
+	Regex *regexp.Regexp
+}
+
+// Matches reports whether a series' labels satisfy the matcher. A missing
+// label has an empty value.
+func (m labelMatcher) Matches(labels map[string]string) bool {
+	value := labels[m.Name]
+	switch m.Op {
+	case "=":
+		return value == m.Value
+	case "!=":
+		return value != m.Value
+	case "=~":
+		return m.Regex.MatchString(value)
+	default:
+		return !m.Regex.MatchString(value)
+	}
+}
+
+func (m labelMatcher) String() string {
+	return m.Name + m.Op + strconv.Quote(m.Value)
+}
+
+// parseLabelMatchers parses comma-separated label matchers, optionally
+// enclosed in braces.
+func parseLabelMatchers(raw string) ([]labelMatcher, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "{") && strings.HasSuffix(raw, "}") {
+		raw = raw[1 : len(raw)-1]
+	}
+	matchers, rest, err := scanLabels(raw, true)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("invalid labels: %q", raw)
+	}
+	for i, m := range matchers {
+		if m.Op == "=~" || m.Op == "!~" {
+			if matchers[i].Regex, err = regexp.Compile("^(?:" + m.Value + ")$"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return matchers, nil
+}
+
+// scanLabels reads comma-separated name="value" pairs up to a closing brace or
+// the end of the string, and returns them with the rest of the string after
+// the brace. If ops is true, the operators !=, =~ and !~ are also accepted.
+func scanLabels(s string, ops bool) ([]labelMatcher, string, error) {
+	labels := []labelMatcher{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return labels, "", nil
+		}
+		if s[0] == '}' {
+			return labels, s[1:], nil
+		}
+		end := strings.IndexAny(s, "=!")
+		if end <= 0 {
+			return nil, "", fmt.Errorf("invalid label: %q", s)
+		}
+		label := labelMatcher{Name: strings.TrimSpace(s[:end])}
+		s = s[end:]
+		for _, op := range []string{"=~", "!=", "!~", "="} {
+			if strings.HasPrefix(s, op) && (ops || op == "=") {
+				label.Op = op
+				break
+			}
+		}
+		if label.Op == "" {
+			return nil, "", fmt.Errorf("invalid operator for label %s", label.Name)
+		}
+		s = strings.TrimLeft(s[len(label.Op):], " \t")
+		value, rest, err := scanQuoted(s)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid value for label %s: %v", label.Name, err)
+		}
+		label.Value = value
+		labels = append(labels, label)
+		s = rest
+	}
+}
+
+// scanQuoted reads a double-quoted string with backslash escapes from the
+// start of s, and returns its value and the rest of s.
+func scanQuoted(s string) (string, string, error) {
+	if s == "" || s[0] != '"' {
+		return "", "", fmt.Errorf("expected quoted string")
+	}
+	value := new(bytes.Buffer)
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return value.String(), s[i+1:], nil
+		case '\\':
+			if i++; i == len(s) {
+				return "", "", fmt.Errorf("unterminated quoted string")
+			}
+			switch s[i] {
+			case 'n':
+				value.WriteByte('\n')
+			default:
+				value.WriteByte(s[i])
+			}
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted string")
+}
+
+// promSample is the value of a single series.
+type promSample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// parsePrometheusText reads the samples of a metric from a page in the
+// Prometheus text exposition format.
+func parsePrometheusText(r io.Reader, metric string) ([]promSample, error) {
+	samples := []promSample{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxPrometheusBytes)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		end := strings.IndexAny(line, "{ \t")
+		if end < 0 || line[:end] != metric {
+			continue
+		}
+		sample := promSample{Labels: map[string]string{}}
+		rest := line[end:]
+		if rest[0] == '{' {
+			labels, after, err := scanLabels(rest[1:], false)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sample %q: %v", line, err)
+			}
+			for _, label := range labels {
+				sample.Labels[label.Name] = label.Value
+			}
+			rest = after
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid sample %q: no value", line)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample %q: %v", line, err)
+		}
+		sample.Value = value
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// aggregateSamples combines the values of the samples.
+func aggregateSamples(aggregate string, samples []promSample) float64 {
+	if aggregate == "count" {
+		return float64(len(samples))
+	}
+	if len(samples) == 0 {
+		return 0
+	}
+	result := samples[0].Value
+	for _, sample := range samples[1:] {
+		switch aggregate {
+		case "min":
+			result = math.Min(result, sample.Value)
+		case "max":
+			result = math.Max(result, sample.Value)
+		default:
+			result += sample.Value
+		}
+	}
+	if aggregate == "avg" {
+		result /= float64(len(samples))
+	}
+	return result
+}
+
+// promCounter is the value of a metric at a point in time.
+type promCounter struct {
+	Time  time.Time
+	Value float64
+}
+
+// promCounters holds the last value of each Prometheus check run in rate mode.
+var promCounters = struct {
+	sync.Mutex
+	values map[string]promCounter
+}{values: make(map[string]promCounter)}
+
+// promRate records the value for the key, and returns its per-second rate of
+// increase since the last value recorded. A decrease is treated as a counter
+// reset, and the whole of the new value as the increase. It returns false if
+// there is no earlier value.
+func promRate(key string, now promCounter) (float64, bool) {
+	promCounters.Lock()
+	last, ok := promCounters.values[key]
+	promCounters.values[key] = now
+	promCounters.Unlock()
+	elapsed := now.Time.Sub(last.Time).Seconds()
+	if !ok || elapsed <= 0 {
+		return 0, false
+	}
+	increase := now.Value - last.Value
+	if increase < 0 {
+		increase = now.Value
+	}
+	return increase / elapsed, true
+}
+
+// PrometheusCheck scrapes a page of Prometheus metrics, and checks the value
+// of the series of a metric matching the label matchers, or its rate of
+// increase. The key identifies the check and subject for measuring rates
+// between runs. See parsePrometheusCheckOptions for its parameters.
+func PrometheusCheck(key string, params map[string]string) (model.CheckOutput, error) {
+	opts, err := parsePrometheusCheckOptions(params)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	req, err := http.NewRequest(http.MethodGet, opts.URL, nil)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	for name, values := range opts.Headers {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "text/plain;version=0.0.4")
+	}
+	client := &http.Client{Transport: httpTransport, Timeout: opts.Timeout}
+	resp, err := client.Do(req)
+	if resp != nil {
+		defer func() {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+	}
+	if resp.StatusCode >= 400 {
+		return model.CheckOutput{
+			Status:  model.StatusCritical,
+			Message: fmt.Sprintf("unexpected status %d from %s", resp.StatusCode, opts.URL),
+		}, nil
+	}
+	all, err := parsePrometheusText(io.LimitReader(resp.Body, maxPrometheusBytes), opts.Metric)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusCritical, Message: err.Error()}, nil
+	}
+	return prometheusOutput(opts, key, all, time.Now()), nil
+}
+
+// prometheusOutput selects the samples matching the label matchers, and checks
+// their aggregate value, or its rate, against the thresholds.
+func prometheusOutput(opts prometheusCheckOptions, key string, all []promSample, now time.Time) model.CheckOutput {
+	samples := []promSample{}
+	for _, sample := range all {
+		matches := true
+		for _, m := range opts.Labels {
+			matches = matches && m.Matches(sample.Labels)
+		}
+		if matches {
+			samples = append(samples, sample)
+		}
+	}
+	selector := opts.Metric
+	if len(opts.Labels) > 0 {
+		matchers := make([]string, len(opts.Labels))
+		for i, m := range opts.Labels {
+			matchers[i] = m.String()
+		}
+		selector += "{" + strings.Join(matchers, ",") + "}"
+	}
+	if len(samples) == 0 && opts.Aggregate != "count" {
+		return model.CheckOutput{
+			Status:  model.StatusCritical,
+			Message: fmt.Sprintf("No series matching %s", selector),
+			Metrics: map[string]float64{"series": 0},
+		}
+	}
+
+	value := aggregateSamples(opts.Aggregate, samples)
+	out := model.CheckOutput{
+		Status:  model.StatusOK,
+		Message: fmt.Sprintf("%s of %d series %s: %g", opts.Aggregate, len(samples), selector, value),
+		Metrics: map[string]float64{
+			"series": float64(len(samples)),
+			"value":  value,
+		},
+	}
+	if len(samples) == 1 && opts.Aggregate != "count" {
+		out.Message = fmt.Sprintf("%s%s: %g", opts.Metric, formatPromLabels(samples[0].Labels), value)
+	}
+	if opts.Rate {
+		rate, ok := promRate(key, promCounter{now, value})
+		if !ok {
+			out.Message += ", rate available after the next run"
+			return out
+		}
+		out.Message += fmt.Sprintf(", rate %g/s", rate)
+		out.Metrics["rate"] = rate
+		value = rate
+	}
+
+	exceeds := func(limit *float64) bool {
+		if limit == nil {
+			return false
+		}
+		if opts.Below {
+			return value < *limit
+		}
+		return value > *limit
+	}
+	comparison := "over"
+	if opts.Below {
+		comparison = "under"
+	}
+	if exceeds(opts.Critical) {
+		out.Status = model.StatusCritical
+		out.Message += fmt.Sprintf(": %s %g", comparison, *opts.Critical)
+	} else if exceeds(opts.Warning) {
+		out.Status = model.StatusWarning
+		out.Message += fmt.Sprintf(": %s %g", comparison, *opts.Warning)
+	}
+	return out
+}
+
+// formatPromLabels formats labels as they appear in the exposition format,
+// sorted by name.
+func formatPromLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Quote(labels[name])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,165 @@
+package checks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+const testMetricsPage = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+http_requests_total{method="get",code="500",path="/a\"b\\c"} 12
+
+# Escaping in label values:
+msdos_file_access_time_seconds{path="C:\\DIR\\FILE.TXT",error="Cannot find file:\n\"FILE.TXT\""} 1.458255915e9
+up 1
+temperature_celsius -3.5
+queue_depth{queue="a"} NaN
+`
+
+func TestParsePrometheusText(t *testing.T) {
+	samples, err := parsePrometheusText(strings.NewReader(testMetricsPage), "http_requests_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []promSample{
+		{map[string]string{"method": "post", "code": "200"}, 1027},
+		{map[string]string{"method": "post", "code": "400"}, 3},
+		{map[string]string{"method": "get", "code": "500", "path": `/a"b\c`}, 12},
+	}
+	if !reflect.DeepEqual(samples, expected) {
+		t.Errorf("expected %v, actual %v", expected, samples)
+	}
+
+	samples, err = parsePrometheusText(strings.NewReader(testMetricsPage), "msdos_file_access_time_seconds")
+	if err != nil || len(samples) != 1 {
+		t.Fatalf("expected 1 sample, actual %v, %v", samples, err)
+	}
+	if samples[0].Labels["error"] != "Cannot find file:\n\"FILE.TXT\"" || samples[0].Labels["path"] != `C:\DIR\FILE.TXT` {
+		t.Errorf("unexpected labels %v", samples[0].Labels)
+	}
+
+	if _, err = parsePrometheusText(strings.NewReader(`up{job="x} 1`), "up"); err == nil {
+		t.Error("expected error for unterminated label value")
+	}
+}
+
+func TestParseLabelMatchers(t *testing.T) {
+	var tests = []struct {
+		raw     string
+		labels  map[string]string
+		matches bool
+		err     bool
+	}{
+		{``, map[string]string{"code": "200"}, true, false},
+		{`code="200"`, map[string]string{"code": "200"}, true, false},
+		{`{code="200"}`, map[string]string{"code": "500"}, false, false},
+		{`code=~"5..", method!="get"`, map[string]string{"code": "503", "method": "post"}, true, false},
+		{`code=~"5.."`, map[string]string{"code": "1503"}, false, false},
+		{`code!~"2..|3.."`, map[string]string{"code": "404"}, true, false},
+		{`path=""`, map[string]string{"code": "404"}, true, false},
+		{`code=200`, nil, false, true},
+		{`code=~"("`, nil, false, true},
+		{`code<"200"`, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		matchers, err := parseLabelMatchers(tt.raw)
+		if (err != nil) != tt.err {
+			t.Errorf("parseLabelMatchers(%q): expected error %v, actual %v", tt.raw, tt.err, err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		matches := true
+		for _, m := range matchers {
+			matches = matches && m.Matches(tt.labels)
+		}
+		if matches != tt.matches {
+			t.Errorf("parseLabelMatchers(%q) matching %v: expected %v, actual %v", tt.raw, tt.labels, tt.matches, matches)
+		}
+	}
+}
+
+func TestPrometheusCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testMetricsPage)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var tests = []struct {
+		name     string
+		params   map[string]string
+		expected model.CheckStatus
+		message  string
+	}{
+		{"no url", map[string]string{"metric": "up"}, model.StatusFailed, ""},
+		{"no metric", map[string]string{"url": server.URL + "/metrics"}, model.StatusFailed, ""},
+		{"bad aggregate", map[string]string{"url": server.URL + "/metrics", "metric": "up", "aggregate": "median"}, model.StatusFailed, ""},
+		{"not found", map[string]string{"url": server.URL + "/missing", "metric": "up"}, model.StatusCritical, "unexpected status 404"},
+		{"single", map[string]string{"url": server.URL + "/metrics", "metric": "up", "critical": "0", "below": "true"}, model.StatusOK, "up: 1"},
+		{"below", map[string]string{"url": server.URL + "/metrics", "metric": "temperature_celsius", "warning": "0", "critical": "-10", "below": "true"}, model.StatusWarning, "under 0"},
+		{"sum", map[string]string{"url": server.URL + "/metrics", "metric": "http_requests_total", "warning": "1000"}, model.StatusWarning, "sum of 3 series"},
+		{"labels", map[string]string{"url": server.URL + "/metrics", "metric": "http_requests_total", "labels": `code=~"[45].."`, "critical": "10"}, model.StatusCritical, "over 10"},
+		{"max", map[string]string{"url": server.URL + "/metrics", "metric": "http_requests_total", "labels": `code!="200"`, "aggregate": "max", "critical": "12"}, model.StatusOK, "max of 2 series"},
+		{"no series", map[string]string{"url": server.URL + "/metrics", "metric": "http_requests_total", "labels": `code="503"`}, model.StatusCritical, "No series"},
+		{"count none", map[string]string{"url": server.URL + "/metrics", "metric": "nonexistent", "aggregate": "count", "warning": "0"}, model.StatusOK, "count of 0 series"},
+	}
+
+	for _, tt := range tests {
+		actual, err := PrometheusCheck("test "+tt.name, tt.params)
+		if (err != nil) != (tt.expected == model.StatusFailed) {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if actual.Status != tt.expected || !strings.Contains(actual.Message, tt.message) {
+			t.Errorf("%s: expected %s containing %q, actual %s: %s", tt.name, tt.expected, tt.message, actual.Status, actual.Message)
+		}
+	}
+}
+
+func TestPrometheusRate(t *testing.T) {
+	opts, err := parsePrometheusCheckOptions(map[string]string{
+		"url":      "http://localhost/metrics",
+		"metric":   "requests",
+		"rate":     "true",
+		"critical": "5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "test rate"
+	start := time.Now()
+	sample := func(value float64) []promSample {
+		return []promSample{{map[string]string{}, value}}
+	}
+
+	out := prometheusOutput(opts, key, sample(100), start)
+	if out.Status != model.StatusOK || !strings.Contains(out.Message, "next run") {
+		t.Errorf("first run: expected OK waiting for rate, actual %s: %s", out.Status, out.Message)
+	}
+	out = prometheusOutput(opts, key, sample(130), start.Add(10*time.Second))
+	if out.Status != model.StatusOK || out.Metrics["rate"] != 3 {
+		t.Errorf("second run: expected OK at 3/s, actual %s: %s (%v)", out.Status, out.Message, out.Metrics)
+	}
+	out = prometheusOutput(opts, key, sample(230), start.Add(20*time.Second))
+	if out.Status != model.StatusCritical || out.Metrics["rate"] != 10 {
+		t.Errorf("third run: expected Critical at 10/s, actual %s: %s (%v)", out.Status, out.Message, out.Metrics)
+	}
+	// A counter reset counts the new value as the increase.
+	out = prometheusOutput(opts, key, sample(20), start.Add(30*time.Second))
+	if out.Status != model.StatusOK || out.Metrics["rate"] != 2 {
+		t.Errorf("after reset: expected OK at 2/s, actual %s: %s (%v)", out.Status, out.Message, out.Metrics)
+	}
+}
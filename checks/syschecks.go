@@ -3,36 +3,48 @@
 package checks
 
 import (
-	"log"
-	"strconv"
+	"time"
 
-	"github.com/aprice/observatory/model"
 	sigar "github.com/cloudfoundry/gosigar"
-	uuid "github.com/satori/go.uuid"
 )
 
-func loadCheck(subjectID uuid.UUID, params map[string]string) (model.CheckStatus, error) {
-	cpu := sigar.Cpu{}
-	err := cpu.Get()
-	if err != nil {
-		return model.StatusFailed, nil
+// sampleCPU measures total and per-core CPU usage over the window.
+func sampleCPU(window time.Duration) (cpuUsage, []cpuUsage, error) {
+	before, beforeCores := sigar.Cpu{}, sigar.CpuList{}
+	if err := before.Get(); err != nil {
+		return cpuUsage{}, nil, err
 	}
-	cpuFree := float64(cpu.Idle) / float64(cpu.Total())
-	cpuUse := (1.0 - cpuFree) * 100.0
-	log.Printf("CPU: %d idle, %d total, %.1f%% used", cpu.Idle, cpu.Total(), cpuUse)
-	thCrit, err := strconv.ParseFloat(params["warning"], 64)
-	if err != nil {
-		return model.StatusFailed, err
+	if err := beforeCores.Get(); err != nil {
+		return cpuUsage{}, nil, err
 	}
-	if thCrit < cpuUse {
-		return model.StatusCritical, nil
+	time.Sleep(window)
+	after, afterCores := sigar.Cpu{}, sigar.CpuList{}
+	if err := after.Get(); err != nil {
+		return cpuUsage{}, nil, err
 	}
-	thWarn, err := strconv.ParseFloat(params["critical"], 64)
-	if err != nil {
-		return model.StatusFailed, err
+	if err := afterCores.Get(); err != nil {
+		return cpuUsage{}, nil, err
 	}
-	if thWarn < cpuUse {
-		return model.StatusWarning, nil
+
+	var cores []cpuUsage
+	if len(beforeCores.List) == len(afterCores.List) {
+		cores = make([]cpuUsage, len(afterCores.List))
+		for i := range cores {
+			cores[i] = cpuDelta(beforeCores.List[i], afterCores.List[i])
+		}
+	}
+	return cpuDelta(before, after), cores, nil
+}
+
+// getLoadAverage returns the load averages and the number of cores.
+func getLoadAverage() (sigar.LoadAverage, int, error) {
+	load := sigar.LoadAverage{}
+	if err := load.Get(); err != nil {
+		return load, 0, err
+	}
+	cores := sigar.CpuList{}
+	if err := cores.Get(); err != nil {
+		return load, 0, err
 	}
-	return model.StatusOK, nil
+	return load, len(cores.List), nil
 }
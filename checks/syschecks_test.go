@@ -0,0 +1,32 @@
+//+build !windows
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+func TestLoadCheck(t *testing.T) {
+	out, err := loadCheck(utils.NewTimeUUID(), map[string]string{"window": "10ms", "warning": "", "critical": "100"})
+	if err != nil || out.Status != model.StatusOK || out.Metrics["cpu_used_pct"] < 0 {
+		t.Errorf("expected OK, actual %v %q %v", out.Status, out.Message, err)
+	}
+	out, err = loadCheck(utils.NewTimeUUID(), map[string]string{"mode": "load"})
+	if err != nil || out.Status != model.StatusOK {
+		t.Errorf("expected OK, actual %v %q %v", out.Status, out.Message, err)
+	}
+
+	for _, params := range []map[string]string{
+		{"mode": "average"},
+		{"window": "briefly"},
+		{"warning": "most"},
+		{"loadcrit": "1,2"},
+	} {
+		if out, err := loadCheck(utils.NewTimeUUID(), params); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("loadCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
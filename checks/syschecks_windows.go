@@ -2,42 +2,28 @@ package checks
 
 import (
 	"bytes"
-	"log"
+	"errors"
 	"os/exec"
 	"strconv"
+	"time"
 
-	"github.com/aprice/observatory/model"
 	sigar "github.com/cloudfoundry/gosigar"
-	uuid "github.com/satori/go.uuid"
 )
 
-func loadCheck(subjectID uuid.UUID, params map[string]string) (model.CheckStatus, error) {
-	cpu := sigar.Cpu{}
-	err := cpu.Get()
-	if err != nil {
-		return model.StatusFailed, nil
-	}
+// sampleCPU returns the current total CPU usage as reported by Windows, which
+// averages it over the last second; the window is ignored, and per-core, iowait
+// and steal usage are not available.
+func sampleCPU(window time.Duration) (cpuUsage, []cpuUsage, error) {
 	cpuInt, err := getCPUWin()
-	cpuUse := float64(cpuInt)
-	if err != nil {
-		return model.StatusFailed, err
-	}
-	log.Printf("CPU: %.0f%% used", cpuUse)
-	thCrit, err := strconv.ParseFloat(params["warning"], 64)
-	if err != nil {
-		return model.StatusFailed, err
-	}
-	if thCrit < cpuUse {
-		return model.StatusCritical, nil
-	}
-	thWarn, err := strconv.ParseFloat(params["critical"], 64)
 	if err != nil {
-		return model.StatusFailed, err
-	}
-	if thWarn < cpuUse {
-		return model.StatusWarning, nil
+		return cpuUsage{}, nil, err
 	}
-	return model.StatusOK, nil
+	return cpuUsage{Used: float64(cpuInt)}, nil, nil
+}
+
+// getLoadAverage is not supported, as Windows has no load average.
+func getLoadAverage() (sigar.LoadAverage, int, error) {
+	return sigar.LoadAverage{}, 0, errors.New("load average is not available on Windows")
 }
 
 func getCPUWin() (int64, error) {
@@ -12,7 +12,9 @@ import (
 	flag "github.com/ogier/pflag"
 
 	"github.com/aprice/observatory"
+	"github.com/aprice/observatory/alert"
 	"github.com/aprice/observatory/remotecheck"
+	"github.com/aprice/observatory/retention"
 	"github.com/aprice/observatory/server"
 	"github.com/aprice/observatory/server/config"
 	"github.com/aprice/observatory/utils"
@@ -28,9 +30,13 @@ func main() {
 
 	peerQuit := make(utils.SentinelChannel)
 	remoteQuit := make(utils.SentinelChannel)
+	retentionQuit := make(utils.SentinelChannel)
+	escalationQuit := make(utils.SentinelChannel)
 	go server.Start(&conf)
 	conf.Up = true
 	go remotecheck.UpdateRemoteChecks(conf, remoteQuit)
+	go retention.ExpireCheckResults(conf, retentionQuit)
+	go alert.EscalateAlerts(conf, escalationQuit)
 	t2 := time.Now()
 	log.Printf("Initialized in %v", t2.Sub(t1))
 
@@ -41,7 +47,10 @@ func main() {
 	conf.Up = false
 	go func() { peerQuit <- utils.Nothing }()
 	go func() { remoteQuit <- utils.Nothing }()
+	go func() { retentionQuit <- utils.Nothing }()
+	go func() { escalationQuit <- utils.Nothing }()
 	time.Sleep(time.Duration(1) * time.Second)
+	alert.WaitForAlerts()
 	os.Exit(0)
 }
 
@@ -0,0 +1,884 @@
+package boltdb
+
+import (
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/satori/go.uuid"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/aprice/observatory/collections"
+	"github.com/aprice/observatory/database/internal/dbutil"
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+var bucketNames = []string{"Subjects", "Checks", "CheckResults", "CheckResultRollups", "CheckStates", "Alerts", "Periods"}
+
+// InitFile sets up the BoltDB database context factory, creating the database
+// file at the given path if it does not already exist.
+func InitFile(path string) (model.AppContextFactory, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err = createBuckets(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return AppContextFactory{db}, nil
+}
+
+func createBuckets(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range bucketNames {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type AppContextFactory struct {
+	db *bolt.DB
+}
+
+func (f AppContextFactory) Get() (model.AppContext, error) {
+	return &AppContext{DB: f.db}, nil
+}
+
+func (f AppContextFactory) Close() error {
+	return f.db.Close()
+}
+
+// AppContext serves as a repository factory for the database file. Each repo
+// operation runs in its own transaction.
+type AppContext struct {
+	DB *bolt.DB
+}
+
+// SubjectRepo returns a SubjectRepo in the current context.
+func (c *AppContext) SubjectRepo() model.SubjectRepo {
+	return &SubjectRepo{c.bucket("Subjects")}
+}
+
+// CheckRepo returns a CheckRepo in the current context.
+func (c *AppContext) CheckRepo() model.CheckRepo {
+	return &CheckRepo{c.bucket("Checks")}
+}
+
+// CheckResultRepo returns a CheckResultRepo in the current context.
+func (c *AppContext) CheckResultRepo() model.CheckResultRepo {
+	return &CheckResultRepo{c.bucket("CheckResults")}
+}
+
+// CheckResultRollupRepo returns a CheckResultRollupRepo in the current context.
+func (c *AppContext) CheckResultRollupRepo() model.CheckResultRollupRepo {
+	return &CheckResultRollupRepo{c.bucket("CheckResultRollups")}
+}
+
+// CheckStateRepo returns a CheckStateRepo in the current context.
+func (c *AppContext) CheckStateRepo() model.CheckStateRepo {
+	return &CheckStateRepo{c.bucket("CheckStates")}
+}
+
+// AlertRepo returns an AlertRepo in the current context.
+func (c *AppContext) AlertRepo() model.AlertRepo {
+	return &AlertRepo{c.bucket("Alerts")}
+}
+
+// RoleRepo returns a RoleRepo in the current context.
+func (c *AppContext) RoleRepo() model.RoleRepo {
+	return &SubjectRepo{c.bucket("Subjects")}
+}
+
+// TagRepo returns a TagRepo in the current context.
+func (c *AppContext) TagRepo() model.TagRepo {
+	return &CheckRepo{c.bucket("Checks")}
+}
+
+// PeriodRepo returns a PeriodRepo in the current context.
+func (c *AppContext) PeriodRepo() model.PeriodRepo {
+	return &PeriodRepo{c.bucket("Periods")}
+}
+
+// CheckConnection verifies that the database file is still readable.
+func (c *AppContext) CheckConnection() error {
+	return c.DB.View(func(tx *bolt.Tx) error { return nil })
+}
+
+// Close the context. The database file stays open until the factory is closed.
+func (c *AppContext) Close() error {
+	return nil
+}
+
+// DropDatabase deletes all data in the database file.
+func (c *AppContext) DropDatabase() error {
+	err := c.DB.Update(func(tx *bolt.Tx) error {
+		for _, name := range bucketNames {
+			if err := tx.DeleteBucket([]byte(name)); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return createBuckets(c.DB)
+}
+
+func (c *AppContext) bucket(name string) bucket {
+	return bucket{c.DB, []byte(name)}
+}
+
+// bucket provides access to a set of documents keyed by ID. Documents are
+// stored in the same BSON representation used by the MongoDB backend, so that
+// they behave the same way on a round trip.
+type bucket struct {
+	db   *bolt.DB
+	name []byte
+}
+
+func (b bucket) get(key []byte, out interface{}) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(b.name).Get(key)
+		if v == nil {
+			return model.ErrNotFound
+		}
+		return bson.Unmarshal(v, out)
+	})
+}
+
+func (b bucket) put(key []byte, in interface{}) error {
+	v, err := bson.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(b.name).Put(key, v)
+	})
+}
+
+func (b bucket) delete(key []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(b.name)
+		if bkt.Get(key) == nil {
+			return model.ErrNotFound
+		}
+		return bkt.Delete(key)
+	})
+}
+
+// deleteWhere deletes all documents for which match returns true. The
+// document is decoded into a fresh value from newDoc before calling match.
+func (b bucket) deleteWhere(newDoc func() interface{}, match func(interface{}) bool) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(b.name)
+		keys := [][]byte{}
+		err := bkt.ForEach(func(k, v []byte) error {
+			doc := newDoc()
+			if err := bson.Unmarshal(v, doc); err != nil {
+				return err
+			}
+			if match(doc) {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := bkt.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b bucket) count() (int, error) {
+	var n int
+	err := b.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(b.name).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+// each calls fn with the raw value of every document in key order, stopping
+// at the first error.
+func (b bucket) each(fn func(v []byte) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(b.name).ForEach(func(k, v []byte) error {
+			return fn(v)
+		})
+	})
+}
+
+// SubjectRepo acts as a repository of Subjects in a BoltDB bucket.
+type SubjectRepo struct {
+	b bucket
+}
+
+func (r *SubjectRepo) Count() (int, error) {
+	return r.b.count()
+}
+
+// Find a Subject by its ID.
+func (r *SubjectRepo) Find(id uuid.UUID) (model.Subject, error) {
+	var result model.Subject
+	err := r.b.get(id.Bytes(), &result)
+	return result, err
+}
+
+// Named retrieves a Subject by its unique name.
+func (r *SubjectRepo) Named(name string) (model.Subject, error) {
+	result, err := r.filter(func(subject model.Subject) bool {
+		return subject.Name == name
+	})
+	if err != nil {
+		return model.Subject{}, err
+	}
+	if len(result) == 0 {
+		return model.Subject{}, model.ErrNotFound
+	}
+	return result[0], nil
+}
+
+// Search the Subjects in the repo by name regular expression and role (combined with AND).
+func (r *SubjectRepo) Search(name, role string) ([]model.Subject, error) {
+	re, err := dbutil.NameRegexp(name)
+	if err != nil {
+		return []model.Subject{}, err
+	}
+	return r.filter(func(subject model.Subject) bool {
+		return (re == nil || re.MatchString(subject.Name)) &&
+			(role == "" || dbutil.Contains(subject.Roles, role))
+	})
+}
+
+// ByRoles looks up all subjects with any of the given roles.
+func (r *SubjectRepo) ByRoles(roles []string) ([]model.Subject, error) {
+	roleSet := collections.NewStringSet(roles...)
+	return r.filter(func(subject model.Subject) bool {
+		return roleSet.ContainsAny(subject.Roles...)
+	})
+}
+
+// AllRoles returns all distinct roles used across all Subjects.
+func (r *SubjectRepo) AllRoles() ([]string, error) {
+	return r.distinctRoles("")
+}
+
+// SharedRoles returns all distinct roles used across all Subjects which also
+// have the given role.
+func (r *SubjectRepo) SharedRoles(role string) ([]string, error) {
+	return r.distinctRoles(role)
+}
+
+func (r *SubjectRepo) CountRoles() (int, error) {
+	roles, err := r.distinctRoles("")
+	return len(roles), err
+}
+
+// Create a new Subject in the repo.
+func (r *SubjectRepo) Create(subject *model.Subject) error {
+	subject.ID = utils.NewTimeUUID()
+	return r.b.put(subject.ID.Bytes(), subject)
+}
+
+// Update a Subject in the repo.
+func (r *SubjectRepo) Update(subject model.Subject) error {
+	return r.b.put(subject.ID.Bytes(), subject)
+}
+
+// Delete a Subject from the repo.
+func (r *SubjectRepo) Delete(subjectID uuid.UUID) error {
+	return r.b.delete(subjectID.Bytes())
+}
+
+func (r *SubjectRepo) filter(match func(model.Subject) bool) ([]model.Subject, error) {
+	result := []model.Subject{}
+	err := r.b.each(func(v []byte) error {
+		var subject model.Subject
+		if err := bson.Unmarshal(v, &subject); err != nil {
+			return err
+		}
+		if match(subject) {
+			result = append(result, subject)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (r *SubjectRepo) distinctRoles(withRole string) ([]string, error) {
+	roles := collections.StringSet{}
+	_, err := r.filter(func(subject model.Subject) bool {
+		if withRole == "" || dbutil.Contains(subject.Roles, withRole) {
+			roles.Add(subject.Roles...)
+		}
+		return false
+	})
+	return roles.ToArray(), err
+}
+
+// CheckRepo acts as a repository of Checks in a BoltDB bucket.
+type CheckRepo struct {
+	b bucket
+}
+
+func (r *CheckRepo) Count() (int, error) {
+	return r.b.count()
+}
+
+// Find a Check by its ID.
+func (r *CheckRepo) Find(id uuid.UUID) (model.Check, error) {
+	var result model.Check
+	err := r.b.get(id.Bytes(), &result)
+	return result, err
+}
+
+// Search the Checks in the repo by name regular expression and role/tag
+// (combined with AND). Any parameter left blank will be ignored.
+func (r *CheckRepo) Search(name, role, tag string) ([]model.Check, error) {
+	re, err := dbutil.NameRegexp(name)
+	if err != nil {
+		return []model.Check{}, err
+	}
+	return r.filter(func(check model.Check) bool {
+		return (re == nil || re.MatchString(check.Name)) &&
+			(role == "" || dbutil.Contains(check.Roles, role)) &&
+			(tag == "" || dbutil.Contains(check.Tags, tag))
+	})
+}
+
+// Create a new Check in the repo.
+func (r *CheckRepo) Create(check *model.Check) error {
+	check.ID = utils.NewTimeUUID()
+	return r.b.put(check.ID.Bytes(), check)
+}
+
+// Update a Check in the repo.
+func (r *CheckRepo) Update(check model.Check) error {
+	return r.b.put(check.ID.Bytes(), check)
+}
+
+// Delete a Check from the repo.
+func (r *CheckRepo) Delete(checkID uuid.UUID) error {
+	return r.b.delete(checkID.Bytes())
+}
+
+// ForRoles returns all Checks for the given Roles.
+func (r *CheckRepo) ForRoles(roles []string) ([]model.Check, error) {
+	roleSet := collections.NewStringSet(roles...)
+	return dbutil.NotFoundIfEmptyChecks(r.filter(func(check model.Check) bool {
+		return roleSet.ContainsAny(check.Roles...)
+	}))
+}
+
+// OfTypes returns all Checks for the given Types.
+func (r *CheckRepo) OfTypes(types []model.CheckType) ([]model.Check, error) {
+	return dbutil.NotFoundIfEmptyChecks(r.filter(func(check model.Check) bool {
+		return dbutil.ContainsCheckType(types, check.Type)
+	}))
+}
+
+// OfTypesForRoles returns all checks for the given Roles of the given Types.
+func (r *CheckRepo) OfTypesForRoles(types []model.CheckType, roles []string) ([]model.Check, error) {
+	roleSet := collections.NewStringSet(roles...)
+	return dbutil.NotFoundIfEmptyChecks(r.filter(func(check model.Check) bool {
+		return dbutil.ContainsCheckType(types, check.Type) && roleSet.ContainsAny(check.Roles...)
+	}))
+}
+
+// AllTags returns all distinct tags used across all Checks.
+func (r *CheckRepo) AllTags() ([]string, error) {
+	tags := collections.StringSet{}
+	_, err := r.filter(func(check model.Check) bool {
+		tags.Add(check.Tags...)
+		return false
+	})
+	return tags.ToArray(), err
+}
+
+func (r *CheckRepo) CountTags() (int, error) {
+	tags, err := r.AllTags()
+	return len(tags), err
+}
+
+func (r *CheckRepo) filter(match func(model.Check) bool) ([]model.Check, error) {
+	result := []model.Check{}
+	err := r.b.each(func(v []byte) error {
+		var check model.Check
+		if err := bson.Unmarshal(v, &check); err != nil {
+			return err
+		}
+		if match(check) {
+			result = append(result, check)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// CheckResultRepo acts as a repository of CheckResults in a BoltDB bucket.
+// Results are keyed by ID, and so are stored in the order they were recorded.
+type CheckResultRepo struct {
+	b bucket
+}
+
+func (r *CheckResultRepo) Count() (int, error) {
+	return r.b.count()
+}
+
+// Create a new CheckResult in the repo.
+func (r *CheckResultRepo) Create(check *model.CheckResult) error {
+	check.ID = utils.NewTimeUUID()
+	return r.b.put(check.ID.Bytes(), check)
+}
+
+// Search the CheckResults in the repo, newest first. See model.CheckResultQuery
+// for the available filters.
+func (r *CheckResultRepo) Search(query model.CheckResultQuery) ([]model.CheckResult, error) {
+	result := []model.CheckResult{}
+	err := r.b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(r.b.name).Cursor()
+		var k, v []byte
+		if query.Before != uuid.Nil {
+			// Seek lands on the cursor or the first result after it; either
+			// way, the previous entry is the first one to consider.
+			if k, _ = c.Seek(query.Before.Bytes()); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		} else {
+			k, v = c.Last()
+		}
+		for ; k != nil; k, v = c.Prev() {
+			var cr model.CheckResult
+			if err := bson.Unmarshal(v, &cr); err != nil {
+				return err
+			}
+			if query.SubjectID != uuid.Nil && cr.SubjectID != query.SubjectID {
+				continue
+			}
+			if query.CheckID != uuid.Nil && cr.CheckID != query.CheckID {
+				continue
+			}
+			if len(query.Statuses) > 0 && !dbutil.ContainsStatus(query.Statuses, cr.Status) {
+				continue
+			}
+			if !query.Since.IsZero() && cr.Time.Before(query.Since) {
+				continue
+			}
+			if !query.Until.IsZero() && !cr.Time.Before(query.Until) {
+				continue
+			}
+			result = append(result, cr)
+			if query.Limit > 0 && len(result) == query.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// DeleteBySubject deletes all check results for a Subject. Used for cleanup
+// after deleting a Subject.
+func (r *CheckResultRepo) DeleteBySubject(subjectID uuid.UUID) error {
+	return r.deleteWhere(func(cr *model.CheckResult) bool { return cr.SubjectID == subjectID })
+}
+
+// DeleteByCheck deletes all check results for a Check. Used for cleanup
+// after deleting a Check.
+func (r *CheckResultRepo) DeleteByCheck(checkID uuid.UUID) error {
+	return r.deleteWhere(func(cr *model.CheckResult) bool { return cr.CheckID == checkID })
+}
+
+// DeleteBySubjectCheck deletes all check results for a given subject and check.
+// Used for cleanup after removing a role from a subject.
+func (r *CheckResultRepo) DeleteBySubjectCheck(id model.SubjectCheckID) error {
+	return r.deleteWhere(func(cr *model.CheckResult) bool { return cr.SubjectCheckID == id })
+}
+
+func (r *CheckResultRepo) deleteWhere(match func(*model.CheckResult) bool) error {
+	return r.b.deleteWhere(
+		func() interface{} { return &model.CheckResult{} },
+		func(doc interface{}) bool { return match(doc.(*model.CheckResult)) })
+}
+
+// DeleteBefore deletes all check results with a time before t. Used to expire
+// raw results once they have been rolled up.
+func (r *CheckResultRepo) DeleteBefore(t time.Time) error {
+	return r.deleteWhere(func(cr *model.CheckResult) bool { return cr.Time.Before(t) })
+}
+
+// CheckResultRollupRepo acts as a repository of CheckResultRollups in a BoltDB
+// bucket.
+type CheckResultRollupRepo struct {
+	b bucket
+}
+
+func rollupKey(id model.CheckResultRollupID) []byte {
+	key := make([]byte, 41)
+	copy(key, id.SubjectID.Bytes())
+	copy(key[16:], id.CheckID.Bytes())
+	key[32] = byte(id.Period)
+	binary.BigEndian.PutUint64(key[33:], uint64(id.Start.Unix()))
+	return key
+}
+
+func (r *CheckResultRollupRepo) Count() (int, error) {
+	return r.b.count()
+}
+
+// Find a CheckResultRollup by its ID.
+func (r *CheckResultRollupRepo) Find(id model.CheckResultRollupID) (model.CheckResultRollup, error) {
+	var result model.CheckResultRollup
+	err := r.b.get(rollupKey(id), &result)
+	return result, err
+}
+
+// Upsert a CheckResultRollup in the repo.
+func (r *CheckResultRollupRepo) Upsert(rollup model.CheckResultRollup) error {
+	return r.b.put(rollupKey(rollup.ID), rollup)
+}
+
+// Search the CheckResultRollups in the repo, in order of start time. See
+// model.CheckResultRollupQuery for the available filters.
+func (r *CheckResultRollupRepo) Search(query model.CheckResultRollupQuery) ([]model.CheckResultRollup, error) {
+	result := []model.CheckResultRollup{}
+	err := r.b.each(func(v []byte) error {
+		var rollup model.CheckResultRollup
+		if err := bson.Unmarshal(v, &rollup); err != nil {
+			return err
+		}
+		id := rollup.ID
+		if (query.SubjectID == uuid.Nil || id.SubjectID == query.SubjectID) &&
+			(query.CheckID == uuid.Nil || id.CheckID == query.CheckID) &&
+			(query.Period == 0 || id.Period == query.Period) &&
+			(query.Since.IsZero() || !id.Start.Before(query.Since)) &&
+			(query.Until.IsZero() || id.Start.Before(query.Until)) {
+			result = append(result, rollup)
+		}
+		return nil
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].ID.Start.Before(result[j].ID.Start) })
+	return result, err
+}
+
+// DeleteBefore deletes all rollups of the given period starting before t.
+func (r *CheckResultRollupRepo) DeleteBefore(period model.RollupPeriod, t time.Time) error {
+	return r.b.deleteWhere(
+		func() interface{} { return &model.CheckResultRollup{} },
+		func(doc interface{}) bool {
+			id := doc.(*model.CheckResultRollup).ID
+			return id.Period == period && id.Start.Before(t)
+		})
+}
+
+// CheckStateRepo acts as a repository of CheckStates in a BoltDB bucket.
+type CheckStateRepo struct {
+	b bucket
+}
+
+func stateKey(id model.SubjectCheckID) []byte {
+	return append(id.SubjectID.Bytes(), id.CheckID.Bytes()...)
+}
+
+func (r *CheckStateRepo) Count() (int, error) {
+	return r.b.count()
+}
+
+// Find a CheckState by its ID.
+func (r *CheckStateRepo) Find(id model.SubjectCheckID) (model.CheckState, error) {
+	var result model.CheckState
+	err := r.b.get(stateKey(id), &result)
+	return result, err
+}
+
+// ForOwner returns all CheckStates owned by a given coordinator.
+func (r *CheckStateRepo) ForOwner(owner uuid.UUID) ([]model.CheckState, error) {
+	return r.filter(func(state model.CheckState) bool {
+		return state.Owner == owner
+	})
+}
+
+// ForTypes returns all CheckStates of the given types.
+func (r *CheckStateRepo) ForTypes(types []model.CheckType) ([]model.CheckState, error) {
+	return r.filter(func(state model.CheckState) bool {
+		return dbutil.ContainsCheckType(types, state.Type)
+	})
+}
+
+// CoordinatorWorkload gets the number of checks owned by each coordinator.
+func (r *CheckStateRepo) CoordinatorWorkload() (model.CoordinatorLoad, error) {
+	result := model.CoordinatorLoad{}
+	_, err := r.filter(func(state model.CheckState) bool {
+		if state.Owner != uuid.Nil {
+			result[state.Owner]++
+		}
+		return false
+	})
+	return result, err
+}
+
+// InStatusRoles returns all CheckStates for the given statuses and roles.
+func (r *CheckStateRepo) InStatusRoles(statuses []model.CheckStatus, roles []string) ([]model.CheckState, error) {
+	result, err := r.filter(func(state model.CheckState) bool {
+		return dbutil.ContainsStatus(statuses, state.Status) && dbutil.ContainsAll(state.Roles, roles)
+	})
+	if err == nil && len(result) == 0 {
+		return result, model.ErrNotFound
+	}
+	return result, err
+}
+
+// CountInRolesByStatus returns the count of distinct Subjects for the given role, by status.
+func (r *CheckStateRepo) CountInRolesByStatus(roles []string) (model.StatusSummary, error) {
+	var out model.StatusSummary
+	maxStatus := map[uuid.UUID]model.CheckStatus{}
+	_, err := r.filter(func(state model.CheckState) bool {
+		if !dbutil.ContainsAll(state.Roles, roles) {
+			return false
+		}
+		if status, ok := maxStatus[state.ID.SubjectID]; !ok || state.Status > status {
+			maxStatus[state.ID.SubjectID] = state.Status
+		}
+		return false
+	})
+	if err != nil {
+		return out, err
+	}
+	for _, status := range maxStatus {
+		switch status {
+		case model.StatusOK:
+			out.Ok++
+		case model.StatusWarning:
+			out.Warning++
+		case model.StatusCritical:
+			out.Critical++
+		}
+	}
+	return out, nil
+}
+
+// Upsert a CheckState in the repo.
+func (r *CheckStateRepo) Upsert(check model.CheckState) error {
+	return r.b.put(stateKey(check.ID), check)
+}
+
+// DeleteBySubject deletes all check states for a Subject. Used for cleanup
+// after deleting a Subject.
+func (r *CheckStateRepo) DeleteBySubject(subjectID uuid.UUID) error {
+	return r.deleteWhere(func(state *model.CheckState) bool { return state.ID.SubjectID == subjectID })
+}
+
+// DeleteByCheck deletes all check states for a Check. Used for cleanup after
+// deleting a Check.
+func (r *CheckStateRepo) DeleteByCheck(checkID uuid.UUID) error {
+	return r.deleteWhere(func(state *model.CheckState) bool { return state.ID.CheckID == checkID })
+}
+
+// DeleteBySubjectCheck deletes the check state for a given subject and check.
+// Used for cleanup after removing a role from a subject.
+func (r *CheckStateRepo) DeleteBySubjectCheck(id model.SubjectCheckID) error {
+	return r.b.delete(stateKey(id))
+}
+
+func (r *CheckStateRepo) filter(match func(model.CheckState) bool) ([]model.CheckState, error) {
+	result := []model.CheckState{}
+	err := r.b.each(func(v []byte) error {
+		var state model.CheckState
+		if err := bson.Unmarshal(v, &state); err != nil {
+			return err
+		}
+		if match(state) {
+			result = append(result, state)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (r *CheckStateRepo) deleteWhere(match func(*model.CheckState) bool) error {
+	return r.b.deleteWhere(
+		func() interface{} { return &model.CheckState{} },
+		func(doc interface{}) bool { return match(doc.(*model.CheckState)) })
+}
+
+// AlertRepo acts as a repository of Alerts in a BoltDB bucket.
+type AlertRepo struct {
+	b bucket
+}
+
+func (r *AlertRepo) Count() (int, error) {
+	return r.b.count()
+}
+
+// Create a new Alert in the repo.
+func (r *AlertRepo) Create(alert *model.Alert) error {
+	alert.ID = utils.NewTimeUUID()
+	return r.b.put(alert.ID.Bytes(), alert)
+}
+
+// Update an Alert in the repo.
+func (r *AlertRepo) Update(alert model.Alert) error {
+	return r.b.put(alert.ID.Bytes(), alert)
+}
+
+// Delete an Alert from the repo.
+func (r *AlertRepo) Delete(alertID uuid.UUID) error {
+	return r.b.delete(alertID.Bytes())
+}
+
+// Find an Alert by its ID.
+func (r *AlertRepo) Find(id uuid.UUID) (model.Alert, error) {
+	var result model.Alert
+	err := r.b.get(id.Bytes(), &result)
+	return result, err
+}
+
+// Search the Alerts in the repo by name regular expression and role/tag
+// (combined with AND). Any parameter left blank will be ignored.
+func (r *AlertRepo) Search(name, role, tag string) ([]model.Alert, error) {
+	re, err := dbutil.NameRegexp(name)
+	if err != nil {
+		return []model.Alert{}, err
+	}
+	return r.filter(func(alert model.Alert) bool {
+		return (re == nil || re.MatchString(alert.Name)) &&
+			(role == "" || dbutil.Contains(alert.Roles, role)) &&
+			(tag == "" || dbutil.Contains(alert.Tags, tag))
+	})
+}
+
+// FindByFilter returns alerts that match the given roles and tags.
+func (r *AlertRepo) FindByFilter(roles, tags []string) ([]model.Alert, error) {
+	roleSet := collections.NewStringSet(roles...)
+	tagSet := collections.NewStringSet(tags...)
+	result, err := r.filter(func(alert model.Alert) bool {
+		return roleSet.ContainsAny(alert.Roles...) && tagSet.ContainsAny(alert.Tags...)
+	})
+	if err == nil && len(result) == 0 {
+		return result, model.ErrNotFound
+	}
+	return result, err
+}
+
+func (r *AlertRepo) filter(match func(model.Alert) bool) ([]model.Alert, error) {
+	result := []model.Alert{}
+	err := r.b.each(func(v []byte) error {
+		var alert model.Alert
+		if err := bson.Unmarshal(v, &alert); err != nil {
+			return err
+		}
+		if match(alert) {
+			result = append(result, alert)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// PeriodRepo acts as a repository of Periods in a BoltDB bucket.
+type PeriodRepo struct {
+	b bucket
+}
+
+func (r *PeriodRepo) Count() (int, error) {
+	return r.b.count()
+}
+
+// Create a new Period in the repo.
+func (r *PeriodRepo) Create(period *model.Period) error {
+	period.ID = utils.NewTimeUUID()
+	return r.b.put(period.ID.Bytes(), period)
+}
+
+// Update a Period in the repo.
+func (r *PeriodRepo) Update(period model.Period) error {
+	return r.b.put(period.ID.Bytes(), period)
+}
+
+// Delete a Period from the repo.
+func (r *PeriodRepo) Delete(periodID uuid.UUID) error {
+	return r.b.delete(periodID.Bytes())
+}
+
+// Find a Period by its ID.
+func (r *PeriodRepo) Find(id uuid.UUID) (model.Period, error) {
+	var result model.Period
+	err := r.b.get(id.Bytes(), &result)
+	return result, err
+}
+
+// Search the Periods in the repo by name regular expression and role/tag
+// (combined with AND). Any parameter left blank will be ignored.
+func (r *PeriodRepo) Search(name, role, tag string) ([]model.Period, error) {
+	re, err := dbutil.NameRegexp(name)
+	if err != nil {
+		return []model.Period{}, err
+	}
+	return r.filter(func(period model.Period) bool {
+		return (re == nil || re.MatchString(period.Name)) &&
+			(role == "" || dbutil.Contains(period.Roles, role)) &&
+			(tag == "" || dbutil.Contains(period.Tags, tag))
+	})
+}
+
+// FindForSubject returns all active Periods that affect a subject by roles or ID for the given types (or all types if no types given).
+func (r *PeriodRepo) FindForSubject(subject model.Subject, types []model.PeriodType) ([]model.Period, error) {
+	now := time.Now()
+	roleSet := collections.NewStringSet(subject.Roles...)
+	return dbutil.NotFoundIfEmptyPeriods(r.filter(func(period model.Period) bool {
+		return dbutil.IsActive(period, now, types) &&
+			len(period.Tags) == 0 &&
+			(roleSet.ContainsAny(period.Roles...) || dbutil.ContainsUUID(period.Subjects, subject.ID))
+	}))
+}
+
+// FindForSubjectChecks returns all active Periods that affect a subject by roles or ID for the given tags and types (or all types if no types given).
+func (r *PeriodRepo) FindForSubjectChecks(subject model.Subject, tags []string, types []model.PeriodType) ([]model.Period, error) {
+	now := time.Now()
+	roleSet := collections.NewStringSet(subject.Roles...)
+	tagSet := collections.NewStringSet(tags...)
+	return dbutil.NotFoundIfEmptyPeriods(r.filter(func(period model.Period) bool {
+		return dbutil.IsActive(period, now, types) &&
+			tagSet.ContainsAny(period.Tags...) &&
+			(roleSet.ContainsAny(period.Roles...) ||
+				dbutil.ContainsUUID(period.Subjects, subject.ID) ||
+				(len(period.Roles) == 0 && len(period.Subjects) == 0))
+	}))
+}
+
+// FindByType returns all active periods for the given types (or all types if no types given).
+func (r *PeriodRepo) FindByType(types []model.PeriodType) ([]model.Period, error) {
+	now := time.Now()
+	return dbutil.NotFoundIfEmptyPeriods(r.filter(func(period model.Period) bool {
+		return dbutil.IsActive(period, now, types)
+	}))
+}
+
+func (r *PeriodRepo) filter(match func(model.Period) bool) ([]model.Period, error) {
+	result := []model.Period{}
+	err := r.b.each(func(v []byte) error {
+		var period model.Period
+		if err := bson.Unmarshal(v, &period); err != nil {
+			return err
+		}
+		if match(period) {
+			result = append(result, period)
+		}
+		return nil
+	})
+	return result, err
+}
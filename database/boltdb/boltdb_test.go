@@ -0,0 +1,160 @@
+package boltdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+// Validate that context & repos implement the desired interfaces.
+func TestInterfaceImplementation(t *testing.T) {
+	var _ model.AppContextFactory = (*AppContextFactory)(nil)
+	var _ model.AppContext = (*AppContext)(nil)
+	var _ model.SubjectRepo = (*SubjectRepo)(nil)
+	var _ model.CheckRepo = (*CheckRepo)(nil)
+	var _ model.AlertRepo = (*AlertRepo)(nil)
+	var _ model.PeriodRepo = (*PeriodRepo)(nil)
+	var _ model.TagRepo = (*CheckRepo)(nil)
+	var _ model.RoleRepo = (*SubjectRepo)(nil)
+	var _ model.CheckStateRepo = (*CheckStateRepo)(nil)
+	var _ model.CheckResultRepo = (*CheckResultRepo)(nil)
+	var _ model.CheckResultRollupRepo = (*CheckResultRollupRepo)(nil)
+}
+
+func testContext(t *testing.T) (model.AppContext, func()) {
+	dir, err := ioutil.TempDir("", "observatory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory, err := InitFile(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, _ := factory.Get()
+	return ctx, func() {
+		factory.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx, done := testContext(t)
+	defer done()
+	state := model.CheckState{
+		ID:        model.SubjectCheckID{},
+		Status:    model.StatusWarning,
+		Roles:     []string{"web"},
+		Reminders: map[string]time.Time{"alert": time.Now()},
+	}
+	if err := ctx.CheckStateRepo().Upsert(state); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := ctx.CheckStateRepo().Find(state.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != state.Status || len(stored.Roles) != 1 || len(stored.Reminders) != 1 {
+		t.Errorf("Stored state does not match: %+v", stored)
+	}
+	if _, err = ctx.SubjectRepo().Find(state.ID.SubjectID); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err = ctx.CheckStateRepo().DeleteBySubjectCheck(state.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = ctx.CheckStateRepo().DeleteBySubjectCheck(state.ID); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound deleting again, got %v", err)
+	}
+}
+
+func TestCheckResultSearch(t *testing.T) {
+	ctx, done := testContext(t)
+	defer done()
+	repo := ctx.CheckResultRepo()
+	subject := model.Subject{Name: "test"}
+	ctx.SubjectRepo().Create(&subject)
+	for i := 0; i < 5; i++ {
+		cr := model.CheckResult{Time: time.Now(), Status: model.CheckStatus(i % 2)}
+		cr.SubjectID = subject.ID
+		repo.Create(&cr)
+	}
+
+	results, err := repo.Search(model.CheckResultQuery{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	rest, _ := repo.Search(model.CheckResultQuery{Before: results[1].ID})
+	if len(rest) != 3 {
+		t.Errorf("Expected 3 results before cursor, got %d", len(rest))
+	}
+	ok, _ := repo.Search(model.CheckResultQuery{SubjectID: subject.ID, Statuses: []model.CheckStatus{model.StatusOK}})
+	if len(ok) != 2 {
+		t.Errorf("Expected 2 OK results, got %d", len(ok))
+	}
+
+	repo.DeleteBySubject(subject.ID)
+	if count, _ := repo.Count(); count != 0 {
+		t.Errorf("Expected no results after delete, got %d", count)
+	}
+}
+
+func TestCoordinatorWorkload(t *testing.T) {
+	ctx, done := testContext(t)
+	defer done()
+	owner := model.Subject{Name: "coordinator"}
+	ctx.SubjectRepo().Create(&owner)
+	for i := 0; i < 3; i++ {
+		check := model.Check{Name: "remote"}
+		ctx.CheckRepo().Create(&check)
+		ctx.CheckStateRepo().Upsert(model.CheckState{
+			ID:    model.SubjectCheckID{SubjectID: owner.ID, CheckID: check.ID},
+			Owner: owner.ID,
+		})
+	}
+	load, err := ctx.CheckStateRepo().CoordinatorWorkload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if load[owner.ID] != 3 {
+		t.Errorf("Expected load of 3, got %v", load)
+	}
+}
+
+func TestRollups(t *testing.T) {
+	ctx, done := testContext(t)
+	defer done()
+	repo := ctx.CheckResultRollupRepo()
+	start := time.Now().Truncate(time.Hour)
+	for i := 0; i < 3; i++ {
+		rollup := model.CheckResultRollup{ID: model.CheckResultRollupID{
+			Period: model.RollupHourly,
+			Start:  start.Add(time.Duration(-i) * time.Hour),
+		}}
+		rollup.Counts.Add(model.StatusOK, int64(i))
+		if err := repo.Upsert(rollup); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, err := repo.Find(model.CheckResultRollupID{Period: model.RollupHourly, Start: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found.ID.Start.Equal(start) {
+		t.Errorf("Expected rollup starting %v, got %v", start, found.ID.Start)
+	}
+	rollups, _ := repo.Search(model.CheckResultRollupQuery{Since: start.Add(-time.Hour)})
+	if len(rollups) != 2 || !rollups[0].ID.Start.Before(rollups[1].ID.Start) {
+		t.Errorf("Expected 2 rollups in order of start, got %+v", rollups)
+	}
+	repo.DeleteBefore(model.RollupHourly, start)
+	if count, _ := repo.Count(); count != 1 {
+		t.Errorf("Expected 1 rollup after delete, got %d", count)
+	}
+}
@@ -0,0 +1,106 @@
+// Package dbutil holds the filtering helpers shared by the storage backends
+// which search their data in Go rather than in the database.
+package dbutil
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/aprice/observatory/model"
+)
+
+// IsActive reports whether a period covers the given time, and is of one of
+// the given types, or of any type if none are given.
+func IsActive(period model.Period, now time.Time, types []model.PeriodType) bool {
+	if period.Start.After(now) || period.End.Before(now) {
+		return false
+	}
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if period.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// NotFoundIfEmptyChecks returns model.ErrNotFound in place of a nil error if
+// no checks were found.
+func NotFoundIfEmptyChecks(checks []model.Check, err error) ([]model.Check, error) {
+	if err == nil && len(checks) == 0 {
+		return checks, model.ErrNotFound
+	}
+	return checks, err
+}
+
+// NotFoundIfEmptyPeriods returns model.ErrNotFound in place of a nil error if
+// no periods were found.
+func NotFoundIfEmptyPeriods(periods []model.Period, err error) ([]model.Period, error) {
+	if err == nil && len(periods) == 0 {
+		return periods, model.ErrNotFound
+	}
+	return periods, err
+}
+
+// NameRegexp compiles a case-insensitive name search pattern, or returns nil
+// if no pattern was given.
+func NameRegexp(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// Contains reports whether the haystack includes the needle.
+func Contains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsAll reports whether the haystack includes every needle.
+func ContainsAll(haystack []string, needles []string) bool {
+	for _, needle := range needles {
+		if !Contains(haystack, needle) {
+			return false
+		}
+	}
+	return true
+}
+
+// ContainsUUID reports whether the haystack includes the needle.
+func ContainsUUID(haystack []uuid.UUID, needle uuid.UUID) bool {
+	for _, id := range haystack {
+		if id == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsCheckType reports whether the haystack includes the needle.
+func ContainsCheckType(haystack []model.CheckType, needle model.CheckType) bool {
+	for _, t := range haystack {
+		if t == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsStatus reports whether the haystack includes the needle.
+func ContainsStatus(haystack []model.CheckStatus, needle model.CheckStatus) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,960 @@
+package memory
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/aprice/observatory/collections"
+	"github.com/aprice/observatory/database/internal/dbutil"
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+// InitStore sets up a new, empty in-memory database context factory. All
+// contexts produced by the factory share the same data, which lives only as
+// long as the process.
+func InitStore() model.AppContextFactory {
+	return AppContextFactory{newStore()}
+}
+
+type store struct {
+	sync.RWMutex
+	subjects map[uuid.UUID]model.Subject
+	checks   map[uuid.UUID]model.Check
+	results  []model.CheckResult
+	rollups  map[rollupKey]model.CheckResultRollup
+	states   map[model.SubjectCheckID]model.CheckState
+	alerts   map[uuid.UUID]model.Alert
+	periods  map[uuid.UUID]model.Period
+}
+
+func newStore() *store {
+	s := &store{}
+	s.reset()
+	return s
+}
+
+func (s *store) reset() {
+	s.subjects = map[uuid.UUID]model.Subject{}
+	s.checks = map[uuid.UUID]model.Check{}
+	s.results = []model.CheckResult{}
+	s.rollups = map[rollupKey]model.CheckResultRollup{}
+	s.states = map[model.SubjectCheckID]model.CheckState{}
+	s.alerts = map[uuid.UUID]model.Alert{}
+	s.periods = map[uuid.UUID]model.Period{}
+}
+
+type AppContextFactory struct {
+	s *store
+}
+
+func (f AppContextFactory) Get() (model.AppContext, error) {
+	return &AppContext{f.s}, nil
+}
+
+func (f AppContextFactory) Close() error {
+	return nil
+}
+
+// AppContext serves as a repository factory for the in-memory store.
+type AppContext struct {
+	s *store
+}
+
+// SubjectRepo returns a SubjectRepo in the current context.
+func (c *AppContext) SubjectRepo() model.SubjectRepo {
+	return &SubjectRepo{c.s}
+}
+
+// CheckRepo returns a CheckRepo in the current context.
+func (c *AppContext) CheckRepo() model.CheckRepo {
+	return &CheckRepo{c.s}
+}
+
+// CheckResultRepo returns a CheckResultRepo in the current context.
+func (c *AppContext) CheckResultRepo() model.CheckResultRepo {
+	return &CheckResultRepo{c.s}
+}
+
+// CheckResultRollupRepo returns a CheckResultRollupRepo in the current context.
+func (c *AppContext) CheckResultRollupRepo() model.CheckResultRollupRepo {
+	return &CheckResultRollupRepo{c.s}
+}
+
+// CheckStateRepo returns a CheckStateRepo in the current context.
+func (c *AppContext) CheckStateRepo() model.CheckStateRepo {
+	return &CheckStateRepo{c.s}
+}
+
+// AlertRepo returns an AlertRepo in the current context.
+func (c *AppContext) AlertRepo() model.AlertRepo {
+	return &AlertRepo{c.s}
+}
+
+// RoleRepo returns a RoleRepo in the current context.
+func (c *AppContext) RoleRepo() model.RoleRepo {
+	return &SubjectRepo{c.s}
+}
+
+// TagRepo returns a TagRepo in the current context.
+func (c *AppContext) TagRepo() model.TagRepo {
+	return &CheckRepo{c.s}
+}
+
+// PeriodRepo returns a PeriodRepo in the current context.
+func (c *AppContext) PeriodRepo() model.PeriodRepo {
+	return &PeriodRepo{c.s}
+}
+
+// CheckConnection always succeeds, as there is no connection to check.
+func (c *AppContext) CheckConnection() error {
+	return nil
+}
+
+// Close the context. The underlying data is unaffected.
+func (c *AppContext) Close() error {
+	return nil
+}
+
+// DropDatabase discards all data in the store.
+func (c *AppContext) DropDatabase() error {
+	c.s.Lock()
+	defer c.s.Unlock()
+	c.s.reset()
+	return nil
+}
+
+// SubjectRepo acts as a repository of Subjects in memory.
+type SubjectRepo struct {
+	s *store
+}
+
+func (r *SubjectRepo) Count() (int, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return len(r.s.subjects), nil
+}
+
+// Find a Subject by its ID.
+func (r *SubjectRepo) Find(id uuid.UUID) (model.Subject, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	subject, ok := r.s.subjects[id]
+	if !ok {
+		return model.Subject{}, model.ErrNotFound
+	}
+	return copySubject(subject), nil
+}
+
+// Named retrieves a Subject by its unique name.
+func (r *SubjectRepo) Named(name string) (model.Subject, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	for _, subject := range r.s.subjects {
+		if subject.Name == name {
+			return copySubject(subject), nil
+		}
+	}
+	return model.Subject{}, model.ErrNotFound
+}
+
+// Search the Subjects in the repo by name regular expression and role (combined with AND).
+func (r *SubjectRepo) Search(name, role string) ([]model.Subject, error) {
+	re, err := dbutil.NameRegexp(name)
+	if err != nil {
+		return []model.Subject{}, err
+	}
+	return r.filter(func(subject model.Subject) bool {
+		return (re == nil || re.MatchString(subject.Name)) &&
+			(role == "" || dbutil.Contains(subject.Roles, role))
+	}), nil
+}
+
+// ByRoles looks up all subjects with any of the given roles.
+func (r *SubjectRepo) ByRoles(roles []string) ([]model.Subject, error) {
+	roleSet := collections.NewStringSet(roles...)
+	return r.filter(func(subject model.Subject) bool {
+		return roleSet.ContainsAny(subject.Roles...)
+	}), nil
+}
+
+// AllRoles returns all distinct roles used across all Subjects.
+func (r *SubjectRepo) AllRoles() ([]string, error) {
+	return r.distinctRoles(""), nil
+}
+
+// SharedRoles returns all distinct roles used across all Subjects which also
+// have the given role.
+func (r *SubjectRepo) SharedRoles(role string) ([]string, error) {
+	return r.distinctRoles(role), nil
+}
+
+func (r *SubjectRepo) CountRoles() (int, error) {
+	return len(r.distinctRoles("")), nil
+}
+
+// Create a new Subject in the repo.
+func (r *SubjectRepo) Create(subject *model.Subject) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	subject.ID = utils.NewTimeUUID()
+	r.s.subjects[subject.ID] = copySubject(*subject)
+	return nil
+}
+
+// Update a Subject in the repo.
+func (r *SubjectRepo) Update(subject model.Subject) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	r.s.subjects[subject.ID] = copySubject(subject)
+	return nil
+}
+
+// Delete a Subject from the repo.
+func (r *SubjectRepo) Delete(subjectID uuid.UUID) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	if _, ok := r.s.subjects[subjectID]; !ok {
+		return model.ErrNotFound
+	}
+	delete(r.s.subjects, subjectID)
+	return nil
+}
+
+func (r *SubjectRepo) filter(match func(model.Subject) bool) []model.Subject {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	result := []model.Subject{}
+	for _, subject := range r.s.subjects {
+		if match(subject) {
+			result = append(result, copySubject(subject))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return lessUUID(result[i].ID, result[j].ID) })
+	return result
+}
+
+func (r *SubjectRepo) distinctRoles(withRole string) []string {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	roles := collections.StringSet{}
+	for _, subject := range r.s.subjects {
+		if withRole == "" || dbutil.Contains(subject.Roles, withRole) {
+			roles.Add(subject.Roles...)
+		}
+	}
+	return roles.ToArray()
+}
+
+// CheckRepo acts as a repository of Checks in memory.
+type CheckRepo struct {
+	s *store
+}
+
+func (r *CheckRepo) Count() (int, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return len(r.s.checks), nil
+}
+
+// Find a Check by its ID.
+func (r *CheckRepo) Find(id uuid.UUID) (model.Check, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	check, ok := r.s.checks[id]
+	if !ok {
+		return model.Check{}, model.ErrNotFound
+	}
+	return copyCheck(check), nil
+}
+
+// Search the Checks in the repo by name regular expression and role/tag
+// (combined with AND). Any parameter left blank will be ignored.
+func (r *CheckRepo) Search(name, role, tag string) ([]model.Check, error) {
+	re, err := dbutil.NameRegexp(name)
+	if err != nil {
+		return []model.Check{}, err
+	}
+	return r.filter(func(check model.Check) bool {
+		return (re == nil || re.MatchString(check.Name)) &&
+			(role == "" || dbutil.Contains(check.Roles, role)) &&
+			(tag == "" || dbutil.Contains(check.Tags, tag))
+	}), nil
+}
+
+// Create a new Check in the repo.
+func (r *CheckRepo) Create(check *model.Check) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	check.ID = utils.NewTimeUUID()
+	r.s.checks[check.ID] = copyCheck(*check)
+	return nil
+}
+
+// Update a Check in the repo.
+func (r *CheckRepo) Update(check model.Check) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	r.s.checks[check.ID] = copyCheck(check)
+	return nil
+}
+
+// Delete a Check from the repo.
+func (r *CheckRepo) Delete(checkID uuid.UUID) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	if _, ok := r.s.checks[checkID]; !ok {
+		return model.ErrNotFound
+	}
+	delete(r.s.checks, checkID)
+	return nil
+}
+
+// ForRoles returns all Checks for the given Roles.
+func (r *CheckRepo) ForRoles(roles []string) ([]model.Check, error) {
+	roleSet := collections.NewStringSet(roles...)
+	return dbutil.NotFoundIfEmptyChecks(r.filter(func(check model.Check) bool {
+		return roleSet.ContainsAny(check.Roles...)
+	}), nil)
+}
+
+// OfTypes returns all Checks for the given Types.
+func (r *CheckRepo) OfTypes(types []model.CheckType) ([]model.Check, error) {
+	return dbutil.NotFoundIfEmptyChecks(r.filter(func(check model.Check) bool {
+		return dbutil.ContainsCheckType(types, check.Type)
+	}), nil)
+}
+
+// OfTypesForRoles returns all checks for the given Roles of the given Types.
+func (r *CheckRepo) OfTypesForRoles(types []model.CheckType, roles []string) ([]model.Check, error) {
+	roleSet := collections.NewStringSet(roles...)
+	return dbutil.NotFoundIfEmptyChecks(r.filter(func(check model.Check) bool {
+		return dbutil.ContainsCheckType(types, check.Type) && roleSet.ContainsAny(check.Roles...)
+	}), nil)
+}
+
+// AllTags returns all distinct tags used across all Checks.
+func (r *CheckRepo) AllTags() ([]string, error) {
+	return r.distinctTags(), nil
+}
+
+func (r *CheckRepo) CountTags() (int, error) {
+	return len(r.distinctTags()), nil
+}
+
+func (r *CheckRepo) filter(match func(model.Check) bool) []model.Check {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	result := []model.Check{}
+	for _, check := range r.s.checks {
+		if match(check) {
+			result = append(result, copyCheck(check))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return lessUUID(result[i].ID, result[j].ID) })
+	return result
+}
+
+func (r *CheckRepo) distinctTags() []string {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	tags := collections.StringSet{}
+	for _, check := range r.s.checks {
+		tags.Add(check.Tags...)
+	}
+	return tags.ToArray()
+}
+
+// CheckResultRepo acts as a repository of CheckResults in memory. Results are
+// kept ordered by ID, which is also the order in which they were recorded.
+type CheckResultRepo struct {
+	s *store
+}
+
+func (r *CheckResultRepo) Count() (int, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return len(r.s.results), nil
+}
+
+// Create a new CheckResult in the repo.
+func (r *CheckResultRepo) Create(check *model.CheckResult) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	check.ID = utils.NewTimeUUID()
+	idx := sort.Search(len(r.s.results), func(i int) bool {
+		return lessUUID(check.ID, r.s.results[i].ID)
+	})
+	r.s.results = append(r.s.results, model.CheckResult{})
+	copy(r.s.results[idx+1:], r.s.results[idx:])
+	r.s.results[idx] = copyCheckResult(*check)
+	return nil
+}
+
+// Search the CheckResults in the repo, newest first. See model.CheckResultQuery
+// for the available filters.
+func (r *CheckResultRepo) Search(query model.CheckResultQuery) ([]model.CheckResult, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	result := []model.CheckResult{}
+	for i := len(r.s.results) - 1; i >= 0; i-- {
+		cr := r.s.results[i]
+		if query.Before != uuid.Nil && !lessUUID(cr.ID, query.Before) {
+			continue
+		}
+		if query.SubjectID != uuid.Nil && cr.SubjectID != query.SubjectID {
+			continue
+		}
+		if query.CheckID != uuid.Nil && cr.CheckID != query.CheckID {
+			continue
+		}
+		if len(query.Statuses) > 0 && !dbutil.ContainsStatus(query.Statuses, cr.Status) {
+			continue
+		}
+		if !query.Since.IsZero() && cr.Time.Before(query.Since) {
+			continue
+		}
+		if !query.Until.IsZero() && !cr.Time.Before(query.Until) {
+			continue
+		}
+		result = append(result, copyCheckResult(cr))
+		if query.Limit > 0 && len(result) == query.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// DeleteBySubject deletes all check results for a Subject. Used for cleanup
+// after deleting a Subject.
+func (r *CheckResultRepo) DeleteBySubject(subjectID uuid.UUID) error {
+	r.deleteWhere(func(cr model.CheckResult) bool { return cr.SubjectID == subjectID })
+	return nil
+}
+
+// DeleteByCheck deletes all check results for a Check. Used for cleanup
+// after deleting a Check.
+func (r *CheckResultRepo) DeleteByCheck(checkID uuid.UUID) error {
+	r.deleteWhere(func(cr model.CheckResult) bool { return cr.CheckID == checkID })
+	return nil
+}
+
+// DeleteBySubjectCheck deletes all check results for a given subject and check.
+// Used for cleanup after removing a role from a subject.
+func (r *CheckResultRepo) DeleteBySubjectCheck(id model.SubjectCheckID) error {
+	r.deleteWhere(func(cr model.CheckResult) bool { return cr.SubjectCheckID == id })
+	return nil
+}
+
+func (r *CheckResultRepo) deleteWhere(match func(model.CheckResult) bool) {
+	r.s.Lock()
+	defer r.s.Unlock()
+	kept := r.s.results[:0]
+	for _, cr := range r.s.results {
+		if !match(cr) {
+			kept = append(kept, cr)
+		}
+	}
+	r.s.results = kept
+}
+
+// DeleteBefore deletes all check results with a time before t. Used to expire
+// raw results once they have been rolled up.
+func (r *CheckResultRepo) DeleteBefore(t time.Time) error {
+	r.deleteWhere(func(cr model.CheckResult) bool { return cr.Time.Before(t) })
+	return nil
+}
+
+// rollupKey is a comparable form of model.CheckResultRollupID, as equal times
+// may not compare equal.
+type rollupKey struct {
+	SubjectID uuid.UUID
+	CheckID   uuid.UUID
+	Period    model.RollupPeriod
+	Start     int64
+}
+
+func keyForRollup(id model.CheckResultRollupID) rollupKey {
+	return rollupKey{id.SubjectID, id.CheckID, id.Period, id.Start.UnixNano()}
+}
+
+// CheckResultRollupRepo acts as a repository of CheckResultRollups in memory.
+type CheckResultRollupRepo struct {
+	s *store
+}
+
+func (r *CheckResultRollupRepo) Count() (int, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return len(r.s.rollups), nil
+}
+
+// Find a CheckResultRollup by its ID.
+func (r *CheckResultRollupRepo) Find(id model.CheckResultRollupID) (model.CheckResultRollup, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	rollup, ok := r.s.rollups[keyForRollup(id)]
+	if !ok {
+		return model.CheckResultRollup{}, model.ErrNotFound
+	}
+	return rollup, nil
+}
+
+// Upsert a CheckResultRollup in the repo.
+func (r *CheckResultRollupRepo) Upsert(rollup model.CheckResultRollup) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	r.s.rollups[keyForRollup(rollup.ID)] = rollup
+	return nil
+}
+
+// Search the CheckResultRollups in the repo, in order of start time. See
+// model.CheckResultRollupQuery for the available filters.
+func (r *CheckResultRollupRepo) Search(query model.CheckResultRollupQuery) ([]model.CheckResultRollup, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	result := []model.CheckResultRollup{}
+	for _, rollup := range r.s.rollups {
+		id := rollup.ID
+		if (query.SubjectID == uuid.Nil || id.SubjectID == query.SubjectID) &&
+			(query.CheckID == uuid.Nil || id.CheckID == query.CheckID) &&
+			(query.Period == 0 || id.Period == query.Period) &&
+			(query.Since.IsZero() || !id.Start.Before(query.Since)) &&
+			(query.Until.IsZero() || id.Start.Before(query.Until)) {
+			result = append(result, rollup)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID.Start.Before(result[j].ID.Start) })
+	return result, nil
+}
+
+// DeleteBefore deletes all rollups of the given period starting before t.
+func (r *CheckResultRollupRepo) DeleteBefore(period model.RollupPeriod, t time.Time) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	for key, rollup := range r.s.rollups {
+		if rollup.ID.Period == period && rollup.ID.Start.Before(t) {
+			delete(r.s.rollups, key)
+		}
+	}
+	return nil
+}
+
+// CheckStateRepo acts as a repository of CheckStates in memory.
+type CheckStateRepo struct {
+	s *store
+}
+
+func (r *CheckStateRepo) Count() (int, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return len(r.s.states), nil
+}
+
+// Find a CheckState by its ID.
+func (r *CheckStateRepo) Find(id model.SubjectCheckID) (model.CheckState, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	state, ok := r.s.states[id]
+	if !ok {
+		return model.CheckState{}, model.ErrNotFound
+	}
+	return copyCheckState(state), nil
+}
+
+// ForOwner returns all CheckStates owned by a given coordinator.
+func (r *CheckStateRepo) ForOwner(owner uuid.UUID) ([]model.CheckState, error) {
+	return r.filter(func(state model.CheckState) bool {
+		return state.Owner == owner
+	}), nil
+}
+
+// ForTypes returns all CheckStates of the given types.
+func (r *CheckStateRepo) ForTypes(types []model.CheckType) ([]model.CheckState, error) {
+	return r.filter(func(state model.CheckState) bool {
+		return dbutil.ContainsCheckType(types, state.Type)
+	}), nil
+}
+
+// CoordinatorWorkload gets the number of checks owned by each coordinator.
+func (r *CheckStateRepo) CoordinatorWorkload() (model.CoordinatorLoad, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	result := model.CoordinatorLoad{}
+	for _, state := range r.s.states {
+		if state.Owner != uuid.Nil {
+			result[state.Owner]++
+		}
+	}
+	return result, nil
+}
+
+// InStatusRoles returns all CheckStates for the given statuses and roles.
+func (r *CheckStateRepo) InStatusRoles(statuses []model.CheckStatus, roles []string) ([]model.CheckState, error) {
+	result := r.filter(func(state model.CheckState) bool {
+		return dbutil.ContainsStatus(statuses, state.Status) && dbutil.ContainsAll(state.Roles, roles)
+	})
+	if len(result) == 0 {
+		return result, model.ErrNotFound
+	}
+	return result, nil
+}
+
+// CountInRolesByStatus returns the count of distinct Subjects for the given role, by status.
+func (r *CheckStateRepo) CountInRolesByStatus(roles []string) (model.StatusSummary, error) {
+	var out model.StatusSummary
+	maxStatus := map[uuid.UUID]model.CheckStatus{}
+	for _, state := range r.filter(func(state model.CheckState) bool {
+		return dbutil.ContainsAll(state.Roles, roles)
+	}) {
+		if status, ok := maxStatus[state.ID.SubjectID]; !ok || state.Status > status {
+			maxStatus[state.ID.SubjectID] = state.Status
+		}
+	}
+	for _, status := range maxStatus {
+		switch status {
+		case model.StatusOK:
+			out.Ok++
+		case model.StatusWarning:
+			out.Warning++
+		case model.StatusCritical:
+			out.Critical++
+		}
+	}
+	return out, nil
+}
+
+// Upsert a CheckState in the repo.
+func (r *CheckStateRepo) Upsert(check model.CheckState) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	r.s.states[check.ID] = copyCheckState(check)
+	return nil
+}
+
+// DeleteBySubject deletes all check states for a Subject. Used for cleanup
+// after deleting a Subject.
+func (r *CheckStateRepo) DeleteBySubject(subjectID uuid.UUID) error {
+	r.deleteWhere(func(id model.SubjectCheckID) bool { return id.SubjectID == subjectID })
+	return nil
+}
+
+// DeleteByCheck deletes all check states for a Check. Used for cleanup after
+// deleting a Check.
+func (r *CheckStateRepo) DeleteByCheck(checkID uuid.UUID) error {
+	r.deleteWhere(func(id model.SubjectCheckID) bool { return id.CheckID == checkID })
+	return nil
+}
+
+// DeleteBySubjectCheck deletes the check state for a given subject and check.
+// Used for cleanup after removing a role from a subject.
+func (r *CheckStateRepo) DeleteBySubjectCheck(id model.SubjectCheckID) error {
+	r.deleteWhere(func(stateID model.SubjectCheckID) bool { return stateID == id })
+	return nil
+}
+
+func (r *CheckStateRepo) filter(match func(model.CheckState) bool) []model.CheckState {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	result := []model.CheckState{}
+	for _, state := range r.s.states {
+		if match(state) {
+			result = append(result, copyCheckState(state))
+		}
+	}
+	return result
+}
+
+func (r *CheckStateRepo) deleteWhere(match func(model.SubjectCheckID) bool) {
+	r.s.Lock()
+	defer r.s.Unlock()
+	for id := range r.s.states {
+		if match(id) {
+			delete(r.s.states, id)
+		}
+	}
+}
+
+// AlertRepo acts as a repository of Alerts in memory.
+type AlertRepo struct {
+	s *store
+}
+
+func (r *AlertRepo) Count() (int, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return len(r.s.alerts), nil
+}
+
+// Create a new Alert in the repo.
+func (r *AlertRepo) Create(alert *model.Alert) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	alert.ID = utils.NewTimeUUID()
+	r.s.alerts[alert.ID] = copyAlert(*alert)
+	return nil
+}
+
+// Update an Alert in the repo.
+func (r *AlertRepo) Update(alert model.Alert) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	r.s.alerts[alert.ID] = copyAlert(alert)
+	return nil
+}
+
+// Delete an Alert from the repo.
+func (r *AlertRepo) Delete(alertID uuid.UUID) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	if _, ok := r.s.alerts[alertID]; !ok {
+		return model.ErrNotFound
+	}
+	delete(r.s.alerts, alertID)
+	return nil
+}
+
+// Find an Alert by its ID.
+func (r *AlertRepo) Find(id uuid.UUID) (model.Alert, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	alert, ok := r.s.alerts[id]
+	if !ok {
+		return model.Alert{}, model.ErrNotFound
+	}
+	return copyAlert(alert), nil
+}
+
+// Search the Alerts in the repo by name regular expression and role/tag
+// (combined with AND). Any parameter left blank will be ignored.
+func (r *AlertRepo) Search(name, role, tag string) ([]model.Alert, error) {
+	re, err := dbutil.NameRegexp(name)
+	if err != nil {
+		return []model.Alert{}, err
+	}
+	return r.filter(func(alert model.Alert) bool {
+		return (re == nil || re.MatchString(alert.Name)) &&
+			(role == "" || dbutil.Contains(alert.Roles, role)) &&
+			(tag == "" || dbutil.Contains(alert.Tags, tag))
+	}), nil
+}
+
+// FindByFilter returns alerts that match the given roles and tags.
+func (r *AlertRepo) FindByFilter(roles, tags []string) ([]model.Alert, error) {
+	roleSet := collections.NewStringSet(roles...)
+	tagSet := collections.NewStringSet(tags...)
+	result := r.filter(func(alert model.Alert) bool {
+		return roleSet.ContainsAny(alert.Roles...) && tagSet.ContainsAny(alert.Tags...)
+	})
+	if len(result) == 0 {
+		return result, model.ErrNotFound
+	}
+	return result, nil
+}
+
+func (r *AlertRepo) filter(match func(model.Alert) bool) []model.Alert {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	result := []model.Alert{}
+	for _, alert := range r.s.alerts {
+		if match(alert) {
+			result = append(result, copyAlert(alert))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return lessUUID(result[i].ID, result[j].ID) })
+	return result
+}
+
+// PeriodRepo acts as a repository of Periods in memory.
+type PeriodRepo struct {
+	s *store
+}
+
+func (r *PeriodRepo) Count() (int, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return len(r.s.periods), nil
+}
+
+// Create a new Period in the repo.
+func (r *PeriodRepo) Create(period *model.Period) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	period.ID = utils.NewTimeUUID()
+	r.s.periods[period.ID] = copyPeriod(*period)
+	return nil
+}
+
+// Update a Period in the repo.
+func (r *PeriodRepo) Update(period model.Period) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	r.s.periods[period.ID] = copyPeriod(period)
+	return nil
+}
+
+// Delete a Period from the repo.
+func (r *PeriodRepo) Delete(periodID uuid.UUID) error {
+	r.s.Lock()
+	defer r.s.Unlock()
+	if _, ok := r.s.periods[periodID]; !ok {
+		return model.ErrNotFound
+	}
+	delete(r.s.periods, periodID)
+	return nil
+}
+
+// Find a Period by its ID.
+func (r *PeriodRepo) Find(id uuid.UUID) (model.Period, error) {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	period, ok := r.s.periods[id]
+	if !ok {
+		return model.Period{}, model.ErrNotFound
+	}
+	return copyPeriod(period), nil
+}
+
+// Search the Periods in the repo by name regular expression and role/tag
+// (combined with AND). Any parameter left blank will be ignored.
+func (r *PeriodRepo) Search(name, role, tag string) ([]model.Period, error) {
+	re, err := dbutil.NameRegexp(name)
+	if err != nil {
+		return []model.Period{}, err
+	}
+	return r.filter(func(period model.Period) bool {
+		return (re == nil || re.MatchString(period.Name)) &&
+			(role == "" || dbutil.Contains(period.Roles, role)) &&
+			(tag == "" || dbutil.Contains(period.Tags, tag))
+	}), nil
+}
+
+// FindForSubject returns all active Periods that affect a subject by roles or ID for the given types (or all types if no types given).
+func (r *PeriodRepo) FindForSubject(subject model.Subject, types []model.PeriodType) ([]model.Period, error) {
+	now := time.Now()
+	roleSet := collections.NewStringSet(subject.Roles...)
+	return dbutil.NotFoundIfEmptyPeriods(r.filter(func(period model.Period) bool {
+		return dbutil.IsActive(period, now, types) &&
+			len(period.Tags) == 0 &&
+			(roleSet.ContainsAny(period.Roles...) || dbutil.ContainsUUID(period.Subjects, subject.ID))
+	}), nil)
+}
+
+// FindForSubjectChecks returns all active Periods that affect a subject by roles or ID for the given tags and types (or all types if no types given).
+func (r *PeriodRepo) FindForSubjectChecks(subject model.Subject, tags []string, types []model.PeriodType) ([]model.Period, error) {
+	now := time.Now()
+	roleSet := collections.NewStringSet(subject.Roles...)
+	tagSet := collections.NewStringSet(tags...)
+	return dbutil.NotFoundIfEmptyPeriods(r.filter(func(period model.Period) bool {
+		return dbutil.IsActive(period, now, types) &&
+			tagSet.ContainsAny(period.Tags...) &&
+			(roleSet.ContainsAny(period.Roles...) ||
+				dbutil.ContainsUUID(period.Subjects, subject.ID) ||
+				(len(period.Roles) == 0 && len(period.Subjects) == 0))
+	}), nil)
+}
+
+// FindByType returns all active periods for the given types (or all types if no types given).
+func (r *PeriodRepo) FindByType(types []model.PeriodType) ([]model.Period, error) {
+	now := time.Now()
+	return dbutil.NotFoundIfEmptyPeriods(r.filter(func(period model.Period) bool {
+		return dbutil.IsActive(period, now, types)
+	}), nil)
+}
+
+func (r *PeriodRepo) filter(match func(model.Period) bool) []model.Period {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	result := []model.Period{}
+	for _, period := range r.s.periods {
+		if match(period) {
+			result = append(result, copyPeriod(period))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return lessUUID(result[i].ID, result[j].ID) })
+	return result
+}
+
+func lessUUID(a, b uuid.UUID) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+// Entities are copied on the way in and out of the store, so that callers
+// never share slices or maps with the stored data. Nil slices and maps come
+// back empty, as they would from a round trip through MongoDB.
+
+func copySubject(subject model.Subject) model.Subject {
+	subject.Roles = copyStrings(subject.Roles)
+	return subject
+}
+
+func copyCheck(check model.Check) model.Check {
+	check.Parameters = copyParams(check.Parameters)
+	check.Roles = copyStrings(check.Roles)
+	check.Tags = copyStrings(check.Tags)
+	return check
+}
+
+func copyCheckResult(result model.CheckResult) model.CheckResult {
+	// Metrics are omitted from storage when empty, so nil stays nil.
+	if len(result.Metrics) > 0 {
+		metrics := make(map[string]float64, len(result.Metrics))
+		for k, v := range result.Metrics {
+			metrics[k] = v
+		}
+		result.Metrics = metrics
+	} else {
+		result.Metrics = nil
+	}
+	return result
+}
+
+func copyCheckState(state model.CheckState) model.CheckState {
+	state.Roles = copyStrings(state.Roles)
+	state.Tags = copyStrings(state.Tags)
+	reminders := make(map[string]time.Time, len(state.Reminders))
+	for k, v := range state.Reminders {
+		reminders[k] = v
+	}
+	state.Reminders = reminders
+	if state.Escalations != nil {
+		escalations := make(map[string]time.Time, len(state.Escalations))
+		for k, v := range state.Escalations {
+			escalations[k] = v
+		}
+		state.Escalations = escalations
+	}
+	return state
+}
+
+func copyAlert(alert model.Alert) model.Alert {
+	alert.Parameters = copyParams(alert.Parameters)
+	alert.Escalation = append([]model.EscalationStep(nil), alert.Escalation...)
+	alert.Roles = copyStrings(alert.Roles)
+	alert.Tags = copyStrings(alert.Tags)
+	return alert
+}
+
+func copyPeriod(period model.Period) model.Period {
+	period.Parameters = copyParams(period.Parameters)
+	period.Roles = copyStrings(period.Roles)
+	period.Tags = copyStrings(period.Tags)
+	period.Subjects = append([]uuid.UUID{}, period.Subjects...)
+	return period
+}
+
+func copyStrings(in []string) []string {
+	return append([]string{}, in...)
+}
+
+func copyParams(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
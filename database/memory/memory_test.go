@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+)
+
+// Validate that context & repos implement the desired interfaces.
+func TestInterfaceImplementation(t *testing.T) {
+	var _ model.AppContextFactory = (*AppContextFactory)(nil)
+	var _ model.AppContext = (*AppContext)(nil)
+	var _ model.SubjectRepo = (*SubjectRepo)(nil)
+	var _ model.CheckRepo = (*CheckRepo)(nil)
+	var _ model.AlertRepo = (*AlertRepo)(nil)
+	var _ model.PeriodRepo = (*PeriodRepo)(nil)
+	var _ model.TagRepo = (*CheckRepo)(nil)
+	var _ model.RoleRepo = (*SubjectRepo)(nil)
+	var _ model.CheckStateRepo = (*CheckStateRepo)(nil)
+	var _ model.CheckResultRepo = (*CheckResultRepo)(nil)
+	var _ model.CheckResultRollupRepo = (*CheckResultRollupRepo)(nil)
+}
+
+func TestCheckResultSearch(t *testing.T) {
+	ctx, _ := InitStore().Get()
+	repo := ctx.CheckResultRepo()
+	subject := model.Subject{Name: "test"}
+	ctx.SubjectRepo().Create(&subject)
+	for i := 0; i < 5; i++ {
+		cr := model.CheckResult{Time: time.Now(), Status: model.CheckStatus(i % 2)}
+		cr.SubjectID = subject.ID
+		repo.Create(&cr)
+	}
+
+	results, err := repo.Search(model.CheckResultQuery{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if !lessUUID(results[1].ID, results[0].ID) {
+		t.Error("Expected results newest first")
+	}
+	rest, _ := repo.Search(model.CheckResultQuery{Before: results[1].ID})
+	if len(rest) != 3 {
+		t.Errorf("Expected 3 results before cursor, got %d", len(rest))
+	}
+	ok, _ := repo.Search(model.CheckResultQuery{SubjectID: subject.ID, Statuses: []model.CheckStatus{model.StatusOK}})
+	if len(ok) != 2 {
+		t.Errorf("Expected 2 OK results, got %d", len(ok))
+	}
+}
+
+func TestCopyOnWrite(t *testing.T) {
+	ctx, _ := InitStore().Get()
+	check := model.Check{Name: "test", Roles: []string{"a"}}
+	ctx.CheckRepo().Create(&check)
+	check.Roles[0] = "b"
+	stored, err := ctx.CheckRepo().Find(check.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Roles[0] != "a" {
+		t.Errorf("Stored check modified through caller's slice: %v", stored.Roles)
+	}
+}
+
+func TestCountInRolesByStatus(t *testing.T) {
+	ctx, _ := InitStore().Get()
+	repo := ctx.CheckStateRepo()
+	subjects := []model.Subject{{Name: "a"}, {Name: "b"}}
+	for i := range subjects {
+		ctx.SubjectRepo().Create(&subjects[i])
+	}
+	check := model.Check{Name: "c"}
+	ctx.CheckRepo().Create(&check)
+	check2 := model.Check{Name: "d"}
+	ctx.CheckRepo().Create(&check2)
+
+	states := []model.CheckState{
+		{ID: model.SubjectCheckID{SubjectID: subjects[0].ID, CheckID: check.ID}, Status: model.StatusOK, Roles: []string{"web"}},
+		{ID: model.SubjectCheckID{SubjectID: subjects[0].ID, CheckID: check2.ID}, Status: model.StatusCritical, Roles: []string{"web"}},
+		{ID: model.SubjectCheckID{SubjectID: subjects[1].ID, CheckID: check.ID}, Status: model.StatusOK, Roles: []string{"web", "db"}},
+	}
+	for _, state := range states {
+		repo.Upsert(state)
+	}
+
+	summary, _ := repo.CountInRolesByStatus([]string{"web"})
+	if summary.Ok != 1 || summary.Critical != 1 || summary.Warning != 0 {
+		t.Errorf("Unexpected summary for web: %+v", summary)
+	}
+	summary, _ = repo.CountInRolesByStatus([]string{"web", "db"})
+	if summary.Ok != 1 || summary.Critical != 0 {
+		t.Errorf("Unexpected summary for web+db: %+v", summary)
+	}
+}
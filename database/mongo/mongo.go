@@ -25,9 +25,29 @@ func InitConnection(host, db, user, pass string) (model.AppContextFactory, error
 	if err != nil {
 		return nil, err
 	}
+	if err = ensureIndexes(session.DB(db)); err != nil {
+		session.Close()
+		return nil, err
+	}
 	return AppContextFactory{session, db}, nil
 }
 
+// ensureIndexes creates the indexes backing the CheckResult searches: by
+// subject and check, and by status, both newest first.
+func ensureIndexes(db *mgo.Database) error {
+	results := db.C("CheckResults")
+	indexes := []mgo.Index{
+		{Key: []string{"subjectcheckid.subjectid", "subjectcheckid.checkid", "-_id"}, Background: true},
+		{Key: []string{"status", "-_id"}, Background: true},
+	}
+	for _, index := range indexes {
+		if err := results.EnsureIndex(index); err != nil {
+			return convertError(err)
+		}
+	}
+	return nil
+}
+
 type AppContextFactory struct {
 	session *mgo.Session
 	db      string
@@ -53,6 +73,7 @@ type AppContext struct {
 	subjectRepo     *SubjectRepo
 	checkRepo       *CheckRepo
 	checkResultRepo *CheckResultRepo
+	rollupRepo      *CheckResultRollupRepo
 	checkStateRepo  *CheckStateRepo
 	alertRepo       *AlertRepo
 	periodRepo      *PeriodRepo
@@ -82,6 +103,15 @@ func (c *AppContext) CheckResultRepo() model.CheckResultRepo {
 	return c.checkResultRepo
 }
 
+// CheckResultRollupRepo returns a pointer to a CheckResultRollupRepo in the
+// current context.
+func (c *AppContext) CheckResultRollupRepo() model.CheckResultRollupRepo {
+	if c.rollupRepo == nil {
+		c.rollupRepo = &CheckResultRollupRepo{c.DB.C("CheckResultRollups")}
+	}
+	return c.rollupRepo
+}
+
 // CheckStateRepo returns a pointer to a CheckStateRepo in the current context.
 func (c *AppContext) CheckStateRepo() model.CheckStateRepo {
 	if c.checkStateRepo == nil {
@@ -406,6 +436,41 @@ func (r *CheckResultRepo) Create(check *model.CheckResult) error {
 	return convertError(err)
 }
 
+// Search the CheckResults in the repo, newest first. See model.CheckResultQuery
+// for the available filters.
+func (r *CheckResultRepo) Search(query model.CheckResultQuery) ([]model.CheckResult, error) {
+	result := []model.CheckResult{}
+	q := bson.M{}
+	if query.SubjectID != uuid.Nil {
+		q["subjectcheckid.subjectid"] = query.SubjectID
+	}
+	if query.CheckID != uuid.Nil {
+		q["subjectcheckid.checkid"] = query.CheckID
+	}
+	if len(query.Statuses) > 0 {
+		q["status"] = bson.M{"$in": query.Statuses}
+	}
+	timeRange := bson.M{}
+	if !query.Since.IsZero() {
+		timeRange["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		timeRange["$lt"] = query.Until
+	}
+	if len(timeRange) > 0 {
+		q["time"] = timeRange
+	}
+	if query.Before != uuid.Nil {
+		q["_id"] = bson.M{"$lt": query.Before}
+	}
+	mq := r.c.Find(q).Sort("-_id")
+	if query.Limit > 0 {
+		mq = mq.Limit(query.Limit)
+	}
+	err := mq.All(&result)
+	return result, convertError(err)
+}
+
 // DeleteBySubject deletes all check results for a Subject. Used for cleanup
 // after deleting a Subject.
 func (r *CheckResultRepo) DeleteBySubject(subjectID uuid.UUID) error {
@@ -427,6 +492,70 @@ func (r *CheckResultRepo) DeleteBySubjectCheck(id model.SubjectCheckID) error {
 	return convertError(err)
 }
 
+// DeleteBefore deletes all check results with a time before t. Used to expire
+// raw results once they have been rolled up.
+func (r *CheckResultRepo) DeleteBefore(t time.Time) error {
+	_, err := r.c.RemoveAll(bson.M{"time": bson.M{"$lt": t}})
+	return convertError(err)
+}
+
+// CheckResultRollupRepo acts as a repository of CheckResultRollups in the
+// database.
+type CheckResultRollupRepo struct {
+	c *mgo.Collection
+}
+
+func (r *CheckResultRollupRepo) Count() (int, error) {
+	return r.c.Count()
+}
+
+// Find a CheckResultRollup by its ID.
+func (r *CheckResultRollupRepo) Find(id model.CheckResultRollupID) (model.CheckResultRollup, error) {
+	var result model.CheckResultRollup
+	err := r.c.FindId(id).One(&result)
+	return result, convertError(err)
+}
+
+// Upsert a CheckResultRollup in the repo.
+func (r *CheckResultRollupRepo) Upsert(rollup model.CheckResultRollup) error {
+	_, err := r.c.UpsertId(rollup.ID, rollup)
+	return convertError(err)
+}
+
+// Search the CheckResultRollups in the repo, in order of start time. See
+// model.CheckResultRollupQuery for the available filters.
+func (r *CheckResultRollupRepo) Search(query model.CheckResultRollupQuery) ([]model.CheckResultRollup, error) {
+	result := []model.CheckResultRollup{}
+	q := bson.M{}
+	if query.SubjectID != uuid.Nil {
+		q["_id.subjectid"] = query.SubjectID
+	}
+	if query.CheckID != uuid.Nil {
+		q["_id.checkid"] = query.CheckID
+	}
+	if query.Period != 0 {
+		q["_id.period"] = query.Period
+	}
+	start := bson.M{}
+	if !query.Since.IsZero() {
+		start["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		start["$lt"] = query.Until
+	}
+	if len(start) > 0 {
+		q["_id.start"] = start
+	}
+	err := r.c.Find(q).Sort("_id.start").All(&result)
+	return result, convertError(err)
+}
+
+// DeleteBefore deletes all rollups of the given period starting before t.
+func (r *CheckResultRollupRepo) DeleteBefore(period model.RollupPeriod, t time.Time) error {
+	_, err := r.c.RemoveAll(bson.M{"_id.period": period, "_id.start": bson.M{"$lt": t}})
+	return convertError(err)
+}
+
 // CheckStateRepo acts as a repository of CheckStates in the database.
 type CheckStateRepo struct {
 	c *mgo.Collection
@@ -18,4 +18,5 @@ func TestInterfaceImplementation(t *testing.T) {
 	var _ model.RoleRepo = (*SubjectRepo)(nil)
 	var _ model.CheckStateRepo = (*CheckStateRepo)(nil)
 	var _ model.CheckResultRepo = (*CheckResultRepo)(nil)
+	var _ model.CheckResultRollupRepo = (*CheckResultRollupRepo)(nil)
 }
@@ -2,6 +2,8 @@ package model
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aprice/observatory/utils"
@@ -91,6 +93,32 @@ const (
 	CheckDisk
 	// CheckVersion type is a Coordinator version update check.
 	CheckVersion
+	// CheckRemoteHTTP type is an HTTP check executed by a coordinator.
+	CheckRemoteHTTP
+	// CheckRemotePort type is a port check executed by a coordinator.
+	CheckRemotePort
+	// CheckRemotePing type is a TCP connection round trip check executed by a
+	// coordinator, for hosts which cannot be reached by ICMP.
+	CheckRemotePing
+	// CheckRemoteDNS type is a DNS record check executed by a coordinator.
+	CheckRemoteDNS
+	// CheckDNS type is a DNS record check.
+	CheckDNS
+	// CheckProcess type is a running process count and resource use check.
+	CheckProcess
+	// CheckLog type is a count of new lines in a log file matching a pattern.
+	CheckLog
+	// CheckFile type is a check that a recent file of a reasonable size exists.
+	CheckFile
+	// CheckPassive type is a check whose results are submitted by an external
+	// job, and which expires if no result is submitted within its interval.
+	CheckPassive
+	// CheckPrometheus type is a threshold check on a metric scraped from a
+	// Prometheus metrics page.
+	CheckPrometheus
+	// CheckRemotePrometheus type is a Prometheus metric check executed by a
+	// coordinator.
+	CheckRemotePrometheus
 )
 
 func (ct CheckType) String() string {
@@ -111,6 +139,28 @@ func (ct CheckType) String() string {
 		return "Disk"
 	case CheckVersion:
 		return "Version"
+	case CheckRemoteHTTP:
+		return "RemoteHTTP"
+	case CheckRemotePort:
+		return "RemotePort"
+	case CheckRemotePing:
+		return "RemotePing"
+	case CheckRemoteDNS:
+		return "RemoteDNS"
+	case CheckDNS:
+		return "DNS"
+	case CheckProcess:
+		return "Process"
+	case CheckLog:
+		return "Log"
+	case CheckFile:
+		return "File"
+	case CheckPassive:
+		return "Passive"
+	case CheckPrometheus:
+		return "Prometheus"
+	case CheckRemotePrometheus:
+		return "RemotePrometheus"
 	default:
 		return "None"
 	}
@@ -124,12 +174,23 @@ var LocalCheckTypes = []CheckType{
 	CheckMemory,
 	CheckCPU,
 	CheckDisk,
+	CheckDNS,
+	CheckProcess,
+	CheckLog,
+	CheckFile,
+	CheckPrometheus,
 }
 
 // RemoteCheckTypes lists the Checks that are executed remotely by a coordinator.
 var RemoteCheckTypes = []CheckType{
 	CheckAgentDown,
 	CheckVersion,
+	CheckRemoteHTTP,
+	CheckRemotePort,
+	CheckRemotePing,
+	CheckRemoteDNS,
+	CheckPassive,
+	CheckRemotePrometheus,
 }
 
 // Check describes a single health check.
@@ -182,6 +243,9 @@ const (
 	StatusFailed = -1
 )
 
+// CheckStatuses lists every CheckStatus.
+var CheckStatuses = []CheckStatus{StatusNone, StatusOK, StatusWarning, StatusCritical, StatusFailed}
+
 func (cs CheckStatus) String() string {
 	switch cs {
 	case StatusOK:
@@ -197,6 +261,24 @@ func (cs CheckStatus) String() string {
 	}
 }
 
+// ParseCheckStatus parses a CheckStatus from either its numeric value or its
+// name (case-insensitive).
+func ParseCheckStatus(raw string) (CheckStatus, error) {
+	if i, err := strconv.Atoi(raw); err == nil {
+		status := CheckStatus(i)
+		if status < StatusFailed || status > StatusCritical {
+			return StatusNone, fmt.Errorf("invalid status: %d", i)
+		}
+		return status, nil
+	}
+	for _, status := range CheckStatuses {
+		if strings.EqualFold(raw, status.String()) {
+			return status, nil
+		}
+	}
+	return StatusNone, fmt.Errorf("invalid status: %q", raw)
+}
+
 // SubjectCheckID is a composite of subject ID and check ID
 type SubjectCheckID struct {
 	SubjectID uuid.UUID
@@ -207,17 +289,38 @@ func (scid SubjectCheckID) String() string {
 	return fmt.Sprintf("%s/%s", scid.SubjectID.String(), scid.CheckID.String())
 }
 
+// CheckOutput is the outcome of executing a check: its status, a
+// human-readable message, and any named numeric performance values.
+type CheckOutput struct {
+	Status  CheckStatus
+	Message string
+	Metrics map[string]float64
+}
+
 // CheckResult describes a single result of a single health check on a subject.
 type CheckResult struct {
 	SubjectCheckID
-	ID     uuid.UUID `bson:"_id"`
-	Time   time.Time
-	Status CheckStatus
+	ID       uuid.UUID `bson:"_id"`
+	Time     time.Time
+	Status   CheckStatus
+	Message  string             `json:",omitempty" bson:",omitempty"`
+	Duration time.Duration      `json:",omitempty" bson:",omitempty"`
+	Metrics  map[string]float64 `json:",omitempty" bson:",omitempty"`
 }
 
 // NewCheckResult creates and initializes a new CheckResult.
 func NewCheckResult(subjectID, checkID uuid.UUID, time time.Time, result CheckStatus) CheckResult {
-	return CheckResult{SubjectCheckID{subjectID, checkID}, utils.NewTimeUUID(), time, result}
+	return CheckResult{SubjectCheckID: SubjectCheckID{subjectID, checkID}, ID: utils.NewTimeUUID(), Time: time, Status: result}
+}
+
+// NewCheckResultFromOutput creates a new CheckResult from the output of a
+// check which took the given duration to execute.
+func NewCheckResultFromOutput(subjectID, checkID uuid.UUID, time time.Time, output CheckOutput, duration time.Duration) CheckResult {
+	result := NewCheckResult(subjectID, checkID, time, output.Status)
+	result.Message = output.Message
+	result.Metrics = output.Metrics
+	result.Duration = duration
+	return result
 }
 
 // GetModified returns the creation date of the CheckResult, as they are immutable.
@@ -225,12 +328,249 @@ func (cr CheckResult) GetModified() time.Time {
 	return cr.Time
 }
 
+// CheckResultQuery describes a search of CheckResults. Zero-valued fields are
+// ignored. Results are returned newest first.
+type CheckResultQuery struct {
+	SubjectID uuid.UUID
+	CheckID   uuid.UUID
+	Statuses  []CheckStatus
+	// Since is the inclusive lower bound of result times.
+	Since time.Time
+	// Until is the exclusive upper bound of result times.
+	Until time.Time
+	// Before is a paging cursor; only results with an ID lower than Before
+	// (i.e. recorded earlier) are returned.
+	Before uuid.UUID
+	Limit  int
+}
+
+// CheckResultPage is a single page of CheckResults from a query. Next is the
+// cursor for the following page, or blank if there are no more results.
+type CheckResultPage struct {
+	Results []CheckResult
+	Next    string
+}
+
+// GetModified returns the latest time of any CheckResult in the page.
+func (crp CheckResultPage) GetModified() time.Time {
+	ret := time.Time{}
+	for _, cr := range crp.Results {
+		ret = utils.LaterDate(ret, cr.Time)
+	}
+	return ret
+}
+
+// RollupPeriod is an enumeration of CheckResultRollup granularities.
+type RollupPeriod int
+
+const (
+	// RollupHourly aggregates one hour of check results.
+	RollupHourly RollupPeriod = iota + 1
+	// RollupDaily aggregates one day (UTC) of check results.
+	RollupDaily
+)
+
+func (rp RollupPeriod) String() string {
+	switch rp {
+	case RollupHourly:
+		return "Hourly"
+	case RollupDaily:
+		return "Daily"
+	default:
+		return "None"
+	}
+}
+
+// Duration returns the length of time covered by a single rollup.
+func (rp RollupPeriod) Duration() time.Duration {
+	switch rp {
+	case RollupHourly:
+		return time.Hour
+	case RollupDaily:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// StatusTally holds a number (of results, seconds, etc.) for each CheckStatus.
+type StatusTally struct {
+	None     int64
+	OK       int64
+	Warning  int64
+	Critical int64
+	Failed   int64
+}
+
+// Add n to the tally for the given status.
+func (st *StatusTally) Add(status CheckStatus, n int64) {
+	switch status {
+	case StatusOK:
+		st.OK += n
+	case StatusWarning:
+		st.Warning += n
+	case StatusCritical:
+		st.Critical += n
+	case StatusFailed:
+		st.Failed += n
+	default:
+		st.None += n
+	}
+}
+
+// Get the tally for the given status.
+func (st StatusTally) Get(status CheckStatus) int64 {
+	switch status {
+	case StatusOK:
+		return st.OK
+	case StatusWarning:
+		return st.Warning
+	case StatusCritical:
+		return st.Critical
+	case StatusFailed:
+		return st.Failed
+	default:
+		return st.None
+	}
+}
+
+// Merge another tally into this one.
+func (st *StatusTally) Merge(other StatusTally) {
+	st.None += other.None
+	st.OK += other.OK
+	st.Warning += other.Warning
+	st.Critical += other.Critical
+	st.Failed += other.Failed
+}
+
+// Total of the tallies for all statuses.
+func (st StatusTally) Total() int64 {
+	return st.None + st.OK + st.Warning + st.Critical + st.Failed
+}
+
+// CheckResultRollupID identifies the subject, check, and time window of a
+// CheckResultRollup.
+type CheckResultRollupID struct {
+	SubjectID uuid.UUID
+	CheckID   uuid.UUID
+	Period    RollupPeriod
+	Start     time.Time
+}
+
+// CheckResultRollup aggregates the CheckResults of a single check on a single
+// subject over an hour or day, once the raw results have expired.
+type CheckResultRollup struct {
+	ID CheckResultRollupID `bson:"_id"`
+	// Counts is the number of results in each status.
+	Counts StatusTally
+	// Seconds is the time spent in each status, measured from each result to
+	// the next one. The last result rolled up holds to the end of its hour,
+	// and its status carries into the next hour until that hour's first result.
+	Seconds StatusTally
+	// LastStatus is the status in effect at the end of the window.
+	LastStatus CheckStatus
+	// Through is the ID of the newest CheckResult counted, so that rolling up
+	// the same results again replaces the rollup rather than counting them
+	// twice.
+	Through uuid.UUID
+}
+
+// GetModified returns the end of the rollup's time window.
+func (crr CheckResultRollup) GetModified() time.Time {
+	return crr.ID.Start.Add(crr.ID.Period.Duration())
+}
+
+// Merge another rollup's tallies into this one.
+func (crr *CheckResultRollup) Merge(other CheckResultRollup) {
+	crr.Counts.Merge(other.Counts)
+	crr.Seconds.Merge(other.Seconds)
+}
+
+// CheckResultRollupQuery describes a search of CheckResultRollups. Zero-valued
+// fields are ignored. Results are returned in order of start time.
+type CheckResultRollupQuery struct {
+	SubjectID uuid.UUID
+	CheckID   uuid.UUID
+	Period    RollupPeriod
+	// Since is the inclusive lower bound of rollup start times.
+	Since time.Time
+	// Until is the exclusive upper bound of rollup start times.
+	Until time.Time
+}
+
+// AvailabilityGrouping is an enumeration of ways to group an availability
+// report.
+type AvailabilityGrouping string
+
+const (
+	// GroupBySubjectCheck reports on each check of each subject.
+	GroupBySubjectCheck AvailabilityGrouping = ""
+	// GroupByRole reports on each subject role.
+	GroupByRole AvailabilityGrouping = "role"
+	// GroupByTag reports on each check tag.
+	GroupByTag AvailabilityGrouping = "tag"
+)
+
+// AvailabilityQuery describes the scope of an availability report. Zero-valued
+// filters are ignored.
+type AvailabilityQuery struct {
+	SubjectID uuid.UUID
+	CheckID   uuid.UUID
+	Role      string
+	Tag       string
+	Since     time.Time
+	Until     time.Time
+	GroupBy   AvailabilityGrouping
+}
+
+// StatusPercent holds the percentage of time spent in each status.
+type StatusPercent struct {
+	None     float64
+	OK       float64
+	Warning  float64
+	Critical float64
+	Failed   float64
+}
+
+// Availability describes the time a subject's check, or all checks in a role
+// or tag, spent in each status.
+type Availability struct {
+	Subject string `json:",omitempty"`
+	Check   string `json:",omitempty"`
+	Role    string `json:",omitempty"`
+	Tag     string `json:",omitempty"`
+	// Seconds is the time spent in each status, excluding blackout periods.
+	Seconds StatusTally
+	Percent StatusPercent
+}
+
+// AvailabilityReport lists Availability over a window of time.
+type AvailabilityReport struct {
+	Since time.Time
+	Until time.Time
+	Items []Availability
+}
+
+// GetModified returns the end of the report window.
+func (ar AvailabilityReport) GetModified() time.Time {
+	return ar.Until
+}
+
 // CheckResultDetail includes the full details of a CheckResult's Subject and
-// Check.
+// Check, and the status of the check before the result, for use in alerts.
 type CheckResultDetail struct {
 	CheckResult
-	Subject Subject
-	Check   Check
+	Subject        Subject
+	Check          Check
+	PreviousStatus CheckStatus
+	// OutageDuration is how long the check had been in its previous status, if
+	// that was a problem status, or zero otherwise.
+	OutageDuration time.Duration
+}
+
+// Recovered returns whether the result is a return to OK from a problem status.
+func (crd CheckResultDetail) Recovered() bool {
+	return crd.Status == StatusOK && crd.PreviousStatus > StatusOK
 }
 
 // GetModified returns the most recent of the CheckResult date, the Subject
@@ -250,6 +590,12 @@ type CheckState struct {
 	Type          CheckType
 	Owner         uuid.UUID            `json:"Owner,omitempty",bson:"omitempty"`
 	Reminders     map[string]time.Time `json:"-",bson:"omitempty"`
+	// Escalations records when each escalated Alert, by ID, fired for the
+	// current problem.
+	Escalations map[string]time.Time `json:"-" bson:",omitempty"`
+	// Started is when the job reporting the check's results by heartbeat
+	// started, if it has not reported a result since.
+	Started time.Time `bson:",omitempty"`
 }
 
 // GetModified returns the last updated date of the CheckState.
@@ -293,12 +639,20 @@ const (
 	// recipients, while "subject" and "body" are both templates for creating
 	// the message. See https://golang.org/pkg/text/template/.
 	AlertEmail
-	// AlertPagerDuty type raises incidents using the PagerDuty API. Parameter
-	// "service" gives the service endpoint to use, while "subject" and "body"
+	// AlertPagerDuty type raises incidents using the PagerDuty Events API v2,
+	// and resolves them when the check recovers. Parameter "service" gives the
+	// integration key of the PagerDuty service, while "subject" and "body"
 	// work as in AlertEmail.
 	AlertPagerDuty
 	// AlertMock is used for integration testing ONLY.
 	AlertMock
+	// AlertWebhook type sends an HTTP request. Parameter "url" gives the URL
+	// to request, while "headers" and "body" are templates as in AlertEmail.
+	AlertWebhook
+	// AlertChat type posts a colour-coded message to a Slack-compatible
+	// incoming webhook. Parameter "url" gives the webhook URL, while "subject"
+	// is a template as in AlertEmail for the message text.
+	AlertChat
 )
 
 func (at AlertType) String() string {
@@ -309,6 +663,10 @@ func (at AlertType) String() string {
 		return "Email"
 	case AlertPagerDuty:
 		return "PagerDuty"
+	case AlertWebhook:
+		return "Webhook"
+	case AlertChat:
+		return "Chat"
 	default:
 		return "None"
 	}
@@ -321,9 +679,19 @@ type Alert struct {
 	Type             AlertType
 	Parameters       map[string]string
 	ReminderInterval int
-	Roles            []string
-	Tags             []string
-	Modified         time.Time
+	// Escalation lists further Alerts to fire, in order, if a check this
+	// Alert fired for is still Critical after each step's delay.
+	Escalation []EscalationStep
+	// An Alert fires again when a check it fired for returns to OK, unless
+	// SkipRecovery is set. RecoverySubject and RecoveryBody, if set, replace
+	// the "subject" and "body" templates in the Parameters for the recovery
+	// notification.
+	SkipRecovery    bool
+	RecoverySubject string
+	RecoveryBody    string
+	Roles           []string
+	Tags            []string
+	Modified        time.Time
 }
 
 // ReminderDuration returns the Alert's ReminderInterval as a time.Duration.
@@ -331,6 +699,40 @@ func (a Alert) ReminderDuration() time.Duration {
 	return time.Duration(a.ReminderInterval) * time.Minute
 }
 
+// EscalationStep is a step of an Alert's escalation, firing another Alert once
+// a check has been Critical for Delay minutes.
+type EscalationStep struct {
+	AlertID uuid.UUID
+	Delay   int
+}
+
+// DelayDuration returns the EscalationStep's Delay as a time.Duration.
+func (es EscalationStep) DelayDuration() time.Duration {
+	return time.Duration(es.Delay) * time.Minute
+}
+
+// NotifiesRecovery returns whether the Alert fires when a check recovers.
+// PagerDuty alerts always do, to resolve their incidents.
+func (a Alert) NotifiesRecovery() bool {
+	return !a.SkipRecovery || a.Type == AlertPagerDuty
+}
+
+// RecoveryParameters returns the Alert's Parameters for a recovery
+// notification.
+func (a Alert) RecoveryParameters() map[string]string {
+	params := make(map[string]string, len(a.Parameters)+2)
+	for k, v := range a.Parameters {
+		params[k] = v
+	}
+	if a.RecoverySubject != "" {
+		params["subject"] = a.RecoverySubject
+	}
+	if a.RecoveryBody != "" {
+		params["body"] = a.RecoveryBody
+	}
+	return params
+}
+
 // GetModified returns the last modified date of the Alert.
 func (a Alert) GetModified() time.Time {
 	return a.Modified
@@ -2,6 +2,7 @@ package model
 
 import (
 	"errors"
+	"time"
 
 	"github.com/satori/go.uuid"
 )
@@ -18,6 +19,7 @@ type AppContext interface {
 	SubjectRepo() SubjectRepo
 	CheckRepo() CheckRepo
 	CheckResultRepo() CheckResultRepo
+	CheckResultRollupRepo() CheckResultRollupRepo
 	CheckStateRepo() CheckStateRepo
 	AlertRepo() AlertRepo
 	TagRepo() TagRepo
@@ -64,9 +66,19 @@ type TagRepo interface {
 type CheckResultRepo interface {
 	Create(check *CheckResult) error
 	Count() (int, error)
+	Search(query CheckResultQuery) ([]CheckResult, error)
 	DeleteBySubject(subjectID uuid.UUID) error
 	DeleteByCheck(checkID uuid.UUID) error
 	DeleteBySubjectCheck(id SubjectCheckID) error
+	DeleteBefore(t time.Time) error
+}
+
+type CheckResultRollupRepo interface {
+	Find(id CheckResultRollupID) (CheckResultRollup, error)
+	Upsert(rollup CheckResultRollup) error
+	Count() (int, error)
+	Search(query CheckResultRollupQuery) ([]CheckResultRollup, error)
+	DeleteBefore(period RollupPeriod, t time.Time) error
 }
 
 type CheckStateRepo interface {
@@ -1,15 +1,20 @@
 package remotecheck
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/aprice/observatory"
 	"github.com/aprice/observatory/actions"
+	"github.com/aprice/observatory/checks"
 	"github.com/aprice/observatory/model"
 	"github.com/aprice/observatory/server/config"
 	"github.com/aprice/observatory/utils"
@@ -19,7 +24,7 @@ import (
 func executeCheck(csd model.CheckStateDetail, conf config.Configuration) {
 	log.Printf("Executing check %s", csd.Check.Name)
 	var (
-		status model.CheckStatus
+		output model.CheckOutput
 		err    error
 	)
 	ctx, err := conf.ContextFactory.Get()
@@ -28,40 +33,49 @@ func executeCheck(csd model.CheckStateDetail, conf config.Configuration) {
 		return
 	}
 	defer ctx.Close()
+	start := time.Now()
 	switch csd.Check.Type {
 	case model.CheckAgentDown:
-		status, err = executeAgentDownCheck(csd.Subject.ID, csd.Check.Parameters, ctx)
+		output, err = executeAgentDownCheck(csd.Subject.ID, csd.Check.Parameters, ctx)
 	case model.CheckVersion:
-		status, err = executeVersionCheck(csd.Subject.ID, csd.Check.Parameters, ctx)
+		output, err = executeVersionCheck(csd.Subject.ID, csd.Check.Parameters, ctx)
+	case model.CheckRemoteHTTP, model.CheckRemotePort, model.CheckRemotePing, model.CheckRemoteDNS, model.CheckRemotePrometheus:
+		output, err = executeNetworkCheck(csd)
+	case model.CheckPassive:
+		var expired bool
+		if output, expired, err = executePassiveCheck(csd, ctx); err == nil && !expired {
+			// Results are submitted externally; there is nothing to record.
+			return
+		}
 	default:
-		status = model.StatusNone
+		output.Status = model.StatusNone
 	}
 	if err != nil {
 		log.Println(err)
 	} else {
-		result := model.NewCheckResult(csd.Subject.ID, csd.Check.ID, time.Now(), status)
+		result := model.NewCheckResultFromOutput(csd.Subject.ID, csd.Check.ID, time.Now(), output, time.Since(start))
 		actions.RecordCheckResult(result, ctx, conf)
 	}
 }
 
-func executeAgentDownCheck(subjectID uuid.UUID, params map[string]string, ctx model.AppContext) (model.CheckStatus, error) {
+func executeAgentDownCheck(subjectID uuid.UUID, params map[string]string, ctx model.AppContext) (model.CheckOutput, error) {
 	log.Printf("Checking if agent %v has checked in.", subjectID)
 	var status model.CheckStatus
 	var err error
 	var warnThreshold, critThreshold time.Duration
 	if warnThresholdRaw, ok := params["warning"]; ok {
 		if warnThreshold, err = time.ParseDuration(warnThresholdRaw); err != nil {
-			return model.StatusNone, err
+			return model.CheckOutput{Status: model.StatusNone}, err
 		}
 	}
 	if critThresholdRaw, ok := params["critical"]; ok {
 		if critThreshold, err = time.ParseDuration(critThresholdRaw); err != nil {
-			return model.StatusNone, err
+			return model.CheckOutput{Status: model.StatusNone}, err
 		}
 	}
 	subject, err := ctx.SubjectRepo().Find(subjectID)
 	if err != nil {
-		return model.StatusFailed, err
+		return model.CheckOutput{Status: model.StatusFailed}, err
 	}
 	if warnThreshold != time.Duration(0) && subject.LastCheckIn.Add(warnThreshold).Before(time.Now()) {
 		status = model.StatusWarning
@@ -70,15 +84,121 @@ func executeAgentDownCheck(subjectID uuid.UUID, params map[string]string, ctx mo
 	} else {
 		status = model.StatusOK
 	}
-	return status, nil
+	since := time.Since(subject.LastCheckIn)
+	return model.CheckOutput{
+		Status:  status,
+		Message: fmt.Sprintf("Last check-in %v ago", since.Truncate(time.Second)),
+		Metrics: map[string]float64{"checkin_age_seconds": since.Seconds()},
+	}, nil
+}
+
+// executePassiveCheck reports whether a passive check has expired, because no
+// result has been recorded within its interval plus the optional "grace"
+// duration. The "expired" parameter sets the status of an expired check:
+// warning, critical (the default), or failed. Recording the expired result
+// restarts the clock, so while no results arrive one is recorded every
+// interval plus grace.
+func executePassiveCheck(csd model.CheckStateDetail, ctx model.AppContext) (model.CheckOutput, bool, error) {
+	var err error
+	var grace time.Duration
+	if raw := csd.Check.Parameters["grace"]; raw != "" {
+		if grace, err = time.ParseDuration(raw); err != nil {
+			return model.CheckOutput{Status: model.StatusFailed}, false, err
+		}
+	}
+	output := model.CheckOutput{Status: model.StatusCritical}
+	switch strings.ToLower(csd.Check.Parameters["expired"]) {
+	case "", "critical":
+	case "warning":
+		output.Status = model.StatusWarning
+	case "failed":
+		output.Status = model.StatusFailed
+	default:
+		return model.CheckOutput{Status: model.StatusFailed}, false, fmt.Errorf("invalid expired status: %q", csd.Check.Parameters["expired"])
+	}
+	// The state given is as of when the check was assigned, so it must be
+	// reloaded to see results recorded since.
+	state, err := ctx.CheckStateRepo().Find(csd.ID)
+	if err != nil && err != model.ErrNotFound {
+		return model.CheckOutput{Status: model.StatusFailed}, false, err
+	}
+	if state.Updated.IsZero() {
+		output.Message = "No result received"
+		return output, true, nil
+	}
+	since := time.Since(state.Updated)
+	if since <= csd.Check.IntervalDuration()+grace {
+		return model.CheckOutput{Status: state.Status}, false, nil
+	}
+	output.Message = fmt.Sprintf("No result received in %v", since.Truncate(time.Second))
+	output.Metrics = map[string]float64{"result_age_seconds": since.Seconds()}
+	return output, true, nil
+}
+
+// executeNetworkCheck runs a network check against a subject using the same
+// implementation as the agent. Parameters may refer to the subject and check
+// using template syntax, such as "https://{{.Subject.Name}}/health", and the
+// target host, or record name for DNS checks, defaults to the subject's name,
+// and the URL of Prometheus checks defaults to http://{subject name}/metrics.
+func executeNetworkCheck(csd model.CheckStateDetail) (model.CheckOutput, error) {
+	params, err := expandParams(csd)
+	if err != nil {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	target, value := "host", csd.Subject.Name
+	switch csd.Check.Type {
+	case model.CheckRemoteDNS:
+		target = "name"
+	case model.CheckRemotePrometheus:
+		target, value = "url", "http://"+csd.Subject.Name+"/metrics"
+	}
+	if params[target] == "" {
+		params[target] = value
+	}
+	switch csd.Check.Type {
+	case model.CheckRemoteHTTP:
+		return checks.HTTPCheck(params)
+	case model.CheckRemotePort:
+		return checks.PortCheck(params)
+	case model.CheckRemotePing:
+		return checks.TCPPingCheck(params)
+	case model.CheckRemoteDNS:
+		return checks.DNSCheck(params)
+	case model.CheckRemotePrometheus:
+		return checks.PrometheusCheck(csd.ID.SubjectID.String()+" "+csd.ID.CheckID.String(), params)
+	default:
+		return model.CheckOutput{Status: model.StatusNone}, nil
+	}
+}
+
+// expandParams returns a copy of the check's parameters with any templates
+// executed against the CheckStateDetail.
+func expandParams(csd model.CheckStateDetail) (map[string]string, error) {
+	params := make(map[string]string, len(csd.Check.Parameters))
+	for k, v := range csd.Check.Parameters {
+		if !strings.Contains(v, "{{") {
+			params[k] = v
+			continue
+		}
+		tmpl, err := template.New(k).Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		buf := new(bytes.Buffer)
+		if err = tmpl.Execute(buf, csd); err != nil {
+			return nil, err
+		}
+		params[k] = buf.String()
+	}
+	return params, nil
 }
 
 var checkClient = new(http.Client)
 
-func executeVersionCheck(subjectID uuid.UUID, params map[string]string, ctx model.AppContext) (model.CheckStatus, error) {
+func executeVersionCheck(subjectID uuid.UUID, params map[string]string, ctx model.AppContext) (model.CheckOutput, error) {
 	req, err := http.NewRequest("GET", "http://observatory-w3files.s3-website-us-east-1.amazonaws.com/version.json", nil)
 	if err != nil {
-		return model.StatusFailed, err
+		return model.CheckOutput{Status: model.StatusFailed}, err
 	}
 	resp, err := checkClient.Do(req)
 	if resp != nil {
@@ -88,12 +208,12 @@ func executeVersionCheck(subjectID uuid.UUID, params map[string]string, ctx mode
 		}()
 	}
 	if err != nil || resp.StatusCode >= 400 {
-		return model.StatusFailed, err
+		return model.CheckOutput{Status: model.StatusFailed}, err
 	}
 	vi := map[string]string{}
 	err = json.NewDecoder(resp.Body).Decode(&vi)
 	if err != nil {
-		return model.StatusFailed, err
+		return model.CheckOutput{Status: model.StatusFailed}, err
 	}
 
 	latest := ""
@@ -107,11 +227,15 @@ func executeVersionCheck(subjectID uuid.UUID, params map[string]string, ctx mode
 	}
 	comp, err := utils.CompareSemVer(observatory.Version, latest)
 	if err != nil {
-		return model.StatusFailed, err
-	} else if comp < 0 {
+		return model.CheckOutput{Status: model.StatusFailed}, err
+	}
+	output := model.CheckOutput{
+		Status:  model.StatusOK,
+		Message: fmt.Sprintf("Running %s, latest %s", observatory.Version, latest),
+	}
+	if comp < 0 {
 		log.Printf("Found update: currently %s, latest %s", observatory.Version, latest)
-		return model.StatusWarning, nil
-	} else {
-		return model.StatusOK, nil
+		output.Status = model.StatusWarning
 	}
+	return output, nil
 }
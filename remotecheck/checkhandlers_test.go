@@ -0,0 +1,158 @@
+package remotecheck
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/database/memory"
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+func TestExpandParams(t *testing.T) {
+	csd := model.CheckStateDetail{
+		Subject: model.Subject{Name: "web01"},
+		Check: model.Check{
+			Name: "health",
+			Parameters: map[string]string{
+				"url":   "https://{{.Subject.Name}}/{{.Check.Name}}",
+				"match": "ok",
+			},
+		},
+	}
+	params, err := expandParams(csd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params["url"] != "https://web01/health" || params["match"] != "ok" {
+		t.Errorf("expandParams: unexpected result %v", params)
+	}
+	if csd.Check.Parameters["url"] != "https://{{.Subject.Name}}/{{.Check.Name}}" {
+		t.Errorf("expandParams modified the check's parameters: %v", csd.Check.Parameters)
+	}
+
+	csd.Check.Parameters["url"] = "{{.Subject.Name"
+	if _, err = expandParams(csd); err == nil {
+		t.Errorf("expandParams: expected error for invalid template")
+	}
+}
+
+func TestExecuteNetworkCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// The host defaults to the subject's name.
+	csd := model.CheckStateDetail{
+		Subject: model.Subject{ID: utils.NewTimeUUID(), Name: "127.0.0.1"},
+		Check: model.Check{
+			Type:       model.CheckRemotePort,
+			Parameters: map[string]string{"port": port},
+		},
+	}
+	for _, checkType := range []model.CheckType{model.CheckRemotePort, model.CheckRemotePing} {
+		csd.Check.Type = checkType
+		out, err := executeNetworkCheck(csd)
+		if err != nil || out.Status != model.StatusOK {
+			t.Errorf("%v: expected OK, actual %v %q %v", checkType, out.Status, out.Message, err)
+		}
+	}
+
+	// DNS checks look up the subject's name rather than a host.
+	csd.Subject.Name = "localhost"
+	csd.Check = model.Check{
+		Type:       model.CheckRemoteDNS,
+		Parameters: map[string]string{"expect": "127.0.0.1"},
+	}
+	if out, err := executeNetworkCheck(csd); err != nil || out.Status != model.StatusOK {
+		t.Errorf("%v: expected OK, actual %v %q %v", csd.Check.Type, out.Status, out.Message, err)
+	}
+
+	// Prometheus checks scrape /metrics on the subject by default.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" {
+			fmt.Fprintln(w, "up 1")
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	csd.Subject.Name = strings.TrimPrefix(server.URL, "http://")
+	csd.Check = model.Check{
+		Type:       model.CheckRemotePrometheus,
+		Parameters: map[string]string{"metric": "up", "critical": "1", "below": "true"},
+	}
+	if out, err := executeNetworkCheck(csd); err != nil || out.Status != model.StatusOK {
+		t.Errorf("%v: expected OK, actual %v %q %v", csd.Check.Type, out.Status, out.Message, err)
+	}
+}
+
+func TestExecutePassiveCheck(t *testing.T) {
+	ctx, err := memory.InitStore().Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+	id := model.SubjectCheckID{SubjectID: utils.NewTimeUUID(), CheckID: utils.NewTimeUUID()}
+	csd := model.CheckStateDetail{
+		CheckState: model.CheckState{ID: id},
+		Check: model.Check{
+			ID:         id.CheckID,
+			Type:       model.CheckPassive,
+			Interval:   60,
+			Parameters: map[string]string{"grace": "30s"},
+		},
+	}
+
+	var tests = []struct {
+		name     string
+		updated  time.Duration
+		expired  string
+		expected model.CheckStatus
+		expires  bool
+	}{
+		{"fresh", 10 * time.Second, "", model.StatusOK, false},
+		{"within grace", 80 * time.Second, "", model.StatusOK, false},
+		{"expired", 100 * time.Second, "", model.StatusCritical, true},
+		{"expired failed", 100 * time.Second, "failed", model.StatusFailed, true},
+		{"expired warning", 100 * time.Second, "Warning", model.StatusWarning, true},
+	}
+	for _, tt := range tests {
+		csd.Check.Parameters["expired"] = tt.expired
+		ctx.CheckStateRepo().Upsert(model.CheckState{ID: id, Status: model.StatusOK, Updated: time.Now().Add(-tt.updated)})
+		out, expired, err := executePassiveCheck(csd, ctx)
+		if err != nil || expired != tt.expires || out.Status != tt.expected {
+			t.Errorf("%s: expected %v (expired %v), actual %v (expired %v) %q %v", tt.name, tt.expected, tt.expires, out.Status, expired, out.Message, err)
+		}
+		if expired && !strings.HasPrefix(out.Message, "No result received in 1m40s") {
+			t.Errorf("%s: unexpected message %q", tt.name, out.Message)
+		}
+	}
+
+	for _, params := range []map[string]string{
+		{"grace": "a while"},
+		{"expired": "ok"},
+	} {
+		csd.Check.Parameters = params
+		if out, _, err := executePassiveCheck(csd, ctx); err == nil || out.Status != model.StatusFailed {
+			t.Errorf("executePassiveCheck(%v): expected failure, actual %v, %v", params, out.Status, err)
+		}
+	}
+}
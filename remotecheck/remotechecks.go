@@ -106,6 +106,7 @@ func updateChecks(ownedChecks []model.CheckStateDetail, conf config.Configuratio
 			seenChecks.Add(id)
 		} else {
 			rc.stop()
+			delete(runningChecks, id)
 		}
 	}
 
@@ -40,7 +40,7 @@ func (f blackoutFilter) MatchSubjectCheck(subject model.Subject, check model.Che
 type blackoutFilterSet []blackoutFilter
 
 func newBlackoutFilterSet(periods []model.Period) blackoutFilterSet {
-	bfs := make(blackoutFilterSet, len(periods))
+	bfs := make(blackoutFilterSet, 0, len(periods))
 	for _, period := range periods {
 		bfs = append(bfs, newBlackoutFilter(period))
 	}
@@ -66,6 +66,16 @@ type remoteCheckDetail struct {
 	Blackout     bool
 }
 
+// OwnerStale reports whether the check's owner seems to have stopped running
+// it, having recorded no result for two intervals. Passive check results are
+// submitted externally rather than by the owner, so they are never stale.
+func (rcd remoteCheckDetail) OwnerStale(now time.Time) bool {
+	if rcd.Check.Type == model.CheckPassive {
+		return false
+	}
+	return now.After(rcd.State.Updated.Add(rcd.Check.IntervalDuration() * 2))
+}
+
 type remoteCheckSet map[model.SubjectCheckID]*remoteCheckDetail
 
 func (rcs remoteCheckSet) AddIfApplicable(subject model.Subject, check model.Check) {
@@ -77,8 +87,7 @@ func (rcs remoteCheckSet) AddIfApplicable(subject model.Subject, check model.Che
 		SubjectRoles: collections.NewStringSet(subject.Roles...),
 		CheckTags:    collections.NewStringSet(check.Tags...),
 	}
-	log.Println(subject.Roles)
-	if !rcd.SubjectRoles.ContainsAny(check.Tags...) {
+	if !rcd.SubjectRoles.ContainsAny(check.Roles...) {
 		// Not applicable
 		return
 	}
@@ -152,16 +161,22 @@ func UpdateCheckAssignments(conf config.Configuration) error {
 		return err
 	}
 	for _, state := range allCheckStates {
-		rcs[state.ID].State = state
+		// States may remain for checks which no longer apply.
+		if rcd, ok := rcs[state.ID]; ok {
+			rcd.State = state
+		}
 	}
 
 	//*** Create missing CheckStates ***//
 	for id, rcd := range rcs {
 		if rcd.State.Type == model.CheckNone {
+			// Passive checks expire an interval after the state is created if
+			// no result has been submitted.
 			state := model.CheckState{
-				ID:    id,
-				Roles: rcd.Subject.Roles,
-				Type:  rcd.Check.Type,
+				ID:      id,
+				Updated: time.Now(),
+				Roles:   rcd.Subject.Roles,
+				Type:    rcd.Check.Type,
 			}
 			err = ctx.CheckStateRepo().Upsert(state)
 			if err != nil {
@@ -223,7 +238,7 @@ func UpdateCheckAssignments(conf config.Configuration) error {
 			toAssign = append(toAssign, rcd.State)
 		} else if _, ok := downPeerSet[rcd.State.Owner]; ok {
 			toAssign = append(toAssign, rcd.State)
-		} else if time.Now().After(rcd.State.Updated.Add(rcd.Check.IntervalDuration() * 2)) {
+		} else if rcd.OwnerStale(time.Now()) {
 			toAssign = append(toAssign, rcd.State)
 			load[rcd.State.Owner]--
 		} else if imbalance > 0 && imbalance > rand.Float32() {
@@ -0,0 +1,57 @@
+package remotecheck
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/utils"
+)
+
+func TestAddIfApplicable(t *testing.T) {
+	subject := model.Subject{ID: utils.NewTimeUUID(), Roles: []string{"web"}}
+	rcs := remoteCheckSet{}
+	rcs.AddIfApplicable(subject, model.Check{ID: utils.NewTimeUUID(), Roles: []string{"db"}, Tags: []string{"web"}})
+	if len(rcs) != 0 {
+		t.Errorf("expected check for other roles not to apply, actual %v", rcs)
+	}
+	check := model.Check{ID: utils.NewTimeUUID(), Roles: []string{"web", "db"}}
+	rcs.AddIfApplicable(subject, check)
+	if _, ok := rcs[model.SubjectCheckID{SubjectID: subject.ID, CheckID: check.ID}]; !ok || len(rcs) != 1 {
+		t.Errorf("expected check for subject's role to apply, actual %v", rcs)
+	}
+}
+
+func TestBlackoutFilterSet(t *testing.T) {
+	subject := model.Subject{ID: utils.NewTimeUUID(), Roles: []string{"web"}}
+	check := model.Check{ID: utils.NewTimeUUID(), Tags: []string{"network"}}
+	if newBlackoutFilterSet([]model.Period{{Roles: []string{"db"}}}).MatchSubjectCheck(subject, check) {
+		t.Errorf("expected blackout for other roles not to match")
+	}
+	if !newBlackoutFilterSet([]model.Period{{Roles: []string{"db"}}, {Tags: []string{"network"}}}).MatchSubjectCheck(subject, check) {
+		t.Errorf("expected blackout for check's tag to match")
+	}
+}
+
+func TestOwnerStale(t *testing.T) {
+	now := time.Now()
+	var tests = []struct {
+		checkType model.CheckType
+		updated   time.Duration
+		expected  bool
+	}{
+		{model.CheckRemoteHTTP, 90 * time.Second, false},
+		{model.CheckRemoteHTTP, 3 * time.Minute, true},
+		{model.CheckPassive, 3 * time.Minute, false},
+		{model.CheckPassive, 24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		rcd := remoteCheckDetail{
+			State: model.CheckState{Updated: now.Add(-tt.updated)},
+			Check: model.Check{Type: tt.checkType, Interval: 60},
+		}
+		if actual := rcd.OwnerStale(now); actual != tt.expected {
+			t.Errorf("OwnerStale for %v updated %v ago: expected %v, actual %v", tt.checkType, tt.updated, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package retention
+
+import (
+	"bytes"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/server/config"
+	"github.com/aprice/observatory/utils"
+	"github.com/satori/go.uuid"
+)
+
+const retentionInterval = time.Hour
+
+// ExpireCheckResults starts a loop periodically applying the configured
+// retention policy. Only the cluster leader does any work.
+func ExpireCheckResults(conf config.Configuration, quit utils.SentinelChannel) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			// Follow the leader
+			if !conf.IsLeader() {
+				log.Printf("Not leader, skipping check result retention.")
+				continue
+			}
+			ctx, err := conf.ContextFactory.Get()
+			if err != nil {
+				log.Printf("Check result retention failed: %s", err.Error())
+				continue
+			}
+			if err = Apply(ctx, conf, time.Now()); err != nil {
+				log.Printf("Check result retention failed: %s", err.Error())
+			}
+			ctx.Close()
+			log.Println("Finished check result retention")
+		}
+	}
+}
+
+// Apply the configured retention policy as of the given time. Raw results
+// older than RawResultRetention are rolled up into hourly rollups; hourly
+// rollups older than HourlyRollupRetention are rolled up into daily rollups;
+// and daily rollups older than DailyRollupRetention are deleted. A retention
+// of zero keeps that data forever.
+func Apply(ctx model.AppContext, conf config.Configuration, now time.Time) error {
+	if conf.RawResultRetention > 0 {
+		cutoff := now.Add(-conf.RawResultRetentionDuration()).Truncate(time.Hour)
+		if err := rollupResults(ctx, cutoff); err != nil {
+			return err
+		}
+	}
+
+	if conf.HourlyRollupRetention > 0 {
+		cutoff := now.Add(-conf.HourlyRollupRetentionDuration()).Truncate(24 * time.Hour)
+		if err := rollupHourly(ctx, cutoff); err != nil {
+			return err
+		}
+	}
+
+	if conf.DailyRollupRetention > 0 {
+		cutoff := now.Add(-conf.DailyRollupRetentionDuration()).Truncate(24 * time.Hour)
+		return ctx.CheckResultRollupRepo().DeleteBefore(model.RollupDaily, cutoff)
+	}
+	return nil
+}
+
+// rollupResults rolls all raw results before the cutoff into hourly rollups,
+// then deletes them. Results are read a day at a time, working backward from
+// the cutoff, so each result's status holds until the next result even where
+// that falls in a later batch.
+func rollupResults(ctx model.AppContext, cutoff time.Time) error {
+	repo := ctx.CheckResultRepo()
+	t := newRollupTally(ctx.CheckResultRollupRepo(), model.RollupHourly)
+	until := cutoff
+	for {
+		latest, err := repo.Search(model.CheckResultQuery{Until: until, Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(latest) == 0 {
+			break
+		}
+		since := latest[0].Time.Truncate(24 * time.Hour)
+		results, err := repo.Search(model.CheckResultQuery{Since: since, Until: until})
+		if err != nil {
+			return err
+		}
+		if err = t.addResults(results); err != nil {
+			return err
+		}
+		until = since
+	}
+	if err := t.carryIn(); err != nil {
+		return err
+	}
+	if err := t.save(); err != nil {
+		return err
+	}
+	return repo.DeleteBefore(cutoff)
+}
+
+// pendingRollup is a rollup being tallied, starting from the stored rollup
+// for its window, if any.
+type pendingRollup struct {
+	model.CheckResultRollup
+	// stored is the Through of the stored rollup; anything up to it has
+	// already been counted, such as by a run interrupted before deleting the
+	// rolled up data.
+	stored model.CheckResultRollup
+	// nanos sums durations in nanoseconds, converted to seconds once, so that
+	// rounding errors don't accumulate.
+	nanos model.StatusTally
+	// lastStart is the start of the hourly rollup which set LastStatus, for
+	// daily rollups.
+	lastStart time.Time
+}
+
+// counts reports whether data up to the given result ID is new to the rollup.
+func (pr *pendingRollup) counts(id uuid.UUID) bool {
+	if id == uuid.Nil || pr.stored.Through == uuid.Nil {
+		return true
+	}
+	return bytes.Compare(id.Bytes(), pr.stored.Through.Bytes()) > 0
+}
+
+func (pr *pendingRollup) counted(id uuid.UUID) {
+	if bytes.Compare(id.Bytes(), pr.Through.Bytes()) > 0 {
+		pr.Through = id
+	}
+}
+
+// rollupTally accumulates rollups of one period.
+type rollupTally struct {
+	repo    model.CheckResultRollupRepo
+	period  model.RollupPeriod
+	pending map[model.CheckResultRollupID]*pendingRollup
+	// next is the time of the earliest result added so far for each subject's
+	// check, which ends the span of the last result of an earlier batch.
+	next map[model.SubjectCheckID]time.Time
+}
+
+func newRollupTally(repo model.CheckResultRollupRepo, period model.RollupPeriod) *rollupTally {
+	return &rollupTally{
+		repo:    repo,
+		period:  period,
+		pending: map[model.CheckResultRollupID]*pendingRollup{},
+		next:    map[model.SubjectCheckID]time.Time{},
+	}
+}
+
+// rollup returns the pending rollup for a subject's check at the given time.
+func (t *rollupTally) rollup(id model.SubjectCheckID, at time.Time) (*pendingRollup, error) {
+	key := model.CheckResultRollupID{
+		SubjectID: id.SubjectID,
+		CheckID:   id.CheckID,
+		Period:    t.period,
+		Start:     at.Truncate(t.period.Duration()),
+	}
+	if pr, ok := t.pending[key]; ok {
+		return pr, nil
+	}
+	stored, err := t.repo.Find(key)
+	if err == model.ErrNotFound {
+		stored = model.CheckResultRollup{ID: key}
+	} else if err != nil {
+		return nil, err
+	}
+	pr := &pendingRollup{CheckResultRollup: stored, stored: stored}
+	t.pending[key] = pr
+	return pr, nil
+}
+
+// addResults tallies a batch of results, which must be older than any batch
+// added before it.
+func (t *rollupTally) addResults(results []model.CheckResult) error {
+	bySubjectCheck := map[model.SubjectCheckID][]model.CheckResult{}
+	for _, cr := range results {
+		bySubjectCheck[cr.SubjectCheckID] = append(bySubjectCheck[cr.SubjectCheckID], cr)
+	}
+	for id, crs := range bySubjectCheck {
+		sort.Slice(crs, func(i, j int) bool { return crs[i].Time.Before(crs[j].Time) })
+		for i, cr := range crs {
+			end := cr.Time.Truncate(time.Hour).Add(time.Hour)
+			if i+1 < len(crs) {
+				end = crs[i+1].Time
+			} else if next, ok := t.next[id]; ok {
+				end = next
+			}
+			pr, err := t.rollup(id, cr.Time)
+			if err != nil {
+				return err
+			}
+			if pr.counts(cr.ID) {
+				pr.Counts.Add(cr.Status, 1)
+				pr.counted(cr.ID)
+			}
+			if err = t.addSpan(id, cr.Status, cr.ID, cr.Time, end); err != nil {
+				return err
+			}
+		}
+		t.next[id] = crs[0].Time
+	}
+	return nil
+}
+
+// addSpan attributes the time from start to end to a status, split across
+// the hours it covers.
+func (t *rollupTally) addSpan(id model.SubjectCheckID, status model.CheckStatus, resultID uuid.UUID, start, end time.Time) error {
+	for start.Before(end) {
+		hourEnd := start.Truncate(time.Hour).Add(time.Hour)
+		pieceEnd := hourEnd
+		if end.Before(hourEnd) {
+			pieceEnd = end
+		}
+		pr, err := t.rollup(id, start)
+		if err != nil {
+			return err
+		}
+		if pr.counts(resultID) {
+			pr.nanos.Add(status, int64(pieceEnd.Sub(start)))
+			if pieceEnd.Equal(hourEnd) {
+				pr.LastStatus = status
+			}
+			pr.counted(resultID)
+		}
+		start = pieceEnd
+	}
+	return nil
+}
+
+// carryIn attributes the time from the start of each subject's check's first
+// hour to its first result to the status at the end of the hour before, if
+// that hour has been rolled up.
+func (t *rollupTally) carryIn() error {
+	for id, first := range t.next {
+		start := first.Truncate(time.Hour)
+		if !first.After(start) {
+			continue
+		}
+		prev, err := t.repo.Find(model.CheckResultRollupID{
+			SubjectID: id.SubjectID,
+			CheckID:   id.CheckID,
+			Period:    model.RollupHourly,
+			Start:     start.Add(-time.Hour),
+		})
+		if err == model.ErrNotFound || err == nil && prev.Through == uuid.Nil {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err = t.addSpan(id, prev.LastStatus, prev.Through, start, first); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save stores every pending rollup, in place of any stored for its window.
+func (t *rollupTally) save() error {
+	for _, pr := range t.pending {
+		for _, status := range model.CheckStatuses {
+			pr.Seconds.Add(status, int64(time.Duration(pr.nanos.Get(status))/time.Second))
+		}
+		if err := t.repo.Upsert(pr.CheckResultRollup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollupHourly rolls all hourly rollups before the cutoff into daily rollups,
+// then deletes them.
+func rollupHourly(ctx model.AppContext, cutoff time.Time) error {
+	repo := ctx.CheckResultRollupRepo()
+	hourly, err := repo.Search(model.CheckResultRollupQuery{Period: model.RollupHourly, Until: cutoff})
+	if err != nil {
+		return err
+	}
+	t := newRollupTally(repo, model.RollupDaily)
+	for _, h := range hourly {
+		id := model.SubjectCheckID{SubjectID: h.ID.SubjectID, CheckID: h.ID.CheckID}
+		pr, err := t.rollup(id, h.ID.Start)
+		if err != nil {
+			return err
+		}
+		if !pr.counts(h.Through) {
+			continue
+		}
+		pr.Merge(h)
+		if h.Through != uuid.Nil {
+			pr.counted(h.Through)
+		}
+		if !h.ID.Start.Before(pr.lastStart) {
+			pr.LastStatus, pr.lastStart = h.LastStatus, h.ID.Start
+		}
+	}
+	if err = t.save(); err != nil {
+		return err
+	}
+	return repo.DeleteBefore(model.RollupHourly, cutoff)
+}
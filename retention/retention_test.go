@@ -0,0 +1,193 @@
+package retention
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aprice/observatory/database/memory"
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/server/config"
+	"github.com/aprice/observatory/utils"
+)
+
+func TestApply(t *testing.T) {
+	ctx, _ := memory.InitStore().Get()
+	conf := config.Configuration{RawResultRetention: 1, HourlyRollupRetention: 2, DailyRollupRetention: 3}
+	id := model.SubjectCheckID{SubjectID: utils.NewTimeUUID(), CheckID: utils.NewTimeUUID()}
+	day := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Two hours of results, every 15 minutes, warning in the second hour.
+	for i := 0; i < 8; i++ {
+		status := model.StatusOK
+		if i >= 4 {
+			status = model.StatusWarning
+		}
+		cr := model.NewCheckResult(id.SubjectID, id.CheckID, day.Add(time.Duration(i)*15*time.Minute), status)
+		ctx.CheckResultRepo().Create(&cr)
+	}
+	recent := model.NewCheckResult(id.SubjectID, id.CheckID, day.Add(36*time.Hour), model.StatusOK)
+	ctx.CheckResultRepo().Create(&recent)
+
+	// Raw results expire after one day.
+	if err := Apply(ctx, conf, day.Add(36*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := ctx.CheckResultRepo().Count(); count != 1 {
+		t.Errorf("Expected 1 raw result remaining, got %d", count)
+	}
+	hourly, _ := ctx.CheckResultRollupRepo().Search(model.CheckResultRollupQuery{Period: model.RollupHourly})
+	if len(hourly) != 2 {
+		t.Fatalf("Expected 2 hourly rollups, got %d", len(hourly))
+	}
+	if !hourly[0].ID.Start.Equal(day) || hourly[0].Counts.OK != 4 || hourly[0].Seconds.OK != 3600 {
+		t.Errorf("Unexpected first hourly rollup: %+v", hourly[0])
+	}
+	if hourly[1].Counts.Warning != 4 || hourly[1].Seconds.Warning != 3600 {
+		t.Errorf("Unexpected second hourly rollup: %+v", hourly[1])
+	}
+
+	// Hourly rollups expire into daily rollups after two days.
+	if err := Apply(ctx, conf, day.Add(72*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	daily, _ := ctx.CheckResultRollupRepo().Search(model.CheckResultRollupQuery{Period: model.RollupDaily})
+	if len(daily) != 1 {
+		t.Fatalf("Expected 1 daily rollup, got %d", len(daily))
+	}
+	if daily[0].Counts.Total() != 8 || daily[0].Seconds.OK != 3600 || daily[0].Seconds.Warning != 3600 {
+		t.Errorf("Unexpected daily rollup: %+v", daily[0])
+	}
+
+	// Daily rollups are deleted after three days.
+	if err := Apply(ctx, conf, day.Add(96*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := ctx.CheckResultRollupRepo().Count(); count != 1 {
+		t.Errorf("Expected only the rollup of the recent result, got %d rollups", count)
+	}
+}
+
+func TestApplyKeepingRawResults(t *testing.T) {
+	ctx, _ := memory.InitStore().Get()
+	conf := config.Configuration{RawResultRetention: 1}
+	id := model.SubjectCheckID{SubjectID: utils.NewTimeUUID(), CheckID: utils.NewTimeUUID()}
+	day := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		cr := model.NewCheckResult(id.SubjectID, id.CheckID, day.Add(time.Duration(i)*15*time.Minute), model.StatusOK)
+		ctx.CheckResultRepo().Create(&cr)
+	}
+	if err := Apply(ctx, conf, day.Add(36*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	recent := model.NewCheckResult(id.SubjectID, id.CheckID, day.Add(36*time.Hour), model.StatusOK)
+	ctx.CheckResultRepo().Create(&recent)
+
+	// Raw results are kept forever, but rollups still expire.
+	conf = config.Configuration{HourlyRollupRetention: 2, DailyRollupRetention: 3}
+	if err := Apply(ctx, conf, day.Add(72*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if daily, _ := ctx.CheckResultRollupRepo().Search(model.CheckResultRollupQuery{Period: model.RollupDaily}); len(daily) != 1 {
+		t.Errorf("Expected 1 daily rollup, got %d", len(daily))
+	}
+	if err := Apply(ctx, conf, day.Add(120*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := ctx.CheckResultRollupRepo().Count(); count != 0 {
+		t.Errorf("Expected no rollups remaining, got %d", count)
+	}
+	if count, _ := ctx.CheckResultRepo().Count(); count != 1 {
+		t.Errorf("Expected 1 raw result remaining, got %d", count)
+	}
+}
+
+// crashingResultRepo fails to delete rolled up results, as if the process
+// stopped between saving rollups and deleting the results.
+type crashingResultRepo struct {
+	model.CheckResultRepo
+}
+
+func (crashingResultRepo) DeleteBefore(t time.Time) error {
+	return errors.New("crashed")
+}
+
+type crashingContext struct {
+	model.AppContext
+}
+
+func (ctx crashingContext) CheckResultRepo() model.CheckResultRepo {
+	return crashingResultRepo{ctx.AppContext.CheckResultRepo()}
+}
+
+func TestApplyUnalignedResults(t *testing.T) {
+	ctx, _ := memory.InitStore().Get()
+	conf := config.Configuration{RawResultRetention: 1}
+	id := model.SubjectCheckID{SubjectID: utils.NewTimeUUID(), CheckID: utils.NewTimeUUID()}
+	day := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := func(at time.Duration, status model.CheckStatus) {
+		cr := model.NewCheckResult(id.SubjectID, id.CheckID, day.Add(at), status)
+		ctx.CheckResultRepo().Create(&cr)
+	}
+	hour := func(h int) model.CheckResultRollup {
+		rollup, err := ctx.CheckResultRollupRepo().Find(model.CheckResultRollupID{
+			SubjectID: id.SubjectID,
+			CheckID:   id.CheckID,
+			Period:    model.RollupHourly,
+			Start:     day.Add(time.Duration(h) * time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("hour %d: %v", h, err)
+		}
+		return rollup
+	}
+	record(10*time.Hour+50*time.Minute, model.StatusCritical)
+	record(11*time.Hour+10*time.Minute, model.StatusOK)
+	record(11*time.Hour+40*time.Minute, model.StatusOK)
+
+	// Critical holds from 10:50 until 11:10, across the hour boundary.
+	if err := Apply(ctx, conf, day.Add(36*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if h := hour(10); h.Seconds.Critical != 600 || h.Seconds.Total() != 600 || h.LastStatus != model.StatusCritical {
+		t.Errorf("Unexpected rollup for 10:00: %+v", h)
+	}
+	if h := hour(11); h.Seconds.Critical != 600 || h.Seconds.OK != 3000 || h.Counts.OK != 2 || h.LastStatus != model.StatusOK {
+		t.Errorf("Unexpected rollup for 11:00: %+v", h)
+	}
+
+	// The status at the end of a rolled up hour carries into the first
+	// result of the next hour rolled up later.
+	record(12*time.Hour+20*time.Minute, model.StatusWarning)
+	if err := Apply(ctx, conf, day.Add(37*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if h := hour(12); h.Seconds.OK != 1200 || h.Seconds.Warning != 2400 || h.Counts.Total() != 1 {
+		t.Errorf("Unexpected rollup for 12:00: %+v", h)
+	}
+
+	// Rolling up the same results again after failing to delete them doesn't
+	// count them twice.
+	record(13*time.Hour+30*time.Minute, model.StatusCritical)
+	if err := Apply(crashingContext{ctx}, conf, day.Add(38*time.Hour)); err == nil {
+		t.Fatal("Expected error deleting results")
+	}
+	crashed := hour(13)
+	if err := Apply(ctx, conf, day.Add(38*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if h := hour(13); h.Counts != crashed.Counts || h.Seconds != crashed.Seconds || h.Seconds.Warning != 1800 || h.Seconds.Critical != 1800 {
+		t.Errorf("Expected rollup for 13:00 unchanged by second pass %+v, got %+v", crashed, h)
+	}
+
+	// A result arriving after its hour was rolled up is still counted.
+	record(13*time.Hour+45*time.Minute, model.StatusOK)
+	if err := Apply(ctx, conf, day.Add(38*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if h := hour(13); h.Counts.Total() != 2 || h.LastStatus != model.StatusOK {
+		t.Errorf("Unexpected rollup for 13:00 after late result: %+v", h)
+	}
+	if count, _ := ctx.CheckResultRepo().Count(); count != 0 {
+		t.Errorf("Expected no raw results remaining, got %d", count)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aprice/observatory/actions"
 	"github.com/aprice/observatory/model"
 	"github.com/aprice/observatory/server/config"
 	"github.com/aprice/observatory/utils"
@@ -102,11 +103,14 @@ func NotAllowedResponse(w http.ResponseWriter, methods []string) {
 	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 }
 
-// ErrorResponse writes an Internal Server Error response, or a Not Found
-// response if the error given is model.ErrNotFound.
+// ErrorResponse writes an Internal Server Error response, a Not Found response
+// if the error given is model.ErrNotFound, or a Bad Request response if it is
+// actions.ErrInvalidEscalation.
 func ErrorResponse(w http.ResponseWriter, err error) {
 	if err == model.ErrNotFound {
 		NotFoundResponse(w)
+	} else if err == actions.ErrInvalidEscalation {
+		BadRequestResponse(w, err)
 	} else {
 		http.Error(w, errorMessageJSON("Internal Server Error: "+err.Error()), http.StatusInternalServerError)
 	}
@@ -122,6 +126,11 @@ func BadRequestResponse(w http.ResponseWriter, err error) {
 	http.Error(w, errorMessageJSON("Bad Request: "+err.Error()), http.StatusBadRequest)
 }
 
+// ConflictResponse writes a Conflict response, including error message.
+func ConflictResponse(w http.ResponseWriter, err error) {
+	http.Error(w, errorMessageJSON("Conflict: "+err.Error()), http.StatusConflict)
+}
+
 // NotImplementedResponse writes a Not Yet Implemented response.
 func NotImplementedResponse(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "text/plain")
@@ -14,11 +14,20 @@ import (
 	"github.com/kardianos/osext"
 	"github.com/satori/go.uuid"
 
+	"github.com/aprice/observatory/database/boltdb"
+	"github.com/aprice/observatory/database/memory"
 	"github.com/aprice/observatory/database/mongo"
 	"github.com/aprice/observatory/model"
 	"github.com/aprice/observatory/utils"
 )
 
+// Supported values for Configuration.StorageBackend.
+const (
+	StorageMongo  = "mongo"
+	StorageBolt   = "bolt"
+	StorageMemory = "memory"
+)
+
 // Configuration describes the configuration of the coordinator instance.
 type Configuration struct {
 	ID             uuid.UUID
@@ -34,6 +43,11 @@ type Configuration struct {
 	PeerCheckInterval         int
 	RemoteCheckUpdateInterval int
 	RemoteCheckAssignInterval int
+	RawResultRetention        int
+	HourlyRollupRetention     int
+	DailyRollupRetention      int
+	StorageBackend            string
+	BoltPath                  string
 	MongoHost                 string
 	MongoDatabase             string
 	MongoUser                 string
@@ -44,6 +58,7 @@ type Configuration struct {
 	SMTPUser                  string
 	SMTPPassword              string
 	EmailFrom                 string
+	PagerDutyURL              string
 }
 
 // New produces a Configuration filled with defaults.
@@ -62,10 +77,16 @@ func New() Configuration {
 		PeerCheckInterval:         5,
 		RemoteCheckUpdateInterval: 20,
 		RemoteCheckAssignInterval: 60,
+		RawResultRetention:        7,
+		HourlyRollupRetention:     90,
+		DailyRollupRetention:      730,
+		StorageBackend:            StorageMongo,
+		BoltPath:                  DefaultBoltPath(),
 		MongoHost:                 "localhost",
 		MongoDatabase:             "Observatory",
 		BootstrapPeers:            []string{},
 		Peers:                     NewPeers(),
+		PagerDutyURL:              "https://events.pagerduty.com/v2/enqueue",
 	}
 }
 
@@ -94,6 +115,21 @@ func (c Configuration) RemoteCheckAssignDuration() time.Duration {
 	return time.Duration(c.RemoteCheckAssignInterval) * time.Second
 }
 
+// RawResultRetentionDuration from RawResultRetention
+func (c Configuration) RawResultRetentionDuration() time.Duration {
+	return time.Duration(c.RawResultRetention) * 24 * time.Hour
+}
+
+// HourlyRollupRetentionDuration from HourlyRollupRetention
+func (c Configuration) HourlyRollupRetentionDuration() time.Duration {
+	return time.Duration(c.HourlyRollupRetention) * 24 * time.Hour
+}
+
+// DailyRollupRetentionDuration from DailyRollupRetention
+func (c Configuration) DailyRollupRetentionDuration() time.Duration {
+	return time.Duration(c.DailyRollupRetention) * 24 * time.Hour
+}
+
 // Endpoint address for this coordinator (Address:Port)
 func (c Configuration) Endpoint() string {
 	return fmt.Sprintf("%s:%d", c.Address, c.Port)
@@ -115,10 +151,23 @@ func (c *Configuration) Init() {
 	c.Peers.Run(*c)
 
 	// DB
-	var err error
-	c.ContextFactory, err = mongo.InitConnection(c.MongoHost, c.MongoDatabase, c.MongoUser, c.MongoPassword)
-	if err != nil {
-		log.Panic("failed to establish Mongo connection", err)
+	switch c.StorageBackend {
+	case StorageMemory:
+		c.ContextFactory = memory.InitStore()
+	case StorageBolt:
+		var err error
+		c.ContextFactory, err = boltdb.InitFile(c.BoltPath)
+		if err != nil {
+			log.Panic("failed to open database file", err)
+		}
+	case StorageMongo, "":
+		var err error
+		c.ContextFactory, err = mongo.InitConnection(c.MongoHost, c.MongoDatabase, c.MongoUser, c.MongoPassword)
+		if err != nil {
+			log.Panic("failed to establish Mongo connection", err)
+		}
+	default:
+		log.Panicf("unknown storage backend %q", c.StorageBackend)
 	}
 }
 
@@ -151,6 +200,21 @@ func DefaultConfigFilePath() string {
 	}
 }
 
+// DefaultBoltPath returns the context-sensitive default database file path
+// for the bolt storage backend.
+func DefaultBoltPath() string {
+	switch runtime.GOOS {
+	case "linux":
+		return "/var/lib/observatory/observatory.db"
+	default:
+		dir, err := osext.ExecutableFolder()
+		if err != nil {
+			return "./observatory.db"
+		}
+		return dir + "/observatory.db"
+	}
+}
+
 // Cleaned up from - where else - http://stackoverflow.com/a/31551220/7426
 func getLocalIP() (string, error) {
 	addrs, err := net.InterfaceAddrs()
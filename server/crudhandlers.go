@@ -207,6 +207,9 @@ func (c alertsCrud) create(w http.ResponseWriter, r *http.Request, entity interf
 	}
 	defer ctx.Close()
 	alert := entity.(*model.Alert)
+	if err = actions.ValidateEscalation(*alert, ctx); err != nil {
+		return "", err
+	}
 	alert.Modified = time.Now()
 	err = ctx.AlertRepo().Create(alert)
 	return c.conf.URLForPath("alerts/" + alert.ID.String()), err
@@ -232,6 +235,9 @@ func (c alertsCrud) update(w http.ResponseWriter, r *http.Request, id uuid.UUID,
 	if id != alert.ID {
 		return fmt.Errorf("URL ID %s and body ID %s do not match", id.String(), alert.ID.String())
 	}
+	if err = actions.ValidateEscalation(*alert, ctx); err != nil {
+		return err
+	}
 	alert.Modified = time.Now()
 	err = ctx.AlertRepo().Update(*alert)
 	if err != nil {
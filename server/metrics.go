@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/aprice/observatory"
+	"github.com/aprice/observatory/actions"
+	"github.com/aprice/observatory/alert"
+	"github.com/aprice/observatory/model"
+	"github.com/aprice/observatory/server/config"
+	"github.com/aprice/observatory/utils"
+)
+
+// handleMetrics serves the state of Observatory, and of this Coordinator, in
+// the Prometheus text exposition format.
+func handleMetrics(w http.ResponseWriter, r *http.Request, conf config.Configuration) {
+	if countPathParts(r) > 0 {
+		NotFoundResponse(w)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		buf := new(bytes.Buffer)
+		if err := writeMetrics(buf, conf); err != nil {
+			ErrorResponse(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.WriteHeader(http.StatusOK)
+		buf.WriteTo(w)
+	case http.MethodOptions:
+		OptionsResponse(w, r, []string{"GET"}, utils.Nothing)
+	default:
+		NotAllowedResponse(w, []string{"GET"})
+	}
+}
+
+// writeMetrics writes every metric family to the buffer.
+func writeMetrics(buf *bytes.Buffer, conf config.Configuration) error {
+	ctx, err := conf.ContextFactory.Get()
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+	mw := metricWriter{buf}
+
+	mw.Family("observatory_info", "gauge", "Information about this Coordinator.")
+	mw.Sample("observatory_info", 1, "id", conf.ID.String(), "version", observatory.Version, "build", observatory.Build)
+
+	leader := 0.0
+	if conf.IsLeader() {
+		leader = 1
+	}
+	mw.Family("observatory_leader", "gauge", "Whether this Coordinator is the cluster leader.")
+	mw.Sample("observatory_leader", leader)
+	mw.Family("observatory_peers", "gauge", "The number of live peer Coordinators.")
+	mw.Sample("observatory_peers", float64(len(conf.Peers.AlivePeerSet())))
+
+	mw.Family("observatory_check_results_received_total", "counter", "Check results received by this Coordinator.")
+	mw.Sample("observatory_check_results_received_total", float64(actions.CheckResultsReceived()))
+	fired, failed := alert.AlertCounts()
+	mw.Family("observatory_alerts_fired_total", "counter", "Alerts fired by this Coordinator, by type.")
+	for _, t := range sortedAlertTypes(fired) {
+		mw.Sample("observatory_alerts_fired_total", float64(fired[t]), "type", t.String())
+	}
+	mw.Family("observatory_alerts_failed_total", "counter", "Alerts which failed to fire on this Coordinator, by type.")
+	for _, t := range sortedAlertTypes(failed) {
+		mw.Sample("observatory_alerts_failed_total", float64(failed[t]), "type", t.String())
+	}
+
+	stats, err := actions.DataStats(conf)
+	if err != nil {
+		return err
+	}
+	mw.Family("observatory_entities", "gauge", "The number of stored entities, by type.")
+	for _, entity := range sortedKeys(stats) {
+		mw.Sample("observatory_entities", float64(stats[entity]), "entity", strings.ToLower(entity))
+	}
+
+	load, err := ctx.CheckStateRepo().CoordinatorWorkload()
+	if err != nil && err != model.ErrNotFound {
+		return err
+	}
+	mw.Family("observatory_remote_checks", "gauge", "The number of remote checks assigned to each Coordinator.")
+	coordinators := make([]uuid.UUID, 0, len(load))
+	for id := range load {
+		coordinators = append(coordinators, id)
+	}
+	sort.Slice(coordinators, func(i, j int) bool { return coordinators[i].String() < coordinators[j].String() })
+	for _, id := range coordinators {
+		mw.Sample("observatory_remote_checks", float64(load[id]), "coordinator", id.String())
+	}
+
+	roles, err := ctx.RoleRepo().AllRoles()
+	if err != nil && err != model.ErrNotFound {
+		return err
+	}
+	sort.Strings(roles)
+	mw.Family("observatory_role_subjects", "gauge", "The number of subjects in each role, by their worst check status.")
+	for _, role := range roles {
+		summary, err := ctx.CheckStateRepo().CountInRolesByStatus([]string{role})
+		if err != nil && err != model.ErrNotFound {
+			return err
+		}
+		mw.Sample("observatory_role_subjects", float64(summary.Ok), "role", role, "status", "ok")
+		mw.Sample("observatory_role_subjects", float64(summary.Warning), "role", role, "status", "warning")
+		mw.Sample("observatory_role_subjects", float64(summary.Critical), "role", role, "status", "critical")
+	}
+
+	return writeCheckStateMetrics(mw, ctx)
+}
+
+// writeCheckStateMetrics writes the status of every subject's checks.
+func writeCheckStateMetrics(mw metricWriter, ctx model.AppContext) error {
+	states, err := ctx.CheckStateRepo().InStatusRoles(model.CheckStatuses, nil)
+	if err != nil && err != model.ErrNotFound {
+		return err
+	}
+	subjects, err := ctx.SubjectRepo().Search("", "")
+	if err != nil && err != model.ErrNotFound {
+		return err
+	}
+	subjectNames := make(map[uuid.UUID]string, len(subjects))
+	for _, subject := range subjects {
+		subjectNames[subject.ID] = subject.Name
+	}
+	checks, err := ctx.CheckRepo().Search("", "", "")
+	if err != nil && err != model.ErrNotFound {
+		return err
+	}
+	checkNames := make(map[uuid.UUID]string, len(checks))
+	for _, check := range checks {
+		checkNames[check.ID] = check.Name
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].ID.SubjectID != states[j].ID.SubjectID {
+			return states[i].ID.SubjectID.String() < states[j].ID.SubjectID.String()
+		}
+		return states[i].ID.CheckID.String() < states[j].ID.CheckID.String()
+	})
+
+	mw.Family("observatory_check_status", "gauge",
+		"The status of each subject's check: -1 failed, 0 none, 1 OK, 2 warning, 3 critical.")
+	for _, state := range states {
+		mw.Sample("observatory_check_status", float64(state.Status), checkStateLabels(state, subjectNames, checkNames)...)
+	}
+	mw.Family("observatory_check_updated_timestamp_seconds", "gauge", "When each subject's check last reported a result.")
+	for _, state := range states {
+		mw.Sample("observatory_check_updated_timestamp_seconds", float64(state.Updated.Unix()), checkStateLabels(state, subjectNames, checkNames)...)
+	}
+	return nil
+}
+
+func checkStateLabels(state model.CheckState, subjectNames, checkNames map[uuid.UUID]string) []string {
+	return []string{
+		"subject", subjectNames[state.ID.SubjectID],
+		"check", checkNames[state.ID.CheckID],
+		"subject_id", state.ID.SubjectID.String(),
+		"check_id", state.ID.CheckID.String(),
+		"type", state.Type.String(),
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedAlertTypes(m map[model.AlertType]uint64) []model.AlertType {
+	types := make([]model.AlertType, 0, len(m))
+	for t := range m {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// metricWriter writes metrics in the Prometheus text exposition format.
+type metricWriter struct {
+	buf *bytes.Buffer
+}
+
+// Family writes the help and type comments which precede a metric's samples.
+func (mw metricWriter) Family(name, metricType, help string) {
+	fmt.Fprintf(mw.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// Sample writes one sample, with labels given as alternating names and values.
+func (mw metricWriter) Sample(name string, value float64, labels ...string) {
+	mw.buf.WriteString(name)
+	if len(labels) > 0 {
+		mw.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				mw.buf.WriteByte(',')
+			}
+			fmt.Fprintf(mw.buf, "%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+		}
+		mw.buf.WriteByte('}')
+	}
+	mw.buf.WriteByte(' ')
+	mw.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	mw.buf.WriteByte('\n')
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
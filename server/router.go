@@ -69,12 +69,18 @@ func (m ObservatoryMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		m.periodsCrudHandler.ServeHTTP(w, r)
 	case "checkresults":
 		handleCheckResults(w, r, *m.Conf)
+	case "heartbeat":
+		handleHeartbeat(w, r, *m.Conf)
 	case "checkstates":
 		handleCheckStates(w, r, *m.Conf)
 	case "roles":
 		handleRoles(w, r, *m.Conf)
 	case "tags":
 		handleTags(w, r, *m.Conf)
+	case "reports":
+		handleReports(w, r, *m.Conf)
+	case "metrics":
+		handleMetrics(w, r, *m.Conf)
 	case "debug":
 		handleDebug(w, r)
 	default:
@@ -1,9 +1,14 @@
 package server
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -123,7 +128,27 @@ func handleCheckResults(w http.ResponseWriter, r *http.Request, conf config.Conf
 	}
 	switch r.Method {
 	case http.MethodGet:
-		NotImplementedResponse(w)
+		query, err := parseCheckResultQuery(r)
+		if err != nil {
+			BadRequestResponse(w, err)
+			return
+		}
+		ctx, err := conf.ContextFactory.Get()
+		if err != nil {
+			ErrorResponse(w, err)
+			return
+		}
+		defer ctx.Close()
+		if err = resolveSubjectCheckNames(r, ctx, &query.SubjectID, &query.CheckID); err != nil {
+			ErrorResponse(w, err)
+			return
+		}
+		payload, err := actions.QueryCheckResults(ctx, query)
+		if err != nil {
+			ErrorResponse(w, err)
+			return
+		}
+		OkResponse(w, r, payload, shortLifetime)
 	case http.MethodPost:
 		result := model.CheckResult{}
 		err := json.NewDecoder(r.Body).Decode(&result)
@@ -150,6 +175,122 @@ func handleCheckResults(w http.ResponseWriter, r *http.Request, conf config.Conf
 	}
 }
 
+// maxHeartbeatMessage is the most of a heartbeat's request body kept as the
+// result message.
+const maxHeartbeatMessage = 64 * 1024
+
+// handleHeartbeat records a check result from a plain GET or POST, for cron
+// jobs and scripts to report in with curl:
+//
+//	/heartbeat/{subject}/{check}       - record an OK result
+//	/heartbeat/{subject}/{check}/fail  - record a Critical result
+//	/heartbeat/{subject}/{check}/start - record that the job started, so the
+//	                                     next OK or failure includes its duration
+//
+// The subject and check may be given by name or ID. The result message is taken
+// from the msg query parameter, or else the body of a POST. A heartbeat for a
+// check which does not apply to the subject is a Conflict.
+func handleHeartbeat(w http.ResponseWriter, r *http.Request, conf config.Configuration) {
+	parts := countPathParts(r)
+	action := pathPart(r, 3)
+	if parts < 2 || parts > 3 || pathPart(r, 1) == "" || pathPart(r, 2) == "" {
+		NotFoundResponse(w)
+		return
+	}
+	if parts == 3 && action != "fail" && action != "start" {
+		NotFoundResponse(w)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+	case http.MethodOptions:
+		OptionsResponse(w, r, []string{"GET", "POST"}, utils.Nothing)
+		return
+	default:
+		NotAllowedResponse(w, []string{"GET", "POST"})
+		return
+	}
+
+	ctx, err := conf.ContextFactory.Get()
+	if err != nil {
+		ErrorResponse(w, err)
+		return
+	}
+	defer ctx.Close()
+	id, err := resolveHeartbeat(ctx, pathPart(r, 1), pathPart(r, 2))
+	if err != nil {
+		ErrorResponse(w, err)
+		return
+	}
+
+	subject, check, err := actions.FindSubjectCheck(id, ctx)
+	if err == actions.ErrNotApplicable {
+		ConflictResponse(w, err)
+		return
+	} else if err != nil {
+		ErrorResponse(w, err)
+		return
+	}
+
+	now := time.Now()
+	if action == "start" {
+		if err = actions.RecordJobStart(subject, check, now, ctx); err != nil {
+			ErrorResponse(w, err)
+			return
+		}
+		NoContentResponse(w)
+		return
+	}
+	state, err := ctx.CheckStateRepo().Find(id)
+	if err != nil && err != model.ErrNotFound {
+		ErrorResponse(w, err)
+		return
+	}
+
+	status := model.StatusOK
+	if action == "fail" {
+		status = model.StatusCritical
+	}
+	result := model.NewCheckResult(id.SubjectID, id.CheckID, now, status)
+	result.Message = r.URL.Query().Get("msg")
+	if result.Message == "" && r.Method == http.MethodPost {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxHeartbeatMessage))
+		if err != nil {
+			BadRequestResponse(w, err)
+			return
+		}
+		result.Message = strings.TrimSpace(string(body))
+	}
+	if !state.Started.IsZero() {
+		result.Duration = now.Sub(state.Started)
+		result.Metrics = map[string]float64{"duration_seconds": result.Duration.Seconds()}
+	}
+	if err = actions.RecordCheckResult(result, ctx, conf); err != nil {
+		ErrorResponse(w, err)
+		return
+	}
+	NoContentResponse(w)
+}
+
+// resolveHeartbeat looks up a subject and check each given by name or ID.
+func resolveHeartbeat(ctx model.AppContext, subject, check string) (model.SubjectCheckID, error) {
+	var id model.SubjectCheckID
+	var err error
+	if id.SubjectID, err = uuid.FromString(subject); err != nil {
+		s, err := ctx.SubjectRepo().Named(subject)
+		if err != nil {
+			return id, err
+		}
+		id.SubjectID = s.ID
+	}
+	if id.CheckID, err = uuid.FromString(check); err != nil {
+		if id.CheckID, err = findCheckNamed(ctx, check); err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
+
 func handleCheckStates(w http.ResponseWriter, r *http.Request, conf config.Configuration) {
 	if countPathParts(r) > 1 {
 		NotFoundResponse(w)
@@ -174,15 +315,11 @@ func handleCheckStates(w http.ResponseWriter, r *http.Request, conf config.Confi
 		if rawStatuses, ok = r.URL.Query()["status"]; ok {
 			statuses = make([]model.CheckStatus, len(rawStatuses))
 			for i, rawStatus := range rawStatuses {
-				//TODO: Also support parsing text representations of statuses
-				var status int
-				status, err = strconv.Atoi(rawStatus)
+				statuses[i], err = model.ParseCheckStatus(rawStatus)
 				if err != nil {
-					ErrorResponse(w, err)
+					BadRequestResponse(w, err)
 					return
 				}
-				// TODO: Sanity check
-				statuses[i] = model.CheckStatus(status)
 			}
 		} else {
 			statuses = []model.CheckStatus{model.StatusOK, model.StatusWarning, model.StatusCritical}
@@ -292,3 +429,193 @@ func handleTags(w http.ResponseWriter, r *http.Request, conf config.Configuratio
 		NotAllowedResponse(w, []string{"GET"})
 	}
 }
+
+func handleReports(w http.ResponseWriter, r *http.Request, conf config.Configuration) {
+	if countPathParts(r) != 1 || pathPart(r, 1) != "availability" {
+		NotFoundResponse(w)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		query, err := parseAvailabilityQuery(r)
+		if err != nil {
+			BadRequestResponse(w, err)
+			return
+		}
+		ctx, err := conf.ContextFactory.Get()
+		if err != nil {
+			ErrorResponse(w, err)
+			return
+		}
+		defer ctx.Close()
+		if err = resolveSubjectCheckNames(r, ctx, &query.SubjectID, &query.CheckID); err != nil {
+			ErrorResponse(w, err)
+			return
+		}
+		report, err := actions.ComputeAvailability(ctx, query)
+		if err != nil {
+			ErrorResponse(w, err)
+			return
+		}
+		if r.URL.Query().Get("format") == "csv" {
+			availabilityCSVResponse(w, report)
+			return
+		}
+		OkResponse(w, r, report, defaultLifetime)
+	case http.MethodOptions:
+		OptionsResponse(w, r, []string{"GET"}, utils.Nothing)
+	default:
+		NotAllowedResponse(w, []string{"GET"})
+	}
+}
+
+// parseAvailabilityQuery builds an AvailabilityQuery from the request's query
+// string. The window defaults to the 30 days up to now.
+func parseAvailabilityQuery(r *http.Request) (model.AvailabilityQuery, error) {
+	var (
+		query model.AvailabilityQuery
+		err   error
+	)
+	params := r.URL.Query()
+	query.SubjectID = uuid.FromStringOrNil(params.Get("subject"))
+	query.CheckID = uuid.FromStringOrNil(params.Get("check"))
+	query.Role = params.Get("role")
+	query.Tag = params.Get("tag")
+	query.Until = time.Now()
+	if raw := params.Get("until"); raw != "" {
+		if query.Until, err = parseQueryTime(raw); err != nil {
+			return query, err
+		}
+	}
+	query.Since = query.Until.AddDate(0, 0, -30)
+	if raw := params.Get("since"); raw != "" {
+		if query.Since, err = parseQueryTime(raw); err != nil {
+			return query, err
+		}
+	}
+	if !query.Since.Before(query.Until) {
+		return query, fmt.Errorf("since must be before until")
+	}
+	switch group := model.AvailabilityGrouping(params.Get("group")); group {
+	case model.GroupBySubjectCheck, model.GroupByRole, model.GroupByTag:
+		query.GroupBy = group
+	default:
+		return query, fmt.Errorf("Bad group: %s", group)
+	}
+	if format := params.Get("format"); format != "" && format != "json" && format != "csv" {
+		return query, fmt.Errorf("Bad format: %s", format)
+	}
+	return query, nil
+}
+
+// availabilityCSVResponse writes an availability report as CSV, one row per
+// item, with percentages followed by seconds in each status.
+func availabilityCSVResponse(w http.ResponseWriter, report model.AvailabilityReport) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=availability.csv")
+	w.WriteHeader(http.StatusOK)
+	out := csv.NewWriter(w)
+	out.Write([]string{"Subject", "Check", "Role", "Tag",
+		"OK %", "Warning %", "Critical %", "Failed %",
+		"OK Seconds", "Warning Seconds", "Critical Seconds", "Failed Seconds"})
+	for _, item := range report.Items {
+		out.Write([]string{item.Subject, item.Check, item.Role, item.Tag,
+			formatPercent(item.Percent.OK), formatPercent(item.Percent.Warning),
+			formatPercent(item.Percent.Critical), formatPercent(item.Percent.Failed),
+			strconv.FormatInt(item.Seconds.OK, 10), strconv.FormatInt(item.Seconds.Warning, 10),
+			strconv.FormatInt(item.Seconds.Critical, 10), strconv.FormatInt(item.Seconds.Failed, 10)})
+	}
+	out.Flush()
+}
+
+func formatPercent(p float64) string {
+	return strconv.FormatFloat(p, 'f', 3, 64)
+}
+
+// parseCheckResultQuery builds a CheckResultQuery from the request's query
+// string. Times may be given in RFC 3339 format or as a duration before now
+// (e.g. "24h"). Subjects and checks given by name rather than ID are resolved
+// separately by resolveSubjectCheckNames.
+func parseCheckResultQuery(r *http.Request) (model.CheckResultQuery, error) {
+	var (
+		query model.CheckResultQuery
+		err   error
+	)
+	params := r.URL.Query()
+	query.SubjectID = uuid.FromStringOrNil(params.Get("subject"))
+	query.CheckID = uuid.FromStringOrNil(params.Get("check"))
+	for _, rawStatuses := range params["status"] {
+		for _, rawStatus := range strings.Split(rawStatuses, ",") {
+			status, err := model.ParseCheckStatus(rawStatus)
+			if err != nil {
+				return query, err
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+	if raw := params.Get("since"); raw != "" {
+		if query.Since, err = parseQueryTime(raw); err != nil {
+			return query, err
+		}
+	}
+	if raw := params.Get("until"); raw != "" {
+		if query.Until, err = parseQueryTime(raw); err != nil {
+			return query, err
+		}
+	}
+	if raw := params.Get("cursor"); raw != "" {
+		if query.Before, err = uuid.FromString(raw); err != nil {
+			return query, fmt.Errorf("Bad cursor: %s", raw)
+		}
+	}
+	if raw := params.Get("limit"); raw != "" {
+		if query.Limit, err = strconv.Atoi(raw); err != nil {
+			return query, fmt.Errorf("Bad limit: %s", raw)
+		}
+	}
+	return query, nil
+}
+
+// resolveSubjectCheckNames looks up the IDs of any subject or check given by
+// name in the request's query string.
+func resolveSubjectCheckNames(r *http.Request, ctx model.AppContext, subjectID, checkID *uuid.UUID) error {
+	params := r.URL.Query()
+	if name := params.Get("subject"); name != "" && *subjectID == uuid.Nil {
+		subject, err := ctx.SubjectRepo().Named(name)
+		if err != nil {
+			return err
+		}
+		*subjectID = subject.ID
+	}
+	if name := params.Get("check"); name != "" && *checkID == uuid.Nil {
+		id, err := findCheckNamed(ctx, name)
+		if err != nil {
+			return err
+		}
+		*checkID = id
+	}
+	return nil
+}
+
+// findCheckNamed returns the ID of the check with exactly the given name.
+func findCheckNamed(ctx model.AppContext, name string) (uuid.UUID, error) {
+	checks, err := ctx.CheckRepo().Search("^"+regexp.QuoteMeta(name)+"$", "", "")
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(checks) == 0 {
+		return uuid.Nil, model.ErrNotFound
+	}
+	return checks[0].ID, nil
+}
+
+func parseQueryTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Bad time: %s", raw)
+	}
+	return time.Now().Add(-d), nil
+}
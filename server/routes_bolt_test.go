@@ -0,0 +1,10 @@
+//+build bolt
+
+package server
+
+import "github.com/aprice/observatory/server/config"
+
+// Run the route tests against a BoltDB file instead of the in-memory store.
+func init() {
+	storageBackend = config.StorageBolt
+}
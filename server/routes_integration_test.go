@@ -1,5 +1,3 @@
-//+build mongo
-
 package server
 
 import (
@@ -10,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
@@ -17,9 +16,9 @@ import (
 
 	"github.com/aprice/observatory/alert"
 	"github.com/aprice/observatory/database"
-	"github.com/aprice/observatory/database/mongo"
 	"github.com/aprice/observatory/model"
 	"github.com/aprice/observatory/server/config"
+	"github.com/aprice/observatory/utils"
 
 	uuid "github.com/satori/go.uuid"
 )
@@ -153,6 +152,7 @@ func TestPostResult(t *testing.T) {
 		},
 	})
 
+	alert.WaitForAlerts()
 	if !alert.MockAlertExecutions.Contains(fmt.Sprintf("%s/%s", brapper.ID, testOK.ID)) {
 		t.Error("Mock alert not executed")
 	}
@@ -164,6 +164,211 @@ func TestPostResult(t *testing.T) {
 	}
 }
 
+// GET /checkresults
+func TestGetResults(t *testing.T) {
+	t.Run("subject-check", func(tt *testing.T) {
+		method := "GET"
+		route := "/checkresults?subject=bootstrapper&check=Test+OK&limit=1"
+		status, body := testRoute(method, route, "")
+		if status != http.StatusOK {
+			tt.Errorf("%s %s: Expected: %d, Actual: %d - %s", method, route, http.StatusOK, status, body)
+		}
+		payload := model.CheckResultPage{}
+		err := json.NewDecoder(strings.NewReader(body)).Decode(&payload)
+		if err != nil {
+			tt.Errorf("%s %s: Failed to decode body:\n\t%s\n\t%s", method, route, err, body)
+		}
+		if len(payload.Results) != 1 || payload.Next == "" {
+			tt.Errorf("%s %s: Expected 1 result and a next cursor, actual %d results, cursor %q", method, route, len(payload.Results), payload.Next)
+		}
+		route = fmt.Sprintf("/checkresults?subject=bootstrapper&check=Test+OK&cursor=%s", payload.Next)
+		status, body = testRoute(method, route, "")
+		if status != http.StatusOK {
+			tt.Errorf("%s %s: Expected: %d, Actual: %d - %s", method, route, http.StatusOK, status, body)
+		}
+		payload = model.CheckResultPage{}
+		err = json.NewDecoder(strings.NewReader(body)).Decode(&payload)
+		if err != nil {
+			tt.Errorf("%s %s: Failed to decode body:\n\t%s\n\t%s", method, route, err, body)
+		}
+		if len(payload.Results) == 0 {
+			tt.Errorf("%s %s: Expected results on second page, actual none", method, route)
+		}
+	})
+	t.Run("status", func(tt *testing.T) {
+		method := "GET"
+		route := "/checkresults?status=critical&since=1h"
+		status, body := testRoute(method, route, "")
+		if status != http.StatusOK {
+			tt.Errorf("%s %s: Expected: %d, Actual: %d - %s", method, route, http.StatusOK, status, body)
+		}
+		payload := model.CheckResultPage{}
+		err := json.NewDecoder(strings.NewReader(body)).Decode(&payload)
+		if err != nil {
+			tt.Errorf("%s %s: Failed to decode body:\n\t%s\n\t%s", method, route, err, body)
+		}
+		if len(payload.Results) == 0 {
+			tt.Errorf("%s %s: Expected results, actual none", method, route)
+		}
+		for _, result := range payload.Results {
+			if result.Status != model.StatusCritical {
+				tt.Errorf("%s %s: Expected status %s, actual %s", method, route, model.StatusCritical, result.Status)
+			}
+		}
+	})
+	execRouteTests(t, []testCase{
+		testCase{
+			Name:      "bad-status",
+			Method:    "GET",
+			Route:     "/checkresults?status=purple",
+			Status:    http.StatusBadRequest,
+			RespRegex: `Bad Request`,
+		},
+		testCase{
+			Name:      "unknown-subject",
+			Method:    "GET",
+			Route:     "/checkresults?subject=nobody",
+			Status:    http.StatusNotFound,
+			RespRegex: `Not Found`,
+		},
+	})
+}
+
+// GET|POST /heartbeat
+func TestHeartbeat(t *testing.T) {
+	execRouteTests(t, []testCase{
+		testCase{
+			Name:   "start",
+			Method: "GET",
+			Route:  "/heartbeat/bootstrapper/Test%20OK/start",
+			Status: http.StatusNoContent,
+		},
+		testCase{
+			Name:   "ok",
+			Method: "GET",
+			Route:  "/heartbeat/bootstrapper/Test%20OK?msg=backup+complete",
+			Status: http.StatusNoContent,
+		},
+	})
+	method := "GET"
+	route := "/checkresults?subject=bootstrapper&check=Test+OK&limit=1"
+	status, body := testRoute(method, route, "")
+	if status != http.StatusOK {
+		t.Fatalf("%s %s: Expected: %d, Actual: %d - %s", method, route, http.StatusOK, status, body)
+	}
+	payload := model.CheckResultPage{}
+	if err := json.NewDecoder(strings.NewReader(body)).Decode(&payload); err != nil {
+		t.Fatalf("%s %s: Failed to decode body:\n\t%s\n\t%s", method, route, err, body)
+	}
+	if len(payload.Results) != 1 {
+		t.Fatalf("%s %s: Expected 1 result, actual %d", method, route, len(payload.Results))
+	}
+	result := payload.Results[0]
+	if result.Status != model.StatusOK || result.Message != "backup complete" || result.Duration <= 0 {
+		t.Errorf("Expected OK result with message and duration, actual %s %q %v", result.Status, result.Message, result.Duration)
+	}
+
+	execRouteTests(t, []testCase{
+		testCase{
+			Name:    "fail",
+			Method:  "POST",
+			Route:   "/heartbeat/bootstrapper/Test%20OK/fail",
+			ReqBody: "disk full",
+			Status:  http.StatusNoContent,
+		},
+		testCase{
+			Name:      "unknown-subject",
+			Method:    "GET",
+			Route:     "/heartbeat/nobody/Test%20OK",
+			Status:    http.StatusNotFound,
+			RespRegex: `Not Found`,
+		},
+		testCase{
+			Name:      "unknown-action",
+			Method:    "GET",
+			Route:     "/heartbeat/bootstrapper/Test%20OK/pause",
+			Status:    http.StatusNotFound,
+			RespRegex: `Not Found`,
+		},
+		testCase{
+			Name:      "not-applicable",
+			Method:    "GET",
+			Route:     "/heartbeat/bootstrapper/Inapplicable",
+			Status:    http.StatusConflict,
+			RespRegex: `Conflict`,
+		},
+	})
+	status, body = testRoute(method, route, "")
+	payload = model.CheckResultPage{}
+	if err := json.NewDecoder(strings.NewReader(body)).Decode(&payload); err != nil || len(payload.Results) != 1 {
+		t.Fatalf("%s %s: Expected 1 result, actual %d - %s", method, route, status, body)
+	}
+	result = payload.Results[0]
+	if result.Status != model.StatusCritical || result.Message != "disk full" || result.Duration != 0 {
+		t.Errorf("Expected Critical result with message and no duration, actual %s %q %v", result.Status, result.Message, result.Duration)
+	}
+}
+
+// GET /metrics
+func TestMetrics(t *testing.T) {
+	execRouteTests(t, []testCase{
+		testCase{
+			Name:      "families",
+			Method:    "GET",
+			Route:     "/metrics",
+			Status:    http.StatusOK,
+			RespRegex: `(?s)# TYPE observatory_leader gauge\nobservatory_leader 1\n.*observatory_check_results_received_total [1-9].*observatory_entities\{entity="subjects"\} [1-9].*observatory_role_subjects\{role="healthy",status="ok"\} .*observatory_check_status\{subject="[^"]+",check="[^"]+",subject_id=`,
+		},
+		testCase{
+			Name:      "sub-path",
+			Method:    "GET",
+			Route:     "/metrics/extra",
+			Status:    http.StatusNotFound,
+			RespRegex: `Not Found`,
+		},
+	})
+}
+
+// GET /reports/availability
+func TestGetAvailability(t *testing.T) {
+	execRouteTests(t, []testCase{
+		testCase{
+			Name:      "subject-check",
+			Method:    "GET",
+			Route:     "/reports/availability?subject=bootstrapper&check=Test+OK&since=1h",
+			Status:    http.StatusOK,
+			RespRegex: `"Items":\[\{"Subject":"bootstrapper","Check":"Test OK","Seconds":\{[^}]*},"Percent":\{`,
+		},
+		testCase{
+			Name:      "by-role",
+			Method:    "GET",
+			Route:     "/reports/availability?role=healthy&group=role&since=1h",
+			Status:    http.StatusOK,
+			RespRegex: `"Items":\[\{"Role":"healthy",`,
+		},
+		testCase{
+			Name:      "csv",
+			Method:    "GET",
+			Route:     "/reports/availability?subject=bootstrapper&since=1h&format=csv",
+			Status:    http.StatusOK,
+			RespRegex: `^Subject,Check,Role,Tag,OK %,Warning %,Critical %,Failed %,OK Seconds,Warning Seconds,Critical Seconds,Failed Seconds\nbootstrapper,`,
+		},
+		testCase{
+			Name:      "bad-group",
+			Method:    "GET",
+			Route:     "/reports/availability?group=planet",
+			Status:    http.StatusBadRequest,
+			RespRegex: `Bad Request`,
+		},
+		testCase{
+			Name:   "unknown-report",
+			Method: "GET",
+			Route:  "/reports/uptime",
+			Status: http.StatusNotFound,
+		},
+	})
+}
+
 func BenchmarkPostCheckResult(b *testing.B) {
 	ctx, err := conf.ContextFactory.Get()
 	if err != nil {
@@ -221,7 +426,7 @@ func TestCheckSearch(t *testing.T) {
 		if err != nil {
 			tt.Errorf("%s %s: Failed to decode body:\n\t%s\n\t%s", method, route, err, body)
 		}
-		expected := 8
+		expected := 9
 		actual := len(payload)
 		if expected != actual {
 			tt.Errorf("%s %s: Expected %d checks, actual %d checks", method, route, expected, actual)
@@ -375,11 +580,48 @@ func TestCheckCrud(t *testing.T) {
 	})
 }
 
+func TestAlertEscalationValidation(t *testing.T) {
+	escalated := func(id uuid.UUID, steps ...uuid.UUID) model.Alert {
+		a := model.Alert{ID: id, Name: "Escalating alert", Type: model.AlertMock}
+		for _, step := range steps {
+			a.Escalation = append(a.Escalation, model.EscalationStep{AlertID: step, Delay: 15})
+		}
+		return a
+	}
+	execRouteTests(t, []testCase{
+		testCase{
+			Name:       "missing",
+			Method:     "POST",
+			Route:      "/alerts",
+			ReqPayload: escalated(uuid.Nil, mockAlertID, utils.NewTimeUUID()),
+			Status:     http.StatusBadRequest,
+			RespRegex:  "escalation step",
+		},
+		testCase{
+			Name:       "self",
+			Method:     "PUT",
+			Route:      "/alerts/" + mockAlertID.String(),
+			ReqPayload: escalated(mockAlertID, mockAlertID),
+			Status:     http.StatusBadRequest,
+			RespRegex:  "escalation step",
+		},
+		testCase{
+			Name:       "valid",
+			Method:     "POST",
+			Route:      "/alerts",
+			ReqPayload: escalated(uuid.Nil, mockAlertID),
+			Status:     http.StatusCreated,
+			RespRegex:  "Escalating alert",
+		},
+	})
+}
+
 /*** Test Harness ***/
 var (
-	dbName  string
-	handler http.Handler
-	conf    config.Configuration
+	dbName         string
+	storageBackend = config.StorageMemory
+	handler        http.Handler
+	conf           config.Configuration
 )
 var blackoutRoleCheckID, blackoutTagCheckID, mockAlertID, blackoutRolePeriodID, blackoutTagPeriodID, quietTagPeriodID, quietTagCheckID uuid.UUID
 
@@ -389,7 +631,9 @@ func TestMain(m *testing.M) {
 	log.Printf("Testing with DB: %s", dbName)
 	conf = config.New()
 	conf.Address = "127.0.0.1"
+	conf.StorageBackend = storageBackend
 	conf.MongoDatabase = dbName
+	conf.BoltPath = filepath.Join(os.TempDir(), dbName+".db")
 	conf.Init()
 	defer conf.ContextFactory.Close()
 
@@ -411,11 +655,19 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Println(err)
 	}
-	err = ctx.(*mongo.AppContext).DropDatabase()
-	if err != nil {
-		log.Println(err)
+	if db, ok := ctx.(interface {
+		DropDatabase() error
+	}); ok {
+		err = db.DropDatabase()
+		if err != nil {
+			log.Println(err)
+		}
 	}
 	ctx.Close()
+	if storageBackend == config.StorageBolt {
+		conf.ContextFactory.Close()
+		os.Remove(conf.BoltPath)
+	}
 	os.Exit(retCode)
 }
 
@@ -453,6 +705,15 @@ func fillTestData(ctx model.AppContext) {
 	ctx.CheckRepo().Create(&c)
 	quietTagCheckID = c.ID
 
+	c = model.Check{
+		Name:     "Inapplicable",
+		Type:     model.CheckExec,
+		Interval: 10,
+		Roles:    []string{"nowhere"},
+		Modified: time.Now(),
+	}
+	ctx.CheckRepo().Create(&c)
+
 	a := model.Alert{
 		Name:     "Mock alert",
 		Type:     model.AlertMock,
@@ -0,0 +1,10 @@
+//+build mongo
+
+package server
+
+import "github.com/aprice/observatory/server/config"
+
+// Run the route tests against a live MongoDB instead of the in-memory store.
+func init() {
+	storageBackend = config.StorageMongo
+}
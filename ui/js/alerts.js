@@ -1,4 +1,4 @@
-var AlertTypes = ["", "Exec", "Email", "PD"];
+var AlertTypes = ["", "Exec", "Email", "PagerDuty", "Mock", "Webhook", "Chat"];
 
 initConnection(function(){
 	$.getJSON( endpoint+"roles", function( roles ) {
@@ -15,8 +15,14 @@ initConnection(function(){
 		buildAlertsTable();
 		$("input[name=name]").val(urlParams["name"])
 	} else if ($("#AlertForm").length) {
+		// Escalation steps are shown by alert name, so load the alerts first.
+		$.getJSON( endpoint+"alerts", function( alerts ) {
+			populateEscalationAlerts(alerts);
+			if (document.location.search.startsWith("?id")) {
+				buildAlertForm();
+			}
+		});
 		if (document.location.search.startsWith("?id")) {
-			buildAlertForm();
 			$("#DeleteButton").click(deleteAlert);
 		} else {
 			$("#DeleteButton").hide();
@@ -27,7 +33,7 @@ initConnection(function(){
 			$(".conditionalInclude").removeClass("include");
 			$(".type"+$("#TypeField").val()+" .conditionalInclude").addClass("include");
 			$(".conditionalInclude").removeAttr("required");
-			$(".type"+$("#TypeField").val()+" .conditionalInclude").attr("required",true);
+			$(".type"+$("#TypeField").val()+" .conditionalInclude.typeRequired").attr("required",true);
 			$(".parameter").hide();
 			$(".type"+$("#TypeField").val()).show();
 		}).change();
@@ -38,6 +44,10 @@ initConnection(function(){
 			$(this).parent("li").remove();
 			addToRoleList($(this).parent("li").attr("data-role"));
 		});
+		$("#AddEscalationButton").click(addNewEscalationStep);
+		$("#EscalationList").on("click", ".removeEscalation", function() {
+			$(this).parent("li").remove();
+		});
 		$("#NewTagButton").click(addNewTag);
 		$("#AddTagButton").click(addExistingTag);
 		$("#TagList").on("click", ".removeTag", function() {
@@ -112,9 +122,44 @@ function populateAlertForm(alert) {
 	alert.Tags.forEach(function(tag){
 		addTag(tag);
 	});
+	(alert.Escalation || []).forEach(function(step){
+		addEscalationStep(step.AlertID, step.Delay);
+	});
+	$("#EscalationAlertSelect option[value="+alert.ID+"]").remove();
 	$("#TypeField").change();
 }
 
+function populateEscalationAlerts(alerts) {
+	alerts.forEach(function(alert){
+		var opt = $(document.createElement("option"));
+		opt.text(alert.Name);
+		opt.attr("value",alert.ID);
+		$("#EscalationAlertSelect").append(opt);
+	});
+}
+
+function addNewEscalationStep() {
+	var id = $("#EscalationAlertSelect").val();
+	if (id) {
+		addEscalationStep(id, parseInt($("#EscalationDelayField").val()) || 0);
+	}
+}
+
+function addEscalationStep(id, delay) {
+	var item = $('#EscalationLine li').clone();
+	item.attr("data-alert", id);
+	item.attr("data-delay", delay);
+	$(".alertName", item).text($("#EscalationAlertSelect option[value="+id+"]").text() || id);
+	$(".delay", item).text(delay);
+	$("#EscalationList").append(item);
+}
+
+function escalationSteps() {
+	return $("#EscalationList li").map(function(){
+		return {AlertID: $(this).attr("data-alert"), Delay: parseInt($(this).attr("data-delay"))};
+	}).get();
+}
+
 function addNewRole() {
 	var role = $("#NewRoleField").val();
 	if ($("#RoleList li[data-role="+role+"]").length == 0) {
@@ -166,6 +211,7 @@ var retryButton = "<button class='retry'>Back to Form</button>";
 
 function saveAlert() {
 	var alert = marshallForm($("#AlertForm .include"));
+	alert.Escalation = escalationSteps();
 	var successHandler = function(xhr, status, error) {
 		var msg = "Alert saved. <p class='controls'>"+backButton+"</p>";
 		$("#SuccessBody .bodyText").html(msg);
@@ -184,16 +230,16 @@ function saveAlert() {
 		});
 	};
 	if ($("#IDField").val()) {
-		check.ID = $("#IDField").val();
+		alert.ID = $("#IDField").val();
 		sendJSON(endpoint+"alerts/"+$("#IDField").val(),
-			check,
+			alert,
 			"PUT",
 			successHandler,
 			errorHandler
 		);
 	} else {
 		sendJSON(endpoint+"alerts",
-			check,
+			alert,
 			"POST",
 			successHandler,
 			errorHandler
@@ -1,4 +1,4 @@
-CheckTypes = ["", "Exec", "HTTP", "Port", "Checkin", "Mem", "CPU", "Disk", "Update"]
+CheckTypes = ["", "Exec", "HTTP", "Port", "Checkin", "Mem", "CPU", "Disk", "Update", "Remote HTTP", "Remote Port", "Remote Ping", "Remote DNS", "DNS", "Process", "Log", "File", "Passive", "Prometheus", "Remote Prometheus"]
 
 initConnection(function(){
 	$.getJSON( endpoint+"roles", function( roles ) {
@@ -27,7 +27,7 @@ initConnection(function(){
 			$(".conditionalInclude").removeClass("include");
 			$(".type"+$("#TypeField").val()+" .conditionalInclude").addClass("include")
 			$(".conditionalInclude").removeAttr("required");
-			$(".type"+$("#TypeField").val()+" .conditionalInclude").attr("required",true);
+			$(".type"+$("#TypeField").val()+" .conditionalInclude.typeRequired").attr("required",true);
 			$(".parameter").hide();
 			$(".type"+$("#TypeField").val()).show();
 		}).change();
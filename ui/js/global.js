@@ -79,7 +79,7 @@ function marshallForm(fields) {
 	for (i = 0; i < fields.length; i++) {
 		var field = $(fields[i]);
 		var key = field.attr("name");
-		var val = field.val();
+		var val = field.attr("type") == "checkbox" ? field.prop("checked") : field.val();
 		var forceArray = field.hasClass("array");
 		if (!field.hasClass("asString")) {
 			if (field.attr("type") == "number" || field.hasClass("number")) {
@@ -120,6 +120,8 @@ function unmarshallForm(ob, fields) {
 		if (val == null) continue;
 		if (field.attr("type") == "datetime-local") {
 			field.setDate(val);
+		} else if (field.attr("type") == "checkbox") {
+			field.prop("checked", val);
 		} else {
 			field.val(val);
 		}
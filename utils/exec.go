@@ -1,13 +1,13 @@
 package utils
 
 import (
-	"bufio"
+	"bytes"
 	"log"
 	"os/exec"
 	"syscall"
 )
 
-// Execute a check and return its exit code.
+// Execute a check and return its exit code and standard output.
 // Based on http://stackoverflow.com/a/10385867/7426 and
 // http://nathanleclaire.com/blog/2014/12/29/shelled-out-commands-in-golang/.
 func Execute(args ...string) (exitCode int, output []byte, err error) {
@@ -16,18 +16,9 @@ func Execute(args ...string) (exitCode int, output []byte, err error) {
 	cmd := exec.Command(args[0])
 	cmd.Args = args
 
-	cmdReader, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Println("Error creating StdoutPipe for Cmd", err)
-		return
-	}
-
-	scanner := bufio.NewScanner(cmdReader)
-	go func() {
-		for scanner.Scan() {
-			output = append(output, scanner.Bytes()...)
-		}
-	}()
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	defer func() { output = stdout.Bytes() }()
 
 	if err = cmd.Start(); err != nil {
 		return
@@ -3,6 +3,9 @@ package utils
 import (
 	"fmt"
 	"math"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -141,3 +144,95 @@ func HumanReadableBytesDecimal(bytes int64, sigdig int) string {
 	decDig := int(math.Max(0, float64(sigdig)-math.Floor(math.Log10(ofUnit)+1)))
 	return fmt.Sprintf("%.*f%s", decDig, ofUnit, decimalUnits[int(unit)])
 }
+
+// ParseBytes parses a byte count with an optional binary unit suffix, such as
+// "512", "64K", "1.5GiB" or "2GB". Units are powers of 1024 whether or not they
+// include the "i".
+func ParseBytes(raw string) (int64, error) {
+	num := strings.TrimSpace(raw)
+	unit := strings.TrimLeft(num, "0123456789.")
+	num = strings.TrimSpace(num[:len(num)-len(unit)])
+	value, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte count: %q", raw)
+	}
+	unit = strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(unit)), "B"), "I")
+	if unit == "" {
+		return int64(value), nil
+	}
+	exp := strings.Index("KMGTPE", unit)
+	if len(unit) != 1 || exp < 0 {
+		return 0, fmt.Errorf("invalid byte count: %q", raw)
+	}
+	return int64(value * math.Pow(1024, float64(exp+1))), nil
+}
+
+// StatusRange is an inclusive range of HTTP status codes.
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// ParseStatusRanges parses a comma-separated list of status codes, ranges of
+// codes such as 200-299, or classes of codes such as 2xx.
+func ParseStatusRanges(raw string) ([]StatusRange, error) {
+	ranges := []StatusRange{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		var (
+			r   StatusRange
+			err error
+		)
+		if len(part) == 3 && strings.HasSuffix(part, "xx") {
+			r.Min, err = strconv.Atoi(part[:1])
+			r.Min *= 100
+			r.Max = r.Min + 99
+		} else if bounds := strings.SplitN(part, "-", 2); len(bounds) == 2 {
+			if r.Min, err = strconv.Atoi(strings.TrimSpace(bounds[0])); err == nil {
+				r.Max, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			}
+		} else {
+			r.Min, err = strconv.Atoi(part)
+			r.Max = r.Min
+		}
+		if err != nil || r.Min > r.Max {
+			return nil, fmt.Errorf("invalid status: %q", part)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// StatusExpected reports whether the status code falls within any of the given
+// ranges. With no ranges, any status below 400 is expected.
+func StatusExpected(ranges []StatusRange, code int) bool {
+	if len(ranges) == 0 {
+		return code < 400
+	}
+	for _, r := range ranges {
+		if code >= r.Min && code <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHeaders parses request headers given one "Name: value" per line.
+func ParseHeaders(raw string) (http.Header, error) {
+	headers := http.Header{}
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid header: %q", line)
+		}
+		headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return headers, nil
+}
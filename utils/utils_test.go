@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"net/http"
 	"reflect"
 	"strings"
 	"testing"
@@ -169,3 +170,70 @@ func TestHumanReadableBytesDecimal(t *testing.T) {
 		}
 	}
 }
+
+func TestParseBytes(t *testing.T) {
+	var tests = []struct {
+		raw      string
+		expected int64
+		err      bool
+	}{
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"64K", 64 * 1024, false},
+		{"1.5GiB", 1536 * 1024 * 1024, false},
+		{"2 gb", 2 * 1024 * 1024 * 1024, false},
+		{"1T", 1 << 40, false},
+		{"", 0, true},
+		{"lots", 0, true},
+		{"10X", 0, true},
+		{"10KM", 0, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseBytes(tt.raw)
+		if (err != nil) != tt.err || actual != tt.expected {
+			t.Errorf("ParseBytes(%q): expected %v (error %v), actual %v, %v", tt.raw, tt.expected, tt.err, actual, err)
+		}
+	}
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	var tests = []struct {
+		raw      string
+		expected []StatusRange
+		err      bool
+	}{
+		{"200", []StatusRange{{200, 200}}, false},
+		{"200, 301-302,4XX", []StatusRange{{200, 200}, {301, 302}, {400, 499}}, false},
+		{"302-301", nil, true},
+		{"ok", nil, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseStatusRanges(tt.raw)
+		if (err != nil) != tt.err || (!tt.err && !reflect.DeepEqual(actual, tt.expected)) {
+			t.Errorf("ParseStatusRanges(%q): expected %v (error %v), actual %v, %v", tt.raw, tt.expected, tt.err, actual, err)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	var tests = []struct {
+		raw      string
+		expected http.Header
+		err      bool
+	}{
+		{"", http.Header{}, false},
+		{"Accept: text/plain\n\n X-Token : a:b \n", http.Header{"Accept": {"text/plain"}, "X-Token": {"a:b"}}, false},
+		{"X-Tag: a\nX-Tag: b", http.Header{"X-Tag": {"a", "b"}}, false},
+		{"Accept", nil, true},
+		{": value", nil, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseHeaders(tt.raw)
+		if (err != nil) != tt.err || (!tt.err && !reflect.DeepEqual(actual, tt.expected)) {
+			t.Errorf("ParseHeaders(%q): expected %v (error %v), actual %v, %v", tt.raw, tt.expected, tt.err, actual, err)
+		}
+	}
+}